@@ -1,8 +1,10 @@
 package main
 
 import (
+	"github.com/go-sphere/sphere-layout/internal/biz/task/accountpurger"
 	"github.com/go-sphere/sphere-layout/internal/biz/task/conncleaner"
 	"github.com/go-sphere/sphere-layout/internal/biz/task/dashinit"
+	"github.com/go-sphere/sphere-layout/internal/biz/task/phonemigrate"
 	"github.com/go-sphere/sphere-layout/internal/server/api"
 	"github.com/go-sphere/sphere-layout/internal/server/bot"
 	"github.com/go-sphere/sphere-layout/internal/server/dash"
@@ -17,6 +19,8 @@ func newApplication(
 	file *file.Web,
 	initialize *dashinit.DashInitialize,
 	cleaner *conncleaner.ConnectCleaner,
+	purger *accountpurger.AccountPurger,
+	phoneMigration *phonemigrate.PhoneMigration,
 ) *boot.Application {
 	return boot.NewApplication(
 		dash,
@@ -24,6 +28,8 @@ func newApplication(
 		//bot,
 		file,
 		initialize,
+		phoneMigration,
 		cleaner,
+		purger,
 	)
 }
@@ -8,11 +8,14 @@ package main
 
 import (
 	"github.com/go-sphere/sphere-layout/internal"
+	"github.com/go-sphere/sphere-layout/internal/biz/task/accountpurger"
 	"github.com/go-sphere/sphere-layout/internal/biz/task/conncleaner"
 	"github.com/go-sphere/sphere-layout/internal/biz/task/dashinit"
+	"github.com/go-sphere/sphere-layout/internal/biz/task/phonemigrate"
 	"github.com/go-sphere/sphere-layout/internal/config"
 	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/client"
+	"github.com/go-sphere/sphere-layout/internal/pkg/wxapp"
 	api2 "github.com/go-sphere/sphere-layout/internal/server/api"
 	bot2 "github.com/go-sphere/sphere-layout/internal/server/bot"
 	dash2 "github.com/go-sphere/sphere-layout/internal/server/dash"
@@ -41,14 +44,16 @@ func NewApplication(conf *config.Config) (*boot.Application, error) {
 		return nil, err
 	}
 	daoDao := dao.NewDao(entClient)
-	wechatConfig := conf.WxMini
+	wxappConfig := conf.WxMini
+	wechatConfig := wxappConfig.Config
 	cache := internal.NewWechatCache()
 	wechatWechat := wechat.NewWechat(wechatConfig, cache)
 	memoryCache := memory.NewByteCache()
 	service := dash.NewService(daoDao, wechatWechat, memoryCache, fileServer)
-	web := dash2.NewWebServer(dashConfig, fileServer, service)
 	apiConfig := conf.API
-	apiService := api.NewService(daoDao, wechatWechat, memoryCache, fileServer)
+	web := dash2.NewWebServer(dashConfig, apiConfig, fileServer, service)
+	apps := wxapp.NewApps(wechatWechat, wxappConfig)
+	apiService := api.NewService(daoDao, apps, memoryCache, fileServer)
 	apiWeb := api2.NewWebServer(apiConfig, fileServer, apiService)
 	telegramConfig := conf.Bot
 	botService := bot.NewService()
@@ -60,6 +65,8 @@ func NewApplication(conf *config.Config) (*boot.Application, error) {
 	fileWeb := file2.NewWebServer(fileConfig, fileServer)
 	dashInitialize := dashinit.NewDashInitialize(daoDao)
 	connectCleaner := conncleaner.NewConnectCleaner(daoDao, memoryCache)
-	application := newApplication(web, apiWeb, botBot, fileWeb, dashInitialize, connectCleaner)
+	accountPurger := accountpurger.NewAccountPurger(apiService)
+	phoneMigration := phonemigrate.NewPhoneMigration(daoDao)
+	application := newApplication(web, apiWeb, botBot, fileWeb, dashInitialize, connectCleaner, accountPurger, phoneMigration)
 	return application, nil
 }
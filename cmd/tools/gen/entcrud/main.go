@@ -11,6 +11,8 @@ import (
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/admin"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/adminsession"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/keyvaluestore"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/permission"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/role"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/user"
 )
 
@@ -22,7 +24,17 @@ func main() {
 			ent.Admin{},
 			entpb.Admin{},
 			[]any{ent.AdminCreate{}, ent.AdminUpdateOne{}},
-			conf.WithIgnoreFields(admin.FieldCreatedAt, admin.FieldUpdatedAt),
+			conf.WithIgnoreFields(
+				admin.FieldCreatedAt,
+				admin.FieldUpdatedAt,
+				admin.FieldTotpSecret,
+				admin.FieldTotpEnabled,
+				admin.FieldRecoveryCodes,
+				admin.FieldPasswordHistory,
+				admin.FieldLoginFailures,
+				admin.FieldLockedUntil,
+				admin.FieldOidcSubject,
+			),
 		),
 		conf.NewEntity(
 			ent.AdminSession{},
@@ -36,6 +48,18 @@ func main() {
 			[]any{ent.KeyValueStoreCreate{}, ent.KeyValueStoreUpdateOne{}, ent.KeyValueStoreUpsertOne{}},
 			conf.WithIgnoreFields(keyvaluestore.FieldCreatedAt, keyvaluestore.FieldUpdatedAt),
 		),
+		conf.NewEntity(
+			ent.Role{},
+			entpb.Role{},
+			[]any{ent.RoleCreate{}, ent.RoleUpdateOne{}},
+			conf.WithIgnoreFields(role.FieldCreatedAt, role.FieldUpdatedAt, role.FieldBuiltin),
+		),
+		conf.NewEntity(
+			ent.Permission{},
+			entpb.Permission{},
+			[]any{ent.PermissionCreate{}, ent.PermissionUpdateOne{}},
+			conf.WithIgnoreFields(permission.FieldCreatedAt, permission.FieldUpdatedAt, permission.FieldBuiltin),
+		),
 		conf.NewEntity(
 			ent.User{},
 			sharedv1.User{},
@@ -0,0 +1,94 @@
+//go:build spheretools
+// +build spheretools
+
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"text/template"
+
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	protoPackage = "dash.v1"
+	outputFile   = "./internal/server/dash/permission_gen.go"
+)
+
+type method struct {
+	Name        string
+	Permissions []string
+}
+
+type service struct {
+	Name    string
+	Methods []method
+}
+
+var tmpl = template.Must(template.New("permission").Parse(`// Code generated by cmd/tools/gen/permission. DO NOT EDIT.
+
+package dash
+
+import (
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+)
+{{range .}}
+// permissions{{.Name}} maps each {{.Name}} operation to the permissions declared by (dash.v1.permissions).
+var permissions{{.Name}} = map[string][]string{
+{{- $svc := .Name}}
+{{- range .Methods}}
+	dashv1.Operation{{$svc}}{{.Name}}: { {{- range $i, $p := .Permissions}}{{if $i}}, {{end}}{{printf "%q" $p}}{{end -}} },
+{{- end}}
+}
+{{end}}`))
+
+func main() {
+	var services []service
+	protoregistry.GlobalFiles.RangeFilesByPackage(protoPackage, func(file protoreflect.FileDescriptor) bool {
+		for i := 0; i < file.Services().Len(); i++ {
+			sd := file.Services().Get(i)
+			svc := service{Name: string(sd.Name())}
+			var missing []string
+			for j := 0; j < sd.Methods().Len(); j++ {
+				md := sd.Methods().Get(j)
+				permissions, _ := proto.GetExtension(md.Options(), dashv1.E_Permissions).([]string)
+				if len(permissions) == 0 {
+					missing = append(missing, string(md.Name()))
+					continue
+				}
+				svc.Methods = append(svc.Methods, method{Name: string(md.Name()), Permissions: permissions})
+			}
+			if len(svc.Methods) == 0 {
+				// 未声明任何权限的服务不需要登录, 例如 AuthService
+				continue
+			}
+			if len(missing) > 0 {
+				// 未声明权限的方法会被拒绝访问, 生成时直接报错以免遗漏
+				log.Fatalf("error: %s methods without (dash.v1.permissions): %v", svc.Name, missing)
+			}
+			services = append(services, svc)
+		}
+		return true
+	})
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, services); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	if err = os.WriteFile(outputFile, source, 0o644); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+//go:build spheretools
+// +build spheretools
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"ariga.io/atlas/sql/sqltool"
+	entsql "entgo.io/ent/dialect/sql"
+	"entgo.io/ent/dialect/sql/schema"
+	"github.com/go-sphere/sphere-layout/internal/config"
+	_ "github.com/go-sphere/sphere-layout/internal/pkg/database/client"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/migrate"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/migration"
+	"github.com/spf13/cobra"
+)
+
+const migrationRoot = "./internal/pkg/database/migrations"
+
+var (
+	rootCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Migration Tools",
+		Long:  `Migration Tools generates versioned migrations from the ent schema and applies them.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	diffCmd = &cobra.Command{
+		Use:   "diff <name>",
+		Short: "Generate a migration",
+		Long:  `Generate the up and down migration from the changes between the migration directory and the ent schema.`,
+		Args:  cobra.ExactArgs(1),
+	}
+	applyCmd = &cobra.Command{
+		Use:   "apply",
+		Short: "Apply pending migrations",
+	}
+	baselineCmd = &cobra.Command{
+		Use:   "baseline <version>",
+		Short: "Mark migrations as applied",
+		Long: `Mark the migrations up to and including the version as applied without running them,
+so that a database created by the auto-migration at startup can switch to apply.`,
+		Args: cobra.ExactArgs(1),
+	}
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show migration status",
+	}
+	downCmd = &cobra.Command{
+		Use:   "down",
+		Short: "Revert applied migrations",
+	}
+)
+
+var (
+	configPath string
+	dirPath    string
+)
+
+func main() {
+	Execute()
+}
+
+func loadConfig() (*config.Config, string, error) {
+	conf, err := config.NewConfig(configPath)
+	if err != nil {
+		return nil, "", err
+	}
+	dir := dirPath
+	if dir == "" {
+		// 迁移文件与数据库方言相关, 每种数据库单独一个目录
+		dir = path.Join(migrationRoot, conf.Database.Type)
+	}
+	return conf, dir, nil
+}
+
+func openMigrator() (*migration.Migrator, func() error, error) {
+	conf, dir, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	migrations, err := migration.Load(os.DirFS(dir))
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open(conf.Database.Type, conf.Database.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migration.NewMigrator(db, migrations), db.Close, nil
+}
+
+func init() {
+	{
+		flag := rootCmd.PersistentFlags()
+		flag.StringVar(&configPath, "config", "config.json", "config file path")
+		flag.StringVar(&dirPath, "dir", "", "migration directory, defaults to "+migrationRoot+"/<database type>")
+	}
+	{
+		flag := diffCmd.Flags()
+		dev := flag.String("dev", "file:dev?mode=memory&cache=shared", "empty database to compute the changes on, e.g. a scratch MySQL database")
+		diffCmd.RunE = func(cmd *cobra.Command, args []string) error {
+			conf, dir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if err = os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			migrationDir, err := sqltool.NewGolangMigrateDir(dir)
+			if err != nil {
+				return err
+			}
+			drv, err := entsql.Open(conf.Database.Type, *dev)
+			if err != nil {
+				return err
+			}
+			defer drv.Close()
+			m, err := schema.NewMigrate(
+				drv,
+				schema.WithDir(migrationDir),
+				schema.WithMigrationMode(schema.ModeReplay),
+				schema.WithDialect(conf.Database.Type),
+				schema.WithFormatter(sqltool.GolangMigrateFormatter),
+				schema.WithDropIndex(true),
+				schema.WithDropColumn(true),
+			)
+			if err != nil {
+				return err
+			}
+			return m.NamedDiff(context.Background(), args[0], migrate.Tables...)
+		}
+	}
+	{
+		applyCmd.RunE = func(cmd *cobra.Command, args []string) error {
+			migrator, closer, err := openMigrator()
+			if err != nil {
+				return err
+			}
+			defer closer()
+			applied, err := migrator.Up(context.Background())
+			for _, m := range applied {
+				fmt.Printf("applied %s_%s\n", m.Version, m.Name)
+			}
+			if err != nil {
+				return err
+			}
+			if len(applied) == 0 {
+				fmt.Println("no pending migrations")
+			}
+			return nil
+		}
+	}
+	{
+		baselineCmd.RunE = func(cmd *cobra.Command, args []string) error {
+			migrator, closer, err := openMigrator()
+			if err != nil {
+				return err
+			}
+			defer closer()
+			marked, err := migrator.Baseline(context.Background(), args[0])
+			if err != nil {
+				return err
+			}
+			for _, m := range marked {
+				fmt.Printf("marked %s_%s as applied\n", m.Version, m.Name)
+			}
+			if len(marked) == 0 {
+				fmt.Println("no pending migrations up to " + args[0])
+			}
+			return nil
+		}
+	}
+	{
+		statusCmd.RunE = func(cmd *cobra.Command, args []string) error {
+			migrator, closer, err := openMigrator()
+			if err != nil {
+				return err
+			}
+			defer closer()
+			revisions, err := migrator.Status(context.Background())
+			if err != nil {
+				return err
+			}
+			for _, r := range revisions {
+				state := "pending"
+				if r.AppliedAt != 0 {
+					state = "applied at " + time.Unix(r.AppliedAt, 0).Format(time.DateTime)
+				}
+				fmt.Printf("%s_%s\t%s\n", r.Version, r.Name, state)
+			}
+			return nil
+		}
+	}
+	{
+		flag := downCmd.Flags()
+		steps := flag.Int("steps", 1, "number of migrations to revert")
+		downCmd.RunE = func(cmd *cobra.Command, args []string) error {
+			if *steps < 1 {
+				return errors.New("steps must be positive")
+			}
+			migrator, closer, err := openMigrator()
+			if err != nil {
+				return err
+			}
+			defer closer()
+			reverted, err := migrator.Down(context.Background(), *steps)
+			for _, m := range reverted {
+				fmt.Printf("reverted %s_%s\n", m.Version, m.Name)
+			}
+			return err
+		}
+	}
+	rootCmd.AddCommand(diffCmd, applyCmd, baselineCmd, statusCmd, downCmd)
+}
+
+func Execute() {
+	err := rootCmd.Execute()
+	if err != nil {
+		os.Exit(1)
+	}
+}
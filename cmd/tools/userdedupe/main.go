@@ -0,0 +1,80 @@
+//go:build spheretools
+// +build spheretools
+
+// Command userdedupe merges the users sharing a login platform. Run it with the application stopped
+// before upgrading to the unique (platform, platform_id) index, the schema migration fails otherwise.
+// The sessions of merged users are revoked in the database, the application denies their access tokens
+// after the restart since it reads the revoked flag from the database.
+// It exits non-zero while users are left which need a manual decision.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/go-sphere/sphere-layout/internal/config"
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	_ "github.com/go-sphere/sphere-layout/internal/pkg/database/client"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "userdedupe",
+	Short: "Merge duplicate users",
+	Long:  `Merge the users bound to the same login platform into the oldest one.`,
+}
+
+func main() {
+	Execute()
+}
+
+func init() {
+	flag := rootCmd.Flags()
+	conf := flag.String("config", "config.json", "config file path")
+	dryRun := flag.Bool("dry-run", false, "only report the duplicates")
+	rootCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		con, err := config.NewConfig(*conf)
+		if err != nil {
+			return err
+		}
+		// 不能使用 client.NewDataBaseClient, 存在重复数据时创建唯一索引会失败
+		client, err := ent.Open(con.Database.Type, con.Database.Path)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		merges, sessions, err := dao.MergeDuplicatePlatforms(context.Background(), client, *dryRun)
+		if err != nil {
+			return err
+		}
+		conflicts := 0
+		for _, m := range merges {
+			fmt.Printf("%s:%s target=%d merged=%v skipped=%v\n", m.Platform, m.PlatformID, m.Target, m.Merged, m.Skipped)
+			conflicts += len(m.Skipped)
+		}
+		if *dryRun {
+			fmt.Printf("found %d duplicate platforms, nothing changed\n", len(merges))
+		} else {
+			fmt.Printf("merged %d duplicate platforms, revoked %d sessions\n", len(merges), len(sessions))
+		}
+		if conflicts > 0 {
+			fmt.Println("remaining conflicts, unlink the platform from the skipped users or merge them manually:")
+			for _, m := range merges {
+				for _, uid := range m.Skipped {
+					fmt.Printf("  %s:%s target=%d user=%d\n", m.Platform, m.PlatformID, m.Target, uid)
+				}
+			}
+			return fmt.Errorf("%d users share a platform and could not be merged", conflicts)
+		}
+		return nil
+	}
+}
+
+func Execute() {
+	err := rootCmd.Execute()
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/go-sphere/sphere-layout
 go 1.26.0
 
 require (
+	ariga.io/atlas v0.36.1
 	buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go v1.36.11-20260209202127-80ab13bee0bf.1
 	buf.build/go/protovalidate v1.1.3
 	entgo.io/ent v0.14.5
@@ -34,7 +35,6 @@ require (
 )
 
 require (
-	ariga.io/atlas v0.36.1 // indirect
 	cel.dev/expr v0.25.1 // indirect
 	filippo.io/edwards25519 v1.2.0 // indirect
 	github.com/KyleBanks/depth v1.2.1 // indirect
@@ -0,0 +1,69 @@
+package accountpurger
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-sphere/sphere-layout/internal/service/api"
+	"github.com/go-sphere/sphere/log"
+)
+
+const purgeInterval = time.Hour
+
+// AccountPurger periodically purges the accounts whose deletion grace period has ended.
+type AccountPurger struct {
+	service *api.Service
+	done    chan struct{}
+	once    sync.Once
+}
+
+func NewAccountPurger(service *api.Service) *AccountPurger {
+	return &AccountPurger{
+		service: service,
+		done:    make(chan struct{}),
+	}
+}
+
+func (p *AccountPurger) Identifier() string {
+	return "account_purger"
+}
+
+func (p *AccountPurger) Start(ctx context.Context) error {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		p.purge(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-p.done:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *AccountPurger) Stop(ctx context.Context) error {
+	p.once.Do(func() {
+		close(p.done)
+	})
+	return nil
+}
+
+func (p *AccountPurger) purge(ctx context.Context) {
+	for {
+		purged, err := p.service.PurgeDeletedAccounts(ctx)
+		if err != nil {
+			log.Warn("purge deleted accounts failed", log.Any("error", err.Error()))
+			return
+		}
+		if purged > 0 {
+			log.Info("purged deleted accounts", log.Any("count", purged))
+		}
+		// 一批未满说明已经清理完毕
+		if purged < api.AccountPurgeBatchSize {
+			return
+		}
+	}
+}
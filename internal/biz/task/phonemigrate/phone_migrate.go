@@ -0,0 +1,95 @@
+package phonemigrate
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/keyvaluestore"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/pkg/phone"
+	"github.com/go-sphere/sphere/log"
+)
+
+// legacyCountryCode is the country of the phone numbers stored before E.164, only mainland China numbers were accepted.
+const legacyCountryCode = "86"
+
+// PhoneMigration rewrites the phone platform ids stored as bare national numbers to E.164 once.
+type PhoneMigration struct {
+	db *dao.Dao
+}
+
+func NewPhoneMigration(db *dao.Dao) *PhoneMigration {
+	return &PhoneMigration{db: db}
+}
+
+func (m *PhoneMigration) Identifier() string {
+	return "phone_migration"
+}
+
+func (m *PhoneMigration) Start(ctx context.Context) error {
+	key := "did_migrate_phone_e164"
+	return dao.WithTxEx(ctx, m.db.Client, func(ctx context.Context, client *ent.Client) error {
+		exist, err := client.KeyValueStore.Query().Where(keyvaluestore.KeyEQ(key)).Exist(ctx)
+		if err != nil {
+			return err
+		}
+		if exist {
+			return nil
+		}
+		if err = migratePhonePlatforms(ctx, client); err != nil {
+			return err
+		}
+		_, err = client.KeyValueStore.Create().
+			SetKey(key).
+			SetValue([]byte(strconv.Itoa(int(time.Now().Unix())))).
+			Save(ctx)
+		return err
+	})
+}
+
+func (m *PhoneMigration) Stop(ctx context.Context) error {
+	return nil
+}
+
+func migratePhonePlatforms(ctx context.Context, client *ent.Client) error {
+	platforms, err := client.UserPlatform.Query().
+		Where(
+			userplatform.PlatformEQ(userplatform.PlatformPhone),
+			userplatform.Not(userplatform.PlatformIDHasPrefix("+")),
+		).
+		All(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range platforms {
+		number, err := phone.Normalize(p.PlatformID, legacyCountryCode)
+		if err != nil {
+			log.Warn("skip invalid phone number", log.Any("platform", p.ID), log.Any("phone", p.PlatformID))
+			continue
+		}
+		existing, err := client.UserPlatform.Query().
+			Where(userplatform.PlatformEQ(userplatform.PlatformPhone), userplatform.PlatformIDEQ(number)).
+			First(ctx)
+		if err != nil && !ent.IsNotFound(err) {
+			return err
+		}
+		if existing != nil {
+			if existing.UserID == p.UserID {
+				// 同一用户已经绑定了 E.164 格式的号码, 旧记录是重复的
+				if err = client.UserPlatform.DeleteOne(p).Exec(ctx); err != nil {
+					return err
+				}
+				continue
+			}
+			log.Warn("skip phone number bound to another user", log.Any("platform", p.ID), log.Any("phone", number), log.Any("user", existing.UserID))
+			continue
+		}
+		if err = client.UserPlatform.UpdateOne(p).SetPlatformID(number).Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
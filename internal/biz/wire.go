@@ -1,12 +1,16 @@
 package biz
 
 import (
+	"github.com/go-sphere/sphere-layout/internal/biz/task/accountpurger"
 	"github.com/go-sphere/sphere-layout/internal/biz/task/conncleaner"
 	"github.com/go-sphere/sphere-layout/internal/biz/task/dashinit"
+	"github.com/go-sphere/sphere-layout/internal/biz/task/phonemigrate"
 	"github.com/google/wire"
 )
 
 var ProviderSet = wire.NewSet(
 	dashinit.NewDashInitialize,
 	conncleaner.NewConnectCleaner,
+	accountpurger.NewAccountPurger,
+	phonemigrate.NewPhoneMigration,
 )
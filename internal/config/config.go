@@ -7,11 +7,17 @@ import (
 	"github.com/go-sphere/confstore/provider/file"
 	"github.com/go-sphere/confstore/provider/http"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/client"
+	"github.com/go-sphere/sphere-layout/internal/pkg/mail"
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc"
+	"github.com/go-sphere/sphere-layout/internal/pkg/security"
+	"github.com/go-sphere/sphere-layout/internal/pkg/wxapp"
 	"github.com/go-sphere/sphere-layout/internal/server/api"
 	"github.com/go-sphere/sphere-layout/internal/server/bot"
 	"github.com/go-sphere/sphere-layout/internal/server/dash"
 	"github.com/go-sphere/sphere-layout/internal/server/docs"
 	fileweb "github.com/go-sphere/sphere-layout/internal/server/file"
+	serviceapi "github.com/go-sphere/sphere-layout/internal/service/api"
+	servicedash "github.com/go-sphere/sphere-layout/internal/service/dash"
 	"github.com/go-sphere/sphere/log/zapx"
 	spherefile "github.com/go-sphere/sphere/server/service/file"
 	"github.com/go-sphere/sphere/utils/secure"
@@ -30,7 +36,7 @@ type Config struct {
 	Local        spherefile.LocalFileServiceConfig `json:"local" yaml:"local"`
 	Docs         docs.Config                       `json:"docs" yaml:"docs"`
 	Bot          bot.Config                        `json:"bot" yaml:"bot"`
-	WxMini       wechat.Config                     `json:"wx_mini" yaml:"wx_mini"`
+	WxMini       wxapp.Config                      `json:"wx_mini" yaml:"wx_mini"`
 }
 
 func NewEmptyConfig() *Config {
@@ -59,13 +65,71 @@ func NewEmptyConfig() *Config {
 				Cors:    nil,
 				Static:  "",
 			},
+			TOTP: dash.TOTPConfig{
+				Issuer:    "Sphere",
+				SecretKey: secure.RandString(32),
+			},
+			OIDC: dash.OIDCConfig{
+				Enabled: false,
+				Provider: oidc.Config{
+					Issuer:      "https://sso.example.com",
+					ClientID:    "sphere-dash",
+					RedirectURL: "http://localhost:8800/dash/#/login/oidc",
+					Scopes:      []string{"openid", "profile", "email", "groups"},
+				},
+				Login: servicedash.OidcLoginPolicy{
+					UsernameClaim: "preferred_username",
+					GroupsClaim:   "groups",
+					GroupRoles: map[string][]string{
+						"sphere-admins": {servicedash.PermissionAll},
+					},
+				},
+			},
+			PasswordPolicy: security.PasswordPolicy{
+				MinLength:    8,
+				RequireUpper: true,
+				RequireLower: true,
+				RequireDigit: true,
+				HistorySize:  5,
+			},
+			LoginLockout: security.LockoutPolicy{
+				MaxAttempts: 5,
+				BaseSeconds: 60,
+				MaxSeconds:  3600 * 24,
+			},
+			Menus: dash.DefaultMenus(),
 		},
 		API: api.Config{
-			JWT: secure.RandString(32),
+			JWT:        secure.RandString(32),
+			RefreshJWT: secure.RandString(32),
 			HTTP: api.HTTPConfig{
 				Address: "0.0.0.0:8899",
 				Cors:    nil,
 			},
+			Impersonation: api.ImpersonationConfig{
+				BlockWrites: true,
+			},
+			Phone: serviceapi.PhoneRegions{
+				DefaultCountryCode:  serviceapi.PhoneDefaultCountryCode,
+				AllowedCountryCodes: []string{serviceapi.PhoneDefaultCountryCode},
+			},
+			AccountDeletion: api.AccountDeletionConfig{
+				GraceDays: 15,
+			},
+			Email: api.EmailConfig{
+				SMTP: mail.Config{
+					Port: 587,
+					From: "Sphere <noreply@example.com>",
+				},
+				Links: serviceapi.EmailLinks{
+					VerifyURL: "http://localhost:8899/#/email/verify",
+					ResetURL:  "http://localhost:8899/#/password/reset",
+				},
+				PasswordPolicy: security.PasswordPolicy{
+					MinLength:    8,
+					RequireDigit: true,
+				},
+			},
 		},
 		File: fileweb.Config{
 			Address: "0.0.0.0:9900",
@@ -85,11 +149,13 @@ func NewEmptyConfig() *Config {
 		Bot: bot.Config{
 			Token: "NOT",
 		},
-		WxMini: wechat.Config{
-			AppID:     "YOUR_WX_MINI_APP_ID",
-			AppSecret: "YOUR_WX_MINI_APP_SECRET",
-			Proxy:     "",
-			Env:       "develop",
+		WxMini: wxapp.Config{
+			Config: wechat.Config{
+				AppID:     "YOUR_WX_MINI_APP_ID",
+				AppSecret: "YOUR_WX_MINI_APP_SECRET",
+				Proxy:     "",
+				Env:       "develop",
+			},
 		},
 	}
 }
@@ -3,6 +3,8 @@ package auth
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
@@ -13,18 +15,52 @@ import (
 )
 
 const (
-	AppTokenValidDuration = time.Hour * 24 * 7
+	AppTokenValidDuration        = time.Hour * 2
+	AppRefreshTokenValidDuration = time.Hour * 24 * 30
+
+	sessionRolePrefix = "session:"
 )
 
-func RenderClaims(user *ent.User, pla *ent.UserPlatform, duration time.Duration) jwtauth.RBACClaims[int64] {
+// WechatPlatforms are the WeChat platform variants, their second id is the unionid of the user
+// and their app id the mini program or official account the openid belongs to.
+var WechatPlatforms = []userplatform.Platform{
+	userplatform.PlatformWechatMini,
+	userplatform.PlatformWechatOa,
+}
+
+// PlatformSubject is the subject of the tokens issued for a login through the platform.
+func PlatformSubject(pla *ent.UserPlatform) string {
+	return string(pla.Platform) + ":" + pla.PlatformID
+}
+
+// RenderClaims builds the claims of an app access token. The session it belongs to is recorded
+// in the roles, so that revoking a device also rejects its access tokens.
+func RenderClaims(user *ent.User, subject string, sessionID int64, duration time.Duration) jwtauth.RBACClaims[int64] {
 	return jwtauth.NewRBACClaims(
 		user.ID,
-		string(pla.Platform)+":"+pla.PlatformID,
-		[]string{},
+		subject,
+		[]string{sessionRolePrefix + strconv.FormatInt(sessionID, 10)},
 		time.Now().Add(duration),
 	)
 }
 
+// SessionID returns the session of an app access token.
+func SessionID(claims *jwtauth.RBACClaims[int64]) (int64, bool) {
+	if claims == nil {
+		return 0, false
+	}
+	for _, role := range claims.Roles {
+		if raw, ok := strings.CutPrefix(role, sessionRolePrefix); ok {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return id, true
+		}
+	}
+	return 0, false
+}
+
 type Response struct {
 	IsNew    bool
 	User     *ent.User
@@ -40,14 +76,17 @@ type Mode int
 
 const (
 	CreateIfNotExist Mode = iota
+	// CreateWithoutCheck fails with a constraint error when the platform is already bound.
 	CreateWithoutCheck
 	LoginIfExist
 )
 
 type options struct {
 	mode                 Mode
-	throwOnNotFound      bool // 是否在登录时抛出用户不存在的错误
-	ignorePlatformIDCase bool // 是否忽略平台ID的大小写
+	throwOnNotFound      bool   // 是否在登录时抛出用户不存在的错误
+	ignorePlatformIDCase bool   // 是否忽略平台ID的大小写
+	unionID              string // 微信 unionid, 用于关联同一开放平台下的其他应用
+	appID                string // 微信应用的 AppID
 	beforeCreate         BeforeCreateFunc
 	afterCreate          AfterCreateFunc
 	onCreateUser         func(user *ent.UserCreate) *ent.UserCreate
@@ -79,6 +118,22 @@ func IgnorePlatformIDCase() Option {
 	}
 }
 
+// MatchUnionID resolves a WeChat platform id seen for the first time to the user who logged in with
+// another WeChat app sharing the unionid, the platform is then added to that user instead of creating one.
+func MatchUnionID(unionID string) Option {
+	return func(opts *options) {
+		opts.unionID = unionID
+	}
+}
+
+// WithAppID records the WeChat app the platform id belongs to, platforms created before
+// the app id was recorded get it on their next login.
+func WithAppID(appID string) Option {
+	return func(opts *options) {
+		opts.appID = appID
+	}
+}
+
 func WithOnCreateUser(f func(user *ent.UserCreate) *ent.UserCreate) Option {
 	return func(opts *options) {
 		opts.onCreateUser = f
@@ -125,6 +180,61 @@ func login(ctx context.Context, client *ent.Client, platformID string, platformT
 	if err != nil {
 		return nil, err // 平台存在用户不存在的话是不可能的
 	}
+	if (opt.unionID != "" && userPlat.SecondID == "") || (opt.appID != "" && userPlat.AppID == "") {
+		update := client.UserPlatform.UpdateOne(userPlat)
+		// 应用绑定开放平台之前登录的用户没有 unionid
+		if opt.unionID != "" && userPlat.SecondID == "" {
+			update = update.SetSecondID(opt.unionID)
+		}
+		if opt.appID != "" && userPlat.AppID == "" {
+			update = update.SetAppID(opt.appID)
+		}
+		userPlat, err = update.Save(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Response{
+		User:     oldUser,
+		Platform: userPlat,
+	}, nil
+}
+
+func loginByUnionID(ctx context.Context, client *ent.Client, platformID string, platformType userplatform.Platform, opt *options) (*Response, error) {
+	if opt.unionID == "" {
+		return nil, nil
+	}
+	linked, err := client.UserPlatform.Query().
+		Where(
+			userplatform.PlatformIn(WechatPlatforms...),
+			userplatform.SecondIDEQ(opt.unionID),
+		).
+		Order(userplatform.ByID()).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	oldUser, err := client.User.Get(ctx, linked.UserID)
+	if err != nil {
+		return nil, err
+	}
+	userPlatCreate := client.UserPlatform.Create().
+		SetUserID(oldUser.ID).
+		SetPlatform(platformType).
+		SetPlatformID(platformID)
+	if opt.onCreatePlatform != nil {
+		userPlatCreate = opt.onCreatePlatform(userPlatCreate)
+	}
+	if opt.appID != "" {
+		userPlatCreate = userPlatCreate.SetAppID(opt.appID)
+	}
+	userPlat, err := userPlatCreate.SetSecondID(opt.unionID).Save(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return &Response{
 		User:     oldUser,
 		Platform: userPlat,
@@ -153,6 +263,9 @@ func create(ctx context.Context, client *ent.Client, platformID string, platform
 	if opt.onCreatePlatform != nil {
 		userPlatCreate = opt.onCreatePlatform(userPlatCreate)
 	}
+	if opt.appID != "" {
+		userPlatCreate = userPlatCreate.SetAppID(opt.appID)
+	}
 	userPlat, err := userPlatCreate.Save(ctx)
 	if err != nil {
 		return nil, err
@@ -174,7 +287,7 @@ func Auth(ctx context.Context, db *dao.Dao, platformID string, platformType user
 	for _, o := range options {
 		o(opt)
 	}
-	return dao.WithTx[Response](ctx, db.Client, func(ctx context.Context, client *ent.Client) (*Response, error) {
+	resp, err := dao.WithTx[Response](ctx, db.Client, func(ctx context.Context, client *ent.Client) (*Response, error) {
 		switch opt.mode {
 		case CreateIfNotExist:
 			resp, err := login(ctx, client, platformID, platformType, opt)
@@ -184,6 +297,13 @@ func Auth(ctx context.Context, db *dao.Dao, platformID string, platformType user
 			if resp != nil {
 				return resp, nil
 			}
+			resp, err = loginByUnionID(ctx, client, platformID, platformType, opt)
+			if err != nil {
+				return nil, err
+			}
+			if resp != nil {
+				return resp, nil
+			}
 			return create(ctx, client, platformID, platformType, opt)
 		case CreateWithoutCheck:
 			return create(ctx, client, platformID, platformType, opt)
@@ -194,4 +314,19 @@ func Auth(ctx context.Context, db *dao.Dao, platformID string, platformType user
 			return nil, errors.New("unsupported auth mode")
 		}
 	})
+	if err != nil && opt.mode == CreateIfNotExist && ent.IsConstraintError(err) {
+		return loginAfterConflict(ctx, db.Client, err, platformID, platformType, opt)
+	}
+	return resp, err
+}
+
+// loginAfterConflict logs in the user of a platform created by a concurrent request,
+// the constraint error is returned when the platform still cannot be found.
+func loginAfterConflict(ctx context.Context, client *ent.Client, conflict error, platformID string, platformType userplatform.Platform, opt *options) (*Response, error) {
+	opt.throwOnNotFound = true
+	resp, err := login(ctx, client, platformID, platformType, opt)
+	if err != nil {
+		return nil, conflict
+	}
+	return resp, nil
 }
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/client"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+)
+
+func newTestDao(t *testing.T) *dao.Dao {
+	t.Helper()
+
+	db, err := client.NewDataBaseClient(client.Config{
+		Type: "sqlite3",
+		Path: fmt.Sprintf("file:auth-test-%d?mode=memory&cache=shared", time.Now().UnixNano()),
+	})
+	if err != nil {
+		t.Fatalf("create test database failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return dao.NewDao(db)
+}
+
+func withTestUsername(name string) Option {
+	return WithOnCreateUser(func(user *ent.UserCreate) *ent.UserCreate {
+		return user.SetUsername(name)
+	})
+}
+
+func TestAuthCreateIfNotExist(t *testing.T) {
+	db := newTestDao(t)
+	ctx := context.Background()
+
+	first, err := Auth(ctx, db, "+8613800138000", userplatform.PlatformPhone, withTestUsername("alice"))
+	if err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	if !first.IsNew {
+		t.Fatal("expected the first login to create the user")
+	}
+	second, err := Auth(ctx, db, "+8613800138000", userplatform.PlatformPhone, withTestUsername("bob"))
+	if err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	if second.IsNew || second.User.ID != first.User.ID {
+		t.Fatalf("expected user %d to log in, got %+v", first.User.ID, second)
+	}
+
+	_, err = Auth(ctx, db, "+8613800138000", userplatform.PlatformPhone, WithAuthMode(CreateWithoutCheck), withTestUsername("carol"))
+	if !ent.IsConstraintError(err) {
+		t.Fatalf("expected CreateWithoutCheck to fail on a bound platform, got %v", err)
+	}
+}
+
+func TestAuthRetryOnConflict(t *testing.T) {
+	db := newTestDao(t)
+	ctx := context.Background()
+	conflict := &ent.ConstraintError{}
+
+	// 并发请求没有留下平台时返回原始的约束错误
+	_, err := Auth(ctx, db, "+8613800138000", userplatform.PlatformPhone,
+		withTestUsername("alice"),
+		WithAfterCreate(func(ctx context.Context, client *ent.Client, user *ent.User, platform *ent.UserPlatform) error {
+			return conflict
+		}),
+	)
+	if !errors.Is(err, conflict) {
+		t.Fatalf("expected the constraint error, got %v", err)
+	}
+	if n := db.UserPlatform.Query().CountX(ctx); n != 0 {
+		t.Fatalf("expected the transaction to be rolled back, got %d platforms", n)
+	}
+
+	// 并发请求创建的平台在重试时登录
+	created, err := Auth(ctx, db, "+8613800138000", userplatform.PlatformPhone, withTestUsername("bob"))
+	if err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	resp, err := loginAfterConflict(ctx, db.Client, conflict, "+8613800138000", userplatform.PlatformPhone, newOptions())
+	if err != nil {
+		t.Fatalf("loginAfterConflict failed: %v", err)
+	}
+	if resp.IsNew || resp.User.ID != created.User.ID {
+		t.Fatalf("expected user %d to log in, got %+v", created.User.ID, resp)
+	}
+}
+
+func TestAuthMatchUnionID(t *testing.T) {
+	db := newTestDao(t)
+	ctx := context.Background()
+
+	mini, err := Auth(ctx, db, "openid-mini", userplatform.PlatformWechatMini,
+		withTestUsername("alice"), MatchUnionID("union"), WithAppID("wx-mini"))
+	if err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	if !mini.IsNew || mini.Platform.AppID != "wx-mini" || mini.Platform.SecondID != "union" {
+		t.Fatalf("expected a new user with app id and unionid, got %+v", mini.Platform)
+	}
+
+	official, err := Auth(ctx, db, "openid-oa", userplatform.PlatformWechatOa,
+		withTestUsername("bob"), MatchUnionID("union"), WithAppID("wx-oa"))
+	if err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	if official.IsNew || official.User.ID != mini.User.ID {
+		t.Fatalf("expected the unionid to resolve to user %d, got %+v", mini.User.ID, official)
+	}
+	if official.Platform.Platform != userplatform.PlatformWechatOa || official.Platform.PlatformID != "openid-oa" ||
+		official.Platform.AppID != "wx-oa" || official.Platform.SecondID != "union" {
+		t.Fatalf("expected a platform of the official account, got %+v", official.Platform)
+	}
+
+	other, err := Auth(ctx, db, "openid-other", userplatform.PlatformWechatMini,
+		withTestUsername("carol"), MatchUnionID("other"), WithAppID("wx-mini"))
+	if err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	if !other.IsNew {
+		t.Fatal("expected another unionid to create a user")
+	}
+	if n := db.UserPlatform.Query().Where(userplatform.UserIDEQ(mini.User.ID)).CountX(ctx); n != 2 {
+		t.Fatalf("expected user %d to own both apps, got %d platforms", mini.User.ID, n)
+	}
+}
+
+func TestAuthBackfillsWechatIDs(t *testing.T) {
+	db := newTestDao(t)
+	ctx := context.Background()
+
+	// 记录 AppID 和绑定开放平台之前创建的平台
+	legacy, err := Auth(ctx, db, "openid", userplatform.PlatformWechatMini, withTestUsername("alice"))
+	if err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	if legacy.Platform.AppID != "" || legacy.Platform.SecondID != "" {
+		t.Fatalf("expected a platform without app id and unionid, got %+v", legacy.Platform)
+	}
+	res, err := Auth(ctx, db, "openid", userplatform.PlatformWechatMini,
+		withTestUsername("bob"), MatchUnionID("union"), WithAppID("wx-mini"))
+	if err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	if res.IsNew || res.Platform.ID != legacy.Platform.ID || res.Platform.AppID != "wx-mini" || res.Platform.SecondID != "union" {
+		t.Fatalf("expected the platform to get the app id and unionid, got %+v", res.Platform)
+	}
+}
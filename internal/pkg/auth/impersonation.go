@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
+)
+
+const (
+	ImpersonationTokenValidDuration = time.Minute * 30
+
+	impersonatorRolePrefix = "impersonator:"
+)
+
+// RenderImpersonationClaims builds the claims of an app token issued to an admin acting as user.
+// The impersonator is recorded in the roles, which app tokens do not use otherwise.
+func RenderImpersonationClaims(user *ent.User, impersonator int64, duration time.Duration) jwtauth.RBACClaims[int64] {
+	return jwtauth.NewRBACClaims(
+		user.ID,
+		"impersonation:"+strconv.FormatInt(impersonator, 10),
+		[]string{impersonatorRolePrefix + strconv.FormatInt(impersonator, 10)},
+		time.Now().Add(duration),
+	)
+}
+
+// Impersonator returns the admin ID of an impersonation token.
+func Impersonator(claims *jwtauth.RBACClaims[int64]) (int64, bool) {
+	if claims == nil {
+		return 0, false
+	}
+	for _, role := range claims.Roles {
+		if raw, ok := strings.CutPrefix(role, impersonatorRolePrefix); ok {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return id, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,295 @@
+// Package oauth exchanges authorization codes of third party login providers for a stable subject.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc"
+)
+
+var (
+	ErrUnknownKind    = errors.New("oauth: unknown provider kind")
+	ErrExchangeFailed = errors.New("oauth: code exchange failed")
+)
+
+const (
+	KindOAuth2 = "oauth2"
+	KindOIDC   = "oidc"
+	KindGitHub = "github"
+	KindGoogle = "google"
+	KindApple  = "apple"
+)
+
+// Identity is the user as reported by the provider. Subject is stable for the user and the client.
+type Identity struct {
+	Subject string
+	Email   string
+	Name    string
+	Avatar  string
+}
+
+type Provider interface {
+	Name() string
+	// Exchange trades the authorization code the client received for the identity of the user.
+	// verifier is the PKCE code verifier and nonce the nonce of the authorization request, both may be empty.
+	Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error)
+}
+
+// ProviderConfig configures a provider. github, google and apple only need the client settings,
+// oauth2 reads the identity from a userinfo endpoint and oidc from a verified ID token.
+type ProviderConfig struct {
+	Kind         string `json:"kind" yaml:"kind"`
+	ClientID     string `json:"client_id" yaml:"client_id"`
+	ClientSecret string `json:"client_secret" yaml:"client_secret"`
+	RedirectURL  string `json:"redirect_url" yaml:"redirect_url"`
+
+	TokenURL     string `json:"token_url" yaml:"token_url"`
+	UserInfoURL  string `json:"user_info_url" yaml:"user_info_url"`
+	SubjectClaim string `json:"subject_claim" yaml:"subject_claim"`
+	EmailClaim   string `json:"email_claim" yaml:"email_claim"`
+	NameClaim    string `json:"name_claim" yaml:"name_claim"`
+	AvatarClaim  string `json:"avatar_claim" yaml:"avatar_claim"`
+
+	Issuer string `json:"issuer" yaml:"issuer"`
+}
+
+func (c ProviderConfig) withDefaults() ProviderConfig {
+	defaults := ProviderConfig{}
+	switch c.Kind {
+	case KindGitHub:
+		defaults = ProviderConfig{
+			Kind:         KindOAuth2,
+			TokenURL:     "https://github.com/login/oauth/access_token",
+			UserInfoURL:  "https://api.github.com/user",
+			SubjectClaim: "id",
+			EmailClaim:   "email",
+			NameClaim:    "name",
+			AvatarClaim:  "avatar_url",
+		}
+	case KindGoogle:
+		defaults = ProviderConfig{Kind: KindOIDC, Issuer: "https://accounts.google.com"}
+	case KindApple:
+		defaults = ProviderConfig{Kind: KindOIDC, Issuer: "https://appleid.apple.com"}
+	case KindOAuth2, KindOIDC:
+		defaults = ProviderConfig{Kind: c.Kind, SubjectClaim: "sub", EmailClaim: "email", NameClaim: "name", AvatarClaim: "picture"}
+	default:
+		return c
+	}
+	c.Kind = defaults.Kind
+	setDefault(&c.TokenURL, defaults.TokenURL)
+	setDefault(&c.UserInfoURL, defaults.UserInfoURL)
+	setDefault(&c.SubjectClaim, defaults.SubjectClaim)
+	setDefault(&c.EmailClaim, defaults.EmailClaim)
+	setDefault(&c.NameClaim, defaults.NameClaim)
+	setDefault(&c.AvatarClaim, defaults.AvatarClaim)
+	setDefault(&c.Issuer, defaults.Issuer)
+	return c
+}
+
+func setDefault(value *string, fallback string) {
+	if *value == "" {
+		*value = fallback
+	}
+}
+
+func NewProvider(name string, config ProviderConfig, client *http.Client) (Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	config = config.withDefaults()
+	switch config.Kind {
+	case KindOAuth2:
+		return &oauth2Provider{name: name, config: config, client: client}, nil
+	case KindOIDC:
+		provider := oidc.NewProvider(oidc.Config{
+			Issuer:       config.Issuer,
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+		}, client)
+		return &oidcProvider{name: name, provider: provider}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKind, config.Kind)
+	}
+}
+
+// Registry holds the enabled providers by name.
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider)}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// NewRegistryFromConfig creates a provider for every entry, the key is used as provider name.
+func NewRegistryFromConfig(configs map[string]ProviderConfig, client *http.Client) (*Registry, error) {
+	r := NewRegistry()
+	for name, config := range configs {
+		provider, err := NewProvider(name, config, client)
+		if err != nil {
+			return nil, fmt.Errorf("oauth provider %s: %w", name, err)
+		}
+		r.Register(provider)
+	}
+	return r, nil
+}
+
+func (r *Registry) Register(provider Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[provider.Name()] = provider
+}
+
+func (r *Registry) Get(name string) (Provider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+type oauth2Provider struct {
+	name   string
+	config ProviderConfig
+	client *http.Client
+}
+
+func (p *oauth2Provider) Name() string {
+	return p.name
+}
+
+func (p *oauth2Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("client_id", p.config.ClientID)
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+	if p.config.RedirectURL != "" {
+		form.Set("redirect_uri", p.config.RedirectURL)
+	}
+	if verifier != "" {
+		form.Set("code_verifier", verifier)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &token)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || token.Error != "" || token.AccessToken == "" {
+		return nil, fmt.Errorf("%w: %s %s", ErrExchangeFailed, token.Error, token.ErrorDescription)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, p.config.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	claims := make(map[string]any)
+	status, err = p.doJSON(req, &claims)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %d from user info", ErrExchangeFailed, status)
+	}
+	identity := &Identity{
+		Subject: claimString(claims, p.config.SubjectClaim),
+		Email:   claimString(claims, p.config.EmailClaim),
+		Name:    claimString(claims, p.config.NameClaim),
+		Avatar:  claimString(claims, p.config.AvatarClaim),
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrExchangeFailed)
+	}
+	return identity, nil
+}
+
+func (p *oauth2Provider) doJSON(req *http.Request, value any) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(io.LimitReader(resp.Body, 1<<20))
+	decoder.UseNumber()
+	if err = decoder.Decode(value); err != nil {
+		return resp.StatusCode, fmt.Errorf("%w: decode response: %w", ErrExchangeFailed, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// claimString returns string and numeric claims as string, numeric ids are common for OAuth2 providers.
+func claimString(claims map[string]any, name string) string {
+	if name == "" {
+		return ""
+	}
+	switch value := claims[name].(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	default:
+		return ""
+	}
+}
+
+type oidcProvider struct {
+	name     string
+	provider *oidc.Provider
+}
+
+func (p *oidcProvider) Name() string {
+	return p.name
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	token, err := p.provider.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		return nil, errors.Join(ErrExchangeFailed, err)
+	}
+	return &Identity{
+		Subject: token.Subject,
+		Email:   token.String("email"),
+		Name:    token.String("name"),
+		Avatar:  token.String("picture"),
+	}, nil
+}
@@ -0,0 +1,140 @@
+package oauth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth/oauth"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth/oauth/oauthtest"
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc"
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc/oidctest"
+)
+
+func TestOAuth2Provider(t *testing.T) {
+	server := oauthtest.NewServer("client", "secret")
+	defer server.Close()
+
+	provider, err := oauth.NewProvider("github", oauth.ProviderConfig{
+		Kind:         oauth.KindGitHub,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		TokenURL:     server.TokenURL(),
+		UserInfoURL:  server.UserInfoURL(),
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	ctx := context.Background()
+
+	verifier := oidc.NewState()
+	code := server.Authorize(map[string]any{
+		"id":         9007199254740993,
+		"name":       "Octocat",
+		"avatar_url": "https://example.com/a.png",
+	}, oidc.CodeChallengeS256(verifier))
+	if _, err = provider.Exchange(ctx, code, oidc.NewState(), ""); !errors.Is(err, oauth.ErrExchangeFailed) {
+		t.Fatalf("expected exchange with wrong verifier to fail, got %v", err)
+	}
+
+	code = server.Authorize(map[string]any{
+		"id":         9007199254740993,
+		"name":       "Octocat",
+		"avatar_url": "https://example.com/a.png",
+	}, oidc.CodeChallengeS256(verifier))
+	identity, err := provider.Exchange(ctx, code, verifier, "")
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if identity.Subject != "9007199254740993" {
+		t.Fatalf("expected numeric subject to be kept exactly, got %q", identity.Subject)
+	}
+	if identity.Name != "Octocat" || identity.Avatar != "https://example.com/a.png" {
+		t.Fatalf("unexpected identity: %+v", identity)
+	}
+
+	if _, err = provider.Exchange(ctx, code, verifier, ""); !errors.Is(err, oauth.ErrExchangeFailed) {
+		t.Fatalf("expected a used code to be rejected, got %v", err)
+	}
+}
+
+func TestOAuth2ProviderMissingSubject(t *testing.T) {
+	server := oauthtest.NewServer("client", "secret")
+	defer server.Close()
+
+	provider, err := oauth.NewProvider("custom", oauth.ProviderConfig{
+		Kind:         oauth.KindOAuth2,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		TokenURL:     server.TokenURL(),
+		UserInfoURL:  server.UserInfoURL(),
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	code := server.Authorize(map[string]any{"name": "nobody"}, "")
+	if _, err = provider.Exchange(context.Background(), code, "", ""); !errors.Is(err, oauth.ErrExchangeFailed) {
+		t.Fatalf("expected missing subject to fail, got %v", err)
+	}
+}
+
+func TestOIDCProvider(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+
+	provider, err := oauth.NewProvider("apple", oauth.ProviderConfig{
+		Kind:        oauth.KindApple,
+		ClientID:    "app",
+		RedirectURL: "http://localhost/callback",
+		Issuer:      idp.Issuer(),
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	ctx := context.Background()
+
+	// 客户端直接向身份提供方发起授权, 服务端只负责交换授权码
+	rp := oidc.NewProvider(oidc.Config{Issuer: idp.Issuer(), ClientID: "app", RedirectURL: "http://localhost/callback"}, nil)
+	verifier, nonce := oidc.NewState(), oidc.NewState()
+	authURL, err := rp.AuthCodeURL(ctx, oidc.NewState(), nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	code, _, err := idp.Authorize(authURL, map[string]any{"sub": "apple-user", "email": "user@example.com"})
+	if err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	identity, err := provider.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if identity.Subject != "apple-user" || identity.Email != "user@example.com" {
+		t.Fatalf("unexpected identity: %+v", identity)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry, err := oauth.NewRegistryFromConfig(map[string]oauth.ProviderConfig{
+		"google": {Kind: oauth.KindGoogle, ClientID: "client"},
+		"github": {Kind: oauth.KindGitHub, ClientID: "client"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewRegistryFromConfig failed: %v", err)
+	}
+	if names := registry.Names(); len(names) != 2 || names[0] != "github" || names[1] != "google" {
+		t.Fatalf("unexpected names: %v", names)
+	}
+	if provider, ok := registry.Get("github"); !ok || provider.Name() != "github" {
+		t.Fatal("expected github provider")
+	}
+	if _, ok := registry.Get("gitlab"); ok {
+		t.Fatal("expected unknown provider to be missing")
+	}
+
+	_, err = oauth.NewRegistryFromConfig(map[string]oauth.ProviderConfig{
+		"custom": {Kind: "saml"},
+	}, nil)
+	if !errors.Is(err, oauth.ErrUnknownKind) {
+		t.Fatalf("expected ErrUnknownKind, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+// Package oauthtest provides a local stand-in OAuth2 provider with token and userinfo endpoints for tests.
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+type pendingCode struct {
+	challenge string
+	userInfo  map[string]any
+}
+
+// Server issues codes through Authorize instead of an interactive login page. A code can be
+// exchanged once, with PKCE checked when a challenge was given.
+type Server struct {
+	server       *httptest.Server
+	clientID     string
+	clientSecret string
+
+	mu     sync.Mutex
+	codes  map[string]pendingCode
+	tokens map[string]map[string]any
+}
+
+func NewServer(clientID, clientSecret string) *Server {
+	s := &Server{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		codes:        make(map[string]pendingCode),
+		tokens:       make(map[string]map[string]any),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", s.handleToken)
+	mux.HandleFunc("GET /userinfo", s.handleUserInfo)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Server) TokenURL() string {
+	return s.server.URL + "/token"
+}
+
+func (s *Server) UserInfoURL() string {
+	return s.server.URL + "/userinfo"
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Authorize returns a code which is exchanged for a token returning userInfo from the userinfo endpoint.
+// challenge is the S256 PKCE code challenge and may be empty.
+func (s *Server) Authorize(userInfo map[string]any, challenge string) string {
+	code := randomString()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.codes[code] = pendingCode{challenge: challenge, userInfo: userInfo}
+	return code
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("client_id") != s.clientID || r.PostForm.Get("client_secret") != s.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	code := r.PostForm.Get("code")
+	s.mu.Lock()
+	pending, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if pending.challenge != "" {
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != pending.challenge {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "pkce verification failed"})
+			return
+		}
+	}
+	token := randomString()
+	s.mu.Lock()
+	s.tokens[token] = pending.userInfo
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": token,
+		"token_type":   "bearer",
+	})
+}
+
+func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	userInfo, found := s.tokens[token]
+	s.mu.Unlock()
+	if !ok || !found {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Bad credentials"})
+		return
+	}
+	writeJSON(w, http.StatusOK, userInfo)
+}
+
+func randomString() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
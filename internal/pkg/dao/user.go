@@ -2,11 +2,15 @@ package dao
 
 import (
 	"context"
+	"errors"
+	"slices"
 
 	"github.com/go-sphere/sphere-layout/internal/pkg/conv"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/user"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userflagrecord"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/usersession"
 )
 
 func (d *Dao) GetUsers(ctx context.Context, ids []int64) (map[int64]*ent.User, error) {
@@ -32,3 +36,216 @@ func (d *Dao) GetUserPlatforms(ctx context.Context, ids []int64) (map[int64][]*e
 	}
 	return userPlatformMap, nil
 }
+
+// MergeUser moves the data owned by source to target and deletes source. The tables keyed by a user are:
+//   - user_platforms: moved to target.
+//   - user_flag_records: moved to target unless target already has a record of the flag.
+//   - user_sessions: the uid is immutable, so the sessions stay with source as its login history.
+//     They are revoked and their ids are returned so that the access tokens issued for them can be denied as well.
+//
+// The uid of admin_sessions, admin_api_keys and audit_logs is an admin, audit logs about source are kept as history.
+// A table keyed by a user added to the schema must be handled here, see TestMergeUserCoversUserTables.
+func MergeUser(ctx context.Context, client *ent.Client, target *ent.User, source *ent.User) ([]int64, error) {
+	err := client.UserPlatform.Update().
+		Where(userplatform.UserIDEQ(source.ID)).
+		SetUserID(target.ID).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := client.UserFlagRecord.Query().Where(userflagrecord.UserIDEQ(source.ID)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		exist, qErr := client.UserFlagRecord.Query().
+			Where(userflagrecord.UserIDEQ(target.ID), userflagrecord.FlagEQ(record.Flag)).
+			Exist(ctx)
+		if qErr != nil {
+			return nil, qErr
+		}
+		if exist {
+			continue
+		}
+		err = client.UserFlagRecord.Create().
+			SetUserID(target.ID).
+			SetFlag(record.Flag).
+			SetReason(record.Reason).
+			SetExpiresAt(record.ExpiresAt).
+			SetOperatorID(record.OperatorID).
+			Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = client.UserFlagRecord.Delete().Where(userflagrecord.UserIDEQ(source.ID)).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	update := client.User.UpdateOne(target).SetFlags(target.Flags | source.Flags)
+	if target.Nickname == "" && source.Nickname != "" {
+		update = update.SetNickname(source.Nickname)
+	}
+	if target.Avatar == "" && source.Avatar != "" {
+		update = update.SetAvatar(source.Avatar)
+	}
+	if err = update.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	sessions, err := client.UserSession.Query().
+		Where(usersession.UIDEQ(source.ID), usersession.IsRevokedEQ(false)).
+		IDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) > 0 {
+		err = client.UserSession.Update().
+			Where(usersession.IDIn(sessions...)).
+			SetIsRevoked(true).
+			Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = client.User.DeleteOneID(source.ID).Exec(ctx); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// PlatformMerge reports how the users sharing one platform were merged.
+type PlatformMerge struct {
+	Platform   userplatform.Platform
+	PlatformID string
+	Target     int64
+	Merged     []int64
+	// Skipped are the users which own another platform of the same kind as the target and need a manual decision.
+	Skipped []int64
+}
+
+var errDryRun = errors.New("dao: dry run")
+
+// MergeDuplicatePlatforms finds the platforms bound more than once, which was possible before
+// (platform, platform_id) became unique, and merges their users into the oldest one. With dryRun
+// the merges are only reported. Revoked sessions are returned for the caller to deny.
+func MergeDuplicatePlatforms(ctx context.Context, client *ent.Client, dryRun bool) ([]*PlatformMerge, []int64, error) {
+	var groups []struct {
+		Platform   userplatform.Platform `json:"platform"`
+		PlatformID string                `json:"platform_id"`
+		Count      int                   `json:"count"`
+	}
+	err := client.UserPlatform.Query().
+		GroupBy(userplatform.FieldPlatform, userplatform.FieldPlatformID).
+		Aggregate(ent.Count()).
+		Scan(ctx, &groups)
+	if err != nil {
+		return nil, nil, err
+	}
+	var merges []*PlatformMerge
+	var sessions []int64
+	err = WithTxEx(ctx, client, func(ctx context.Context, client *ent.Client) error {
+		for _, group := range groups {
+			if group.Count < 2 {
+				continue
+			}
+			merge, revoked, mErr := mergeDuplicatePlatform(ctx, client, group.Platform, group.PlatformID)
+			if mErr != nil {
+				return mErr
+			}
+			merges = append(merges, merge)
+			sessions = append(sessions, revoked...)
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, nil, err
+	}
+	return merges, sessions, nil
+}
+
+func mergeDuplicatePlatform(ctx context.Context, client *ent.Client, platform userplatform.Platform, platformID string) (*PlatformMerge, []int64, error) {
+	duplicates, err := client.UserPlatform.Query().
+		Where(userplatform.PlatformEQ(platform), userplatform.PlatformIDEQ(platformID)).
+		Order(userplatform.ByID()).
+		All(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	merge := &PlatformMerge{
+		Platform:   platform,
+		PlatformID: platformID,
+		Target:     duplicates[0].UserID,
+	}
+	var sessions []int64
+	for _, duplicate := range duplicates[1:] {
+		if duplicate.UserID == merge.Target {
+			if err = client.UserPlatform.DeleteOne(duplicate).Exec(ctx); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if slices.Contains(merge.Merged, duplicate.UserID) || slices.Contains(merge.Skipped, duplicate.UserID) {
+			continue
+		}
+		target, err := client.User.Get(ctx, merge.Target)
+		if err != nil {
+			return nil, nil, err
+		}
+		source, err := client.User.Get(ctx, duplicate.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		conflict, err := hasPlatformConflict(ctx, client, target.ID, source.ID, platform, platformID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if conflict {
+			merge.Skipped = append(merge.Skipped, source.ID)
+			continue
+		}
+		_, err = client.UserPlatform.Delete().
+			Where(
+				userplatform.UserIDEQ(source.ID),
+				userplatform.PlatformEQ(platform),
+				userplatform.PlatformIDEQ(platformID),
+			).
+			Exec(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		revoked, err := MergeUser(ctx, client, target, source)
+		if err != nil {
+			return nil, nil, err
+		}
+		merge.Merged = append(merge.Merged, source.ID)
+		sessions = append(sessions, revoked...)
+	}
+	return merge, sessions, nil
+}
+
+// hasPlatformConflict reports whether source owns a platform of a kind target already has, apart from the duplicated one.
+// WeChat platforms are bound once for every app, so the kind includes the app id.
+func hasPlatformConflict(ctx context.Context, client *ent.Client, target, source int64, platform userplatform.Platform, platformID string) (bool, error) {
+	owned, err := client.UserPlatform.Query().Where(userplatform.UserIDIn(target, source)).All(ctx)
+	if err != nil {
+		return false, err
+	}
+	kinds := make(map[string]int64)
+	for _, p := range owned {
+		if p.Platform == platform && p.PlatformID == platformID {
+			continue
+		}
+		kind := string(p.Platform) + ":" + p.AppID
+		if owner, ok := kinds[kind]; ok && owner != p.UserID {
+			return true, nil
+		}
+		kinds[kind] = p.UserID
+	}
+	return false, nil
+}
@@ -0,0 +1,167 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/client"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/migrate"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userflagrecord"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/usersession"
+)
+
+func newTestClient(t *testing.T) *ent.Client {
+	t.Helper()
+
+	db, err := client.NewDataBaseClient(client.Config{
+		Type: "sqlite3",
+		Path: fmt.Sprintf("file:dao-test-%d?mode=memory&cache=shared", time.Now().UnixNano()),
+	})
+	if err != nil {
+		t.Fatalf("create test database failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// TestMergeUserCoversUserTables fails when a table keyed by a user is added without being handled by MergeUser.
+func TestMergeUserCoversUserTables(t *testing.T) {
+	handled := []string{"user_platforms", "user_flag_records", "user_sessions"}
+	adminTables := []string{"admin_sessions", "admin_api_keys", "audit_logs"}
+	for _, table := range migrate.Tables {
+		for _, column := range table.Columns {
+			if column.Name != "uid" && column.Name != "user_id" {
+				continue
+			}
+			if !slices.Contains(handled, table.Name) && !slices.Contains(adminTables, table.Name) {
+				t.Errorf("table %s is keyed by %s but not handled by MergeUser", table.Name, column.Name)
+			}
+		}
+	}
+}
+
+func TestMergeUser(t *testing.T) {
+	db := newTestClient(t)
+	ctx := context.Background()
+
+	target := db.User.Create().SetUsername("target").SetFlags(1).SaveX(ctx)
+	source := db.User.Create().SetUsername("source").SetNickname("Source").SetFlags(2).SaveX(ctx)
+	db.UserPlatform.Create().SetUserID(target.ID).SetPlatform(userplatform.PlatformEmail).SetPlatformID("a@example.com").SaveX(ctx)
+	db.UserPlatform.Create().SetUserID(source.ID).SetPlatform(userplatform.PlatformPhone).SetPlatformID("+8613800138000").SaveX(ctx)
+	db.UserFlagRecord.Create().SetUserID(target.ID).SetFlag("banned").SetReason("target").SaveX(ctx)
+	db.UserFlagRecord.Create().SetUserID(source.ID).SetFlag("banned").SetReason("source").SaveX(ctx)
+	db.UserFlagRecord.Create().SetUserID(source.ID).SetFlag("muted").SetReason("source").SaveX(ctx)
+	session := db.UserSession.Create().SetUID(source.ID).SetSessionKey("source-session").SaveX(ctx)
+	db.UserSession.Create().SetUID(source.ID).SetSessionKey("revoked-session").SetIsRevoked(true).SaveX(ctx)
+
+	sessions, err := MergeUser(ctx, db, target, source)
+	if err != nil {
+		t.Fatalf("MergeUser failed: %v", err)
+	}
+	if !slices.Equal(sessions, []int64{session.ID}) {
+		t.Fatalf("expected active session %d to be revoked, got %v", session.ID, sessions)
+	}
+
+	if n := db.UserPlatform.Query().Where(userplatform.UserIDEQ(target.ID)).CountX(ctx); n != 2 {
+		t.Fatalf("expected target to own 2 platforms, got %d", n)
+	}
+	records := db.UserFlagRecord.Query().Where(userflagrecord.UserIDEQ(target.ID)).Order(userflagrecord.ByFlag()).AllX(ctx)
+	if len(records) != 2 || records[0].Flag != "banned" || records[0].Reason != "target" || records[1].Flag != "muted" {
+		t.Fatalf("expected target to keep its record and take the missing one, got %v", records)
+	}
+	if n := db.UserFlagRecord.Query().Where(userflagrecord.UserIDEQ(source.ID)).CountX(ctx); n != 0 {
+		t.Fatalf("expected no flag record left on source, got %d", n)
+	}
+	if db.UserSession.Query().Where(usersession.UIDEQ(source.ID), usersession.IsRevokedEQ(false)).ExistX(ctx) {
+		t.Fatal("expected the sessions of source to be revoked")
+	}
+
+	merged := db.User.GetX(ctx, target.ID)
+	if merged.Flags != 3 || merged.Nickname != "Source" {
+		t.Fatalf("expected flags and nickname to be merged, got %+v", merged)
+	}
+	if _, err = db.User.Get(ctx, source.ID); !ent.IsNotFound(err) {
+		t.Fatalf("expected source to be deleted, got %v", err)
+	}
+}
+
+// newTestClientWithDuplicates drops the unique platform index, as in databases created before it existed.
+func newTestClientWithDuplicates(t *testing.T) *ent.Client {
+	t.Helper()
+
+	db := newTestClient(t)
+	if _, err := db.ExecContext(context.Background(), "DROP INDEX userplatform_platform_platform_id"); err != nil {
+		t.Fatalf("drop unique platform index failed: %v", err)
+	}
+	return db
+}
+
+func TestMergeDuplicatePlatforms(t *testing.T) {
+	db := newTestClientWithDuplicates(t)
+	ctx := context.Background()
+
+	// alice 和 bob 只重复绑定了同一个手机号, bob 合并到最早绑定的 alice
+	alice := db.User.Create().SetUsername("alice").SaveX(ctx)
+	bob := db.User.Create().SetUsername("bob").SaveX(ctx)
+	db.UserPlatform.Create().SetUserID(alice.ID).SetPlatform(userplatform.PlatformPhone).SetPlatformID("+8613800138000").SaveX(ctx)
+	db.UserPlatform.Create().SetUserID(bob.ID).SetPlatform(userplatform.PlatformPhone).SetPlatformID("+8613800138000").SaveX(ctx)
+	db.UserPlatform.Create().SetUserID(bob.ID).SetPlatform(userplatform.PlatformEmail).SetPlatformID("bob@example.com").SaveX(ctx)
+	session := db.UserSession.Create().SetUID(bob.ID).SetSessionKey("bob-session").SaveX(ctx)
+
+	// carol 和 dave 的 github 重复, 但各自还有不同的手机号, 需要人工处理
+	carol := db.User.Create().SetUsername("carol").SaveX(ctx)
+	dave := db.User.Create().SetUsername("dave").SaveX(ctx)
+	db.UserPlatform.Create().SetUserID(carol.ID).SetPlatform(userplatform.PlatformGithub).SetPlatformID("octocat").SaveX(ctx)
+	db.UserPlatform.Create().SetUserID(dave.ID).SetPlatform(userplatform.PlatformGithub).SetPlatformID("octocat").SaveX(ctx)
+	db.UserPlatform.Create().SetUserID(carol.ID).SetPlatform(userplatform.PlatformPhone).SetPlatformID("+8613900139000").SaveX(ctx)
+	db.UserPlatform.Create().SetUserID(dave.ID).SetPlatform(userplatform.PlatformPhone).SetPlatformID("+8613700137000").SaveX(ctx)
+
+	merges, sessions, err := MergeDuplicatePlatforms(ctx, db, true)
+	if err != nil {
+		t.Fatalf("MergeDuplicatePlatforms dry run failed: %v", err)
+	}
+	if len(merges) != 2 || len(sessions) != 1 {
+		t.Fatalf("expected 2 duplicates and 1 session to be reported, got %d and %v", len(merges), sessions)
+	}
+	if _, err = db.User.Get(ctx, bob.ID); err != nil {
+		t.Fatalf("expected the dry run to keep bob, got %v", err)
+	}
+
+	merges, sessions, err = MergeDuplicatePlatforms(ctx, db, false)
+	if err != nil {
+		t.Fatalf("MergeDuplicatePlatforms failed: %v", err)
+	}
+	byPlatform := make(map[userplatform.Platform]*PlatformMerge)
+	for _, m := range merges {
+		byPlatform[m.Platform] = m
+	}
+	phone := byPlatform[userplatform.PlatformPhone]
+	if phone == nil || phone.Target != alice.ID || !slices.Equal(phone.Merged, []int64{bob.ID}) || len(phone.Skipped) != 0 {
+		t.Fatalf("expected bob to be merged into alice, got %+v", phone)
+	}
+	github := byPlatform[userplatform.PlatformGithub]
+	if github == nil || github.Target != carol.ID || len(github.Merged) != 0 || !slices.Equal(github.Skipped, []int64{dave.ID}) {
+		t.Fatalf("expected dave to be skipped, got %+v", github)
+	}
+	if !slices.Equal(sessions, []int64{session.ID}) {
+		t.Fatalf("expected the session of bob to be revoked, got %v", sessions)
+	}
+	if !db.UserSession.GetX(ctx, session.ID).IsRevoked {
+		t.Fatal("expected the session of bob to be revoked in the database")
+	}
+
+	if n := db.UserPlatform.Query().Where(userplatform.PlatformIDEQ("+8613800138000")).CountX(ctx); n != 1 {
+		t.Fatalf("expected the duplicate phone to be removed, got %d", n)
+	}
+	if owner := db.UserPlatform.Query().Where(userplatform.PlatformIDEQ("bob@example.com")).OnlyX(ctx).UserID; owner != alice.ID {
+		t.Fatalf("expected the email of bob to move to alice, got owner %d", owner)
+	}
+	if n := db.UserPlatform.Query().Where(userplatform.PlatformIDEQ("octocat")).CountX(ctx); n != 2 {
+		t.Fatalf("expected the skipped duplicate to be kept, got %d", n)
+	}
+}
@@ -18,6 +18,9 @@ type Config struct {
 	Type  string `json:"type" yaml:"type"`
 	Path  string `json:"path" yaml:"path"`
 	Debug bool   `json:"debug" yaml:"debug"`
+	// DisableAutoMigrate skips the schema migration at startup, which also drops removed columns and indexes.
+	// Production databases should disable it and apply the versioned migrations with cmd/tools/migrate.
+	DisableAutoMigrate bool `json:"disable_auto_migrate" yaml:"disable_auto_migrate"`
 }
 
 func NewDataBaseClient(config Config) (*ent.Client, error) {
@@ -25,13 +28,15 @@ func NewDataBaseClient(config Config) (*ent.Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = client.Schema.Create(
-		context.Background(),
-		migrate.WithDropIndex(true),
-		migrate.WithDropColumn(true),
-	)
-	if err != nil {
-		return nil, err
+	if !config.DisableAutoMigrate {
+		err = client.Schema.Create(
+			context.Background(),
+			migrate.WithDropIndex(true),
+			migrate.WithDropColumn(true),
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
 	if config.Debug {
 		client = client.Debug()
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	atlasmigrate "ariga.io/atlas/sql/migrate"
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"entgo.io/ent/dialect/sql/schema"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/migrate"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/migration"
+)
+
+const migrationRoot = "../migrations"
+
+func newTestDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:migration-test-%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := sql.Open(dialect.SQLite, dsn)
+	if err != nil {
+		t.Fatalf("open test database failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db, dsn
+}
+
+func newTestMigrator(t *testing.T, db *sql.DB) (*migration.Migrator, []*migration.Migration) {
+	t.Helper()
+
+	migrations, err := migration.Load(os.DirFS(path.Join(migrationRoot, dialect.SQLite)))
+	if err != nil {
+		t.Fatalf("load migrations failed: %v", err)
+	}
+	return migration.NewMigrator(db, migrations), migrations
+}
+
+// TestMigrationsMatchSchema fails when the ent schema changes without a migration, see make db/diff.
+func TestMigrationsMatchSchema(t *testing.T) {
+	ctx := context.Background()
+	db, _ := newTestDB(t)
+	migrator, _ := newTestMigrator(t, db)
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("apply migrations failed: %v", err)
+	}
+
+	dir, err := atlasmigrate.NewLocalDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("create migration directory failed: %v", err)
+	}
+	m, err := schema.NewMigrate(
+		entsql.OpenDB(dialect.SQLite, db),
+		schema.WithDir(dir),
+		schema.WithDropIndex(true),
+		schema.WithDropColumn(true),
+	)
+	if err != nil {
+		t.Fatalf("create migrate failed: %v", err)
+	}
+	if err = m.Diff(ctx, migrate.Tables...); err != nil {
+		t.Fatalf("diff failed: %v", err)
+	}
+	files, err := dir.Files()
+	if err != nil {
+		t.Fatalf("read changes failed: %v", err)
+	}
+	for _, file := range files {
+		t.Errorf("migrations differ from the ent schema:\n%s", file.Bytes())
+	}
+}
+
+func TestBaselineAutoMigratedDatabase(t *testing.T) {
+	ctx := context.Background()
+	db, dsn := newTestDB(t)
+	client, err := NewDataBaseClient(Config{Type: dialect.SQLite, Path: dsn})
+	if err != nil {
+		t.Fatalf("auto-migrate failed: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	// 自动迁移创建的数据库直接执行初始迁移会因表已存在而失败
+	migrator, migrations := newTestMigrator(t, db)
+	if _, err = migrator.Up(ctx); err == nil {
+		t.Fatal("expected the initial migration to fail on an auto-migrated database")
+	}
+	marked, err := migrator.Baseline(ctx, migrations[0].Version)
+	if err != nil {
+		t.Fatalf("Baseline failed: %v", err)
+	}
+	if len(marked) != 1 {
+		t.Fatalf("expected the initial migration to be marked, got %d", len(marked))
+	}
+	if _, err = migrator.Up(ctx); err != nil {
+		t.Fatalf("expected the remaining migrations to apply, got %v", err)
+	}
+	revisions, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, r := range revisions {
+		if r.AppliedAt == 0 {
+			t.Errorf("expected %s_%s to be applied", r.Version, r.Name)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+// Package migration applies the versioned migrations generated from the ent schema. The files use the
+// golang-migrate layout, <version>_<name>.up.sql with an optional <version>_<name>.down.sql, and are
+// verified against the atlas.sum checksum file written by the generator.
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidFileName  = errors.New("migration: invalid file name")
+	ErrMissingMigration = errors.New("migration: applied migration not found")
+	ErrNoDownMigration  = errors.New("migration: no down migration")
+	ErrChecksumNotFound = errors.New("migration: checksum file not found")
+	ErrChecksumMismatch = errors.New("migration: checksum mismatch")
+	ErrUnknownVersion   = errors.New("migration: unknown version")
+)
+
+const (
+	// RevisionTable records the applied migrations.
+	RevisionTable = "sphere_schema_migrations"
+	// SumFile is the checksum file of the migration directory, in the format of `atlas migrate hash`.
+	SumFile = "atlas.sum"
+)
+
+type Migration struct {
+	Version string
+	Name    string
+	Up      string
+	Down    string
+	// Hash is recorded when the migration is applied, to detect later changes to the up migration.
+	Hash string
+}
+
+// Revision is the state of a migration, AppliedAt is zero for pending migrations.
+type Revision struct {
+	Version   string
+	Name      string
+	Hash      string
+	AppliedAt int64
+}
+
+// Load reads the migrations in the root of fsys ordered by version, after verifying them against atlas.sum.
+func Load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	sum, err := fs.ReadFile(fsys, SumFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrChecksumNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []sumEntry
+	byVersion := make(map[string]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		base, up := strings.CutSuffix(entry.Name(), ".up.sql")
+		if !up {
+			var down bool
+			if base, down = strings.CutSuffix(entry.Name(), ".down.sql"); !down {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidFileName, entry.Name())
+			}
+		}
+		version, name, _ := strings.Cut(base, "_")
+		if version == "" || strings.Trim(version, "0123456789") != "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFileName, entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sumEntry{name: entry.Name(), content: content})
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if up {
+			m.Up = string(content)
+			hash := sha256.Sum256(content)
+			m.Hash = hex.EncodeToString(hash[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+	if err = verifySum(string(sum), files); err != nil {
+		return nil, err
+	}
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("%w: %s has no up migration", ErrInvalidFileName, m.Version)
+		}
+		migrations = append(migrations, m)
+	}
+	slices.SortFunc(migrations, func(a, b *Migration) int {
+		return compareVersion(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+type sumEntry struct {
+	name    string
+	content []byte
+}
+
+// checksum returns the atlas.sum of the files ordered by name. Each line holds the hash of the names and contents
+// up to and including the file, so that reordering, adding or editing files all change the sum.
+func checksum(files []sumEntry) string {
+	var (
+		h     = sha256.New()
+		lines strings.Builder
+	)
+	for _, file := range files {
+		h.Write([]byte(file.name))
+		h.Write(file.content)
+		lines.WriteString(file.name + " h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + "\n")
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + "\n" + lines.String()
+}
+
+func verifySum(sum string, files []sumEntry) error {
+	want := strings.Split(strings.TrimSpace(checksum(files)), "\n")
+	got := strings.Split(strings.TrimSpace(strings.ReplaceAll(sum, "\r\n", "\n")), "\n")
+	for i := 1; i < len(want); i++ {
+		if i >= len(got) || got[i] != want[i] {
+			name, _, _ := strings.Cut(want[i], " ")
+			return fmt.Errorf("%w: %s was changed without updating %s", ErrChecksumMismatch, name, SumFile)
+		}
+	}
+	if !slices.Equal(got, want) {
+		return fmt.Errorf("%w: %s does not match the migration files", ErrChecksumMismatch, SumFile)
+	}
+	return nil
+}
+
+// compareVersion orders numeric versions of different length, e.g. sequence numbers, correctly.
+func compareVersion(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
+
+// splitStatements splits a migration into statements on the semicolons outside of quotes, comments and the
+// BEGIN ... END bodies of CREATE TRIGGER, PROCEDURE and FUNCTION statements. Comments before a statement
+// are dropped. Quotes inside strings are escaped by doubling them as the migration generator does.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		start      int    // 当前语句的起始位置, 语句前的空白和注释会被跳过
+		started    bool   // 当前语句是否已有内容
+		first      string // 当前语句的第一个关键字
+		depth      int    // BEGIN/CASE ... END 的嵌套层数
+	)
+	for i := 0; i < len(script); {
+		c := script[i]
+		switch {
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end
+			if !started {
+				start = i
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 4
+			}
+			if !started {
+				start = i
+			}
+		case c == '\'' || c == '"' || c == '`':
+			started = true
+			end := strings.IndexByte(script[i+1:], c)
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 2
+			}
+		case isWordByte(c):
+			word := readWord(script, i)
+			i += len(word)
+			if !started {
+				started, first = true, strings.ToUpper(word)
+			}
+			if first != "CREATE" {
+				continue
+			}
+			switch strings.ToUpper(word) {
+			case "BEGIN", "CASE":
+				depth++
+			case "END":
+				// END IF, END LOOP, END WHILE 和 END REPEAT 结束的块没有计入层数, END CASE 的 CASE 不再计入
+				rest := strings.TrimLeft(script[i:], " \t\r\n")
+				switch next := readWord(rest, 0); strings.ToUpper(next) {
+				case "IF", "LOOP", "WHILE", "REPEAT":
+				case "CASE":
+					depth = max(depth-1, 0)
+					i = len(script) - len(rest) + len(next)
+				default:
+					depth = max(depth-1, 0)
+				}
+			}
+		case c == ';' && depth == 0:
+			i++
+			if started {
+				statements = append(statements, strings.TrimSpace(script[start:i]))
+			}
+			start, started, first = i, false, ""
+		default:
+			if !started {
+				if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+					start = i + 1
+				} else {
+					started = true
+				}
+			}
+			i++
+		}
+	}
+	if rest := strings.TrimSpace(script[start:]); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func readWord(s string, i int) string {
+	end := i
+	for end < len(s) && isWordByte(s[end]) {
+		end++
+	}
+	return s[i:end]
+}
+
+// Migrator applies migrations to databases using ? placeholders, i.e. SQLite and MySQL.
+// Every migration runs in a transaction, MySQL commits DDL statements implicitly though.
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+}
+
+func NewMigrator(db *sql.DB, migrations []*Migration) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+func (m *Migrator) ensureRevisionTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+RevisionTable+" ("+
+		"version VARCHAR(64) NOT NULL PRIMARY KEY, "+
+		"name VARCHAR(255) NOT NULL, "+
+		"hash VARCHAR(64) NOT NULL, "+
+		"applied_at BIGINT NOT NULL)")
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[string]Revision, error) {
+	if err := m.ensureRevisionTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version, name, hash, applied_at FROM "+RevisionTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := make(map[string]Revision)
+	for rows.Next() {
+		var r Revision
+		if err = rows.Scan(&r.Version, &r.Name, &r.Hash, &r.AppliedAt); err != nil {
+			return nil, err
+		}
+		revisions[r.Version] = r
+	}
+	return revisions, rows.Err()
+}
+
+// Status returns the applied migrations followed by the pending ones.
+func (m *Migrator) Status(ctx context.Context) ([]Revision, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]Revision, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		if r, ok := applied[migration.Version]; ok {
+			revisions = append(revisions, r)
+			delete(applied, migration.Version)
+			continue
+		}
+		revisions = append(revisions, Revision{Version: migration.Version, Name: migration.Name, Hash: migration.Hash})
+	}
+	for _, r := range applied {
+		revisions = append(revisions, r)
+	}
+	slices.SortStableFunc(revisions, func(a, b Revision) int {
+		if (a.AppliedAt == 0) != (b.AppliedAt == 0) {
+			if a.AppliedAt == 0 {
+				return 1
+			}
+			return -1
+		}
+		return compareVersion(a.Version, b.Version)
+	})
+	return revisions, nil
+}
+
+// Up applies the pending migrations in order and returns them. It fails before applying anything when an
+// applied migration was changed since.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, migration := range m.migrations {
+		if r, ok := applied[migration.Version]; ok && r.Hash != migration.Hash {
+			return nil, fmt.Errorf("%w: %s_%s was changed after it was applied", ErrChecksumMismatch, migration.Version, migration.Name)
+		}
+	}
+	var done []*Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err = m.run(ctx, migration.Up, func(tx *sql.Tx) error {
+			return recordRevision(ctx, tx, migration)
+		})
+		if err != nil {
+			return done, fmt.Errorf("apply %s_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Baseline marks the migrations up to and including version as applied without running them. It adopts databases
+// whose schema already matches these migrations, e.g. created by the auto-migration at startup.
+func (m *Migrator) Baseline(ctx context.Context, version string) ([]*Migration, error) {
+	index := slices.IndexFunc(m.migrations, func(migration *Migration) bool {
+		return migration.Version == version
+	})
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVersion, version)
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var done []*Migration
+	for _, migration := range m.migrations[:index+1] {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err = recordRevision(ctx, tx, migration); err != nil {
+			return nil, errors.Join(err, tx.Rollback())
+		}
+		done = append(done, migration)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	slices.SortFunc(versions, func(a, b string) int {
+		return compareVersion(b, a)
+	})
+	var done []*Migration
+	for _, version := range versions[:min(steps, len(versions))] {
+		index := slices.IndexFunc(m.migrations, func(migration *Migration) bool {
+			return migration.Version == version
+		})
+		if index < 0 {
+			return done, fmt.Errorf("%w: %s", ErrMissingMigration, version)
+		}
+		migration := m.migrations[index]
+		if migration.Down == "" {
+			return done, fmt.Errorf("%w: %s_%s", ErrNoDownMigration, migration.Version, migration.Name)
+		}
+		err = m.run(ctx, migration.Down, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "DELETE FROM "+RevisionTable+" WHERE version = ?", migration.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("revert %s_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+func recordRevision(ctx context.Context, tx *sql.Tx, migration *Migration) error {
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO "+RevisionTable+" (version, name, hash, applied_at) VALUES (?, ?, ?, ?)",
+		migration.Version, migration.Name, migration.Hash, time.Now().Unix(),
+	)
+	return err
+}
+
+func (m *Migrator) run(ctx context.Context, script string, record func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, statement := range splitStatements(script) {
+		if _, err = tx.ExecContext(ctx, statement); err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
+	}
+	if err = record(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
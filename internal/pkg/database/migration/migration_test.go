@@ -0,0 +1,188 @@
+package migration
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// withSum adds the atlas.sum of the migration files to fsys.
+func withSum(t *testing.T, fsys fstest.MapFS) fstest.MapFS {
+	t.Helper()
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatalf("read migrations failed: %v", err)
+	}
+	var files []sumEntry
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".sql") {
+			files = append(files, sumEntry{name: entry.Name(), content: fsys[entry.Name()].Data})
+		}
+	}
+	fsys[SumFile] = &fstest.MapFile{Data: []byte(checksum(files))}
+	return fsys
+}
+
+func TestLoad(t *testing.T) {
+	fsys := withSum(t, fstest.MapFS{
+		"20250102000000_add_index.up.sql":   {Data: []byte("CREATE INDEX a ON t (a);")},
+		"20250102000000_add_index.down.sql": {Data: []byte("DROP INDEX a;")},
+		"20250101000000_init.up.sql":        {Data: []byte("CREATE TABLE t (a int);")},
+	})
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(migrations))
+	}
+	if m := migrations[0]; m.Version != "20250101000000" || m.Name != "init" || m.Down != "" {
+		t.Fatalf("unexpected first migration: %+v", m)
+	}
+	if m := migrations[1]; m.Name != "add_index" || m.Up != "CREATE INDEX a ON t (a);" || m.Down != "DROP INDEX a;" {
+		t.Fatalf("unexpected second migration: %+v", m)
+	}
+	if migrations[0].Hash == "" || migrations[0].Hash == migrations[1].Hash {
+		t.Fatalf("expected distinct hashes, got %q and %q", migrations[0].Hash, migrations[1].Hash)
+	}
+}
+
+func TestLoadChecksum(t *testing.T) {
+	newFS := func() fstest.MapFS {
+		return withSum(t, fstest.MapFS{
+			"1_init.up.sql":   {Data: []byte("CREATE TABLE t (a int);")},
+			"1_init.down.sql": {Data: []byte("DROP TABLE t;")},
+			"2_index.up.sql":  {Data: []byte("CREATE INDEX a ON t (a);")},
+		})
+	}
+
+	missing := newFS()
+	delete(missing, SumFile)
+	if _, err := Load(missing); !errors.Is(err, ErrChecksumNotFound) {
+		t.Fatalf("expected ErrChecksumNotFound, got %v", err)
+	}
+
+	edited := newFS()
+	edited["1_init.down.sql"].Data = []byte("DROP TABLE t; DROP TABLE u;")
+	if _, err := Load(edited); !errors.Is(err, ErrChecksumMismatch) || !strings.Contains(err.Error(), "1_init.down.sql") {
+		t.Fatalf("expected a mismatch of 1_init.down.sql, got %v", err)
+	}
+
+	added := newFS()
+	added["3_column.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE t ADD COLUMN b int;")}
+	if _, err := Load(added); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch for an added file, got %v", err)
+	}
+
+	removed := newFS()
+	delete(removed, "2_index.up.sql")
+	if _, err := Load(removed); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch for a removed file, got %v", err)
+	}
+
+	crlf := newFS()
+	crlf[SumFile].Data = []byte(strings.ReplaceAll(string(crlf[SumFile].Data), "\n", "\r\n"))
+	if _, err := Load(crlf); err != nil {
+		t.Fatalf("expected CRLF line endings to be accepted, got %v", err)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	cases := []fstest.MapFS{
+		{"init.up.sql": {Data: []byte("SELECT 1;")}},
+		{"1_init.sql": {Data: []byte("SELECT 1;")}},
+		{"1_init.down.sql": {Data: []byte("SELECT 1;")}},
+	}
+	for _, fsys := range cases {
+		if _, err := Load(withSum(t, fsys)); !errors.Is(err, ErrInvalidFileName) {
+			t.Fatalf("expected ErrInvalidFileName for %v, got %v", fsys, err)
+		}
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	versions := []string{"10", "9", "002", "1"}
+	slices.SortFunc(versions, compareVersion)
+	if !slices.Equal(versions, []string{"1", "002", "9", "10"}) {
+		t.Fatalf("unexpected order: %v", versions)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- Create "users" table
+CREATE TABLE users (
+  id integer NOT NULL,
+  name text NOT NULL DEFAULT ';'
+);
+-- Create index "users_name" to table: "users"
+CREATE INDEX users_name ON users (name);
+
+SELECT 1`
+	got := splitStatements(script)
+	want := []string{
+		"CREATE TABLE users (\n  id integer NOT NULL,\n  name text NOT NULL DEFAULT ';'\n);",
+		"CREATE INDEX users_name ON users (name);",
+		"SELECT 1",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("unexpected statements: %q", got)
+	}
+}
+
+func TestSplitStatementsBodies(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name: "sqlite trigger",
+			script: `CREATE TRIGGER users_updated AFTER UPDATE ON users BEGIN
+  UPDATE users SET updated_at = 1 WHERE id = NEW.id;
+  INSERT INTO logs (msg) VALUES (CASE WHEN NEW.name = 'a;b' THEN 'x' ELSE 'y' END);
+END;
+DROP TABLE t;`,
+			want: []string{
+				"CREATE TRIGGER users_updated AFTER UPDATE ON users BEGIN\n  UPDATE users SET updated_at = 1 WHERE id = NEW.id;\n" +
+					"  INSERT INTO logs (msg) VALUES (CASE WHEN NEW.name = 'a;b' THEN 'x' ELSE 'y' END);\nEND;",
+				"DROP TABLE t;",
+			},
+		},
+		{
+			name: "mysql procedure",
+			script: `CREATE PROCEDURE cleanup(IN days INT)
+BEGIN
+  IF days > 0 THEN
+    DELETE FROM logs WHERE age > days;
+  END IF;
+  CASE days WHEN 0 THEN SELECT 1; ELSE SELECT 2; END CASE;
+  WHILE days > 0 DO SET days = days - 1; END WHILE;
+END;
+CALL cleanup(1);`,
+			want: []string{
+				"CREATE PROCEDURE cleanup(IN days INT)\nBEGIN\n  IF days > 0 THEN\n    DELETE FROM logs WHERE age > days;\n  END IF;\n" +
+					"  CASE days WHEN 0 THEN SELECT 1; ELSE SELECT 2; END CASE;\n  WHILE days > 0 DO SET days = days - 1; END WHILE;\nEND;",
+				"CALL cleanup(1);",
+			},
+		},
+		{
+			name:   "quotes and comments",
+			script: "/* header; */\nINSERT INTO t (a, `b;c`, \"d\") VALUES ('it''s;', 'x'); -- trailing;\n;\nUPDATE t SET a = 'e' /* ; */;",
+			want: []string{
+				"INSERT INTO t (a, `b;c`, \"d\") VALUES ('it''s;', 'x');",
+				"UPDATE t SET a = 'e' /* ; */;",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !slices.Equal(got, tt.want) {
+				t.Fatalf("unexpected statements: %q", got)
+			}
+		})
+	}
+}
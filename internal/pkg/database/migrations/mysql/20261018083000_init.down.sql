@@ -0,0 +1,22 @@
+-- reverse: create "user_sessions" table
+DROP TABLE `user_sessions`;
+-- reverse: create "user_platforms" table
+DROP TABLE `user_platforms`;
+-- reverse: create "user_flag_records" table
+DROP TABLE `user_flag_records`;
+-- reverse: create "users" table
+DROP TABLE `users`;
+-- reverse: create "roles" table
+DROP TABLE `roles`;
+-- reverse: create "permissions" table
+DROP TABLE `permissions`;
+-- reverse: create "key_value_stores" table
+DROP TABLE `key_value_stores`;
+-- reverse: create "audit_logs" table
+DROP TABLE `audit_logs`;
+-- reverse: create "admin_sessions" table
+DROP TABLE `admin_sessions`;
+-- reverse: create "admin_api_keys" table
+DROP TABLE `admin_api_keys`;
+-- reverse: create "admins" table
+DROP TABLE `admins`;
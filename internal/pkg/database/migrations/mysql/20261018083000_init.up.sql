@@ -0,0 +1,170 @@
+-- Create "admins" table
+CREATE TABLE `admins` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `username` varchar(255) NOT NULL,
+  `nickname` varchar(255) NOT NULL DEFAULT '',
+  `avatar` varchar(255) NOT NULL DEFAULT '',
+  `password` varchar(255) NOT NULL,
+  `roles` json NOT NULL,
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  `totp_secret` varchar(255) NOT NULL DEFAULT '',
+  `totp_enabled` bool NOT NULL DEFAULT false,
+  `recovery_codes` json NOT NULL,
+  `password_history` json NOT NULL,
+  `login_failures` bigint NOT NULL DEFAULT 0,
+  `locked_until` bigint NOT NULL DEFAULT 0,
+  `oidc_subject` varchar(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `username` (`username`),
+  INDEX `admin_oidc_subject` (`oidc_subject`)
+) CHARSET utf8mb4 COLLATE utf8mb4_bin;
+-- Create "admin_api_keys" table
+CREATE TABLE `admin_api_keys` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `uid` bigint NOT NULL,
+  `name` varchar(64) NOT NULL,
+  `prefix` varchar(255) NOT NULL,
+  `key_hash` varchar(255) NOT NULL,
+  `scopes` json NOT NULL,
+  `expires_at` bigint NOT NULL DEFAULT 0,
+  `last_used_at` bigint NOT NULL DEFAULT 0,
+  `is_revoked` bool NOT NULL DEFAULT false,
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `key_hash` (`key_hash`),
+  INDEX `adminapikey_uid` (`uid`)
+) CHARSET utf8mb4 COLLATE utf8mb4_bin;
+-- Create "admin_sessions" table
+CREATE TABLE `admin_sessions` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `uid` bigint NOT NULL,
+  `session_key` varchar(36) NOT NULL,
+  `expires` bigint NOT NULL,
+  `is_revoked` bool NOT NULL DEFAULT false,
+  `device_info` varchar(255) NOT NULL DEFAULT '',
+  `ip_address` varchar(255) NOT NULL DEFAULT '',
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  `parent_id` bigint NOT NULL DEFAULT 0,
+  `family_id` bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  INDEX `adminsession_parent_id` (`parent_id`),
+  INDEX `adminsession_family_id` (`family_id`)
+) CHARSET utf8mb4 COLLATE utf8mb4_bin;
+-- Create "audit_logs" table
+CREATE TABLE `audit_logs` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `uid` bigint NOT NULL DEFAULT 0,
+  `operation` varchar(255) NOT NULL DEFAULT '',
+  `entity` varchar(255) NOT NULL,
+  `target_id` bigint NOT NULL DEFAULT 0,
+  `action` varchar(255) NOT NULL,
+  `changes` varchar(255) NOT NULL DEFAULT '',
+  `ip_address` varchar(255) NOT NULL DEFAULT '',
+  `user_agent` varchar(255) NOT NULL DEFAULT '',
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `auditlog_uid` (`uid`),
+  INDEX `auditlog_operation` (`operation`),
+  INDEX `auditlog_entity_target_id` (`entity`, `target_id`),
+  INDEX `auditlog_created_at` (`created_at`)
+) CHARSET utf8mb4 COLLATE utf8mb4_bin;
+-- Create "key_value_stores" table
+CREATE TABLE `key_value_stores` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `key` varchar(255) NOT NULL,
+  `value` blob NOT NULL,
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `key` (`key`)
+) CHARSET utf8mb4 COLLATE utf8mb4_bin;
+-- Create "permissions" table
+CREATE TABLE `permissions` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `builtin` bool NOT NULL DEFAULT false,
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `name` (`name`)
+) CHARSET utf8mb4 COLLATE utf8mb4_bin;
+-- Create "roles" table
+CREATE TABLE `roles` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `permissions` json NOT NULL,
+  `builtin` bool NOT NULL DEFAULT false,
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `name` (`name`)
+) CHARSET utf8mb4 COLLATE utf8mb4_bin;
+-- Create "users" table
+CREATE TABLE `users` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `username` varchar(255) NOT NULL,
+  `nickname` varchar(30) NOT NULL DEFAULT '',
+  `remark` varchar(30) NOT NULL DEFAULT '',
+  `avatar` varchar(255) NOT NULL DEFAULT '',
+  `flags` bigint unsigned NOT NULL DEFAULT 0,
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  `delete_at` bigint NOT NULL DEFAULT 0,
+  `deleted_at` bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  INDEX `user_delete_at` (`delete_at`)
+) CHARSET utf8mb4 COLLATE utf8mb4_bin;
+-- Create "user_flag_records" table
+CREATE TABLE `user_flag_records` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `flag` varchar(255) NOT NULL,
+  `reason` varchar(256) NOT NULL DEFAULT '',
+  `expires_at` bigint NOT NULL DEFAULT 0,
+  `operator_id` bigint NOT NULL DEFAULT 0,
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `userflagrecord_user_id_flag` (`user_id`, `flag`)
+) CHARSET utf8mb4 COLLATE utf8mb4_bin;
+-- Create "user_platforms" table
+CREATE TABLE `user_platforms` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `platform` enum('wechat_mini','phone','email','github','google','apple','wechat_oa') NOT NULL,
+  `platform_id` varchar(255) NOT NULL,
+  `second_id` varchar(255) NOT NULL DEFAULT '',
+  `private_key` varchar(255) NOT NULL DEFAULT '',
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  `verified_at` bigint NOT NULL DEFAULT 0,
+  `app_id` varchar(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `userplatform_platform_platform_id` (`platform`, `platform_id`),
+  INDEX `userplatform_second_id` (`second_id`)
+) CHARSET utf8mb4 COLLATE utf8mb4_bin;
+-- Create "user_sessions" table
+CREATE TABLE `user_sessions` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `uid` bigint NOT NULL,
+  `session_key` varchar(36) NOT NULL,
+  `expires` bigint NOT NULL,
+  `is_revoked` bool NOT NULL DEFAULT false,
+  `device_info` varchar(255) NOT NULL DEFAULT '',
+  `ip_address` varchar(255) NOT NULL DEFAULT '',
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  `parent_id` bigint NOT NULL DEFAULT 0,
+  `family_id` bigint NOT NULL DEFAULT 0,
+  `subject` varchar(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  INDEX `usersession_uid` (`uid`),
+  INDEX `usersession_parent_id` (`parent_id`),
+  INDEX `usersession_family_id` (`family_id`)
+) CHARSET utf8mb4 COLLATE utf8mb4_bin;
@@ -0,0 +1,62 @@
+-- reverse: create index "usersession_family_id" to table: "user_sessions"
+DROP INDEX `usersession_family_id`;
+-- reverse: create index "usersession_parent_id" to table: "user_sessions"
+DROP INDEX `usersession_parent_id`;
+-- reverse: create index "usersession_uid" to table: "user_sessions"
+DROP INDEX `usersession_uid`;
+-- reverse: create "user_sessions" table
+DROP TABLE `user_sessions`;
+-- reverse: create index "userplatform_second_id" to table: "user_platforms"
+DROP INDEX `userplatform_second_id`;
+-- reverse: create index "userplatform_platform_platform_id" to table: "user_platforms"
+DROP INDEX `userplatform_platform_platform_id`;
+-- reverse: create "user_platforms" table
+DROP TABLE `user_platforms`;
+-- reverse: create index "userflagrecord_user_id_flag" to table: "user_flag_records"
+DROP INDEX `userflagrecord_user_id_flag`;
+-- reverse: create "user_flag_records" table
+DROP TABLE `user_flag_records`;
+-- reverse: create index "user_delete_at" to table: "users"
+DROP INDEX `user_delete_at`;
+-- reverse: create "users" table
+DROP TABLE `users`;
+-- reverse: create index "roles_name_key" to table: "roles"
+DROP INDEX `roles_name_key`;
+-- reverse: create "roles" table
+DROP TABLE `roles`;
+-- reverse: create index "permissions_name_key" to table: "permissions"
+DROP INDEX `permissions_name_key`;
+-- reverse: create "permissions" table
+DROP TABLE `permissions`;
+-- reverse: create index "key_value_stores_key_key" to table: "key_value_stores"
+DROP INDEX `key_value_stores_key_key`;
+-- reverse: create "key_value_stores" table
+DROP TABLE `key_value_stores`;
+-- reverse: create index "auditlog_created_at" to table: "audit_logs"
+DROP INDEX `auditlog_created_at`;
+-- reverse: create index "auditlog_entity_target_id" to table: "audit_logs"
+DROP INDEX `auditlog_entity_target_id`;
+-- reverse: create index "auditlog_operation" to table: "audit_logs"
+DROP INDEX `auditlog_operation`;
+-- reverse: create index "auditlog_uid" to table: "audit_logs"
+DROP INDEX `auditlog_uid`;
+-- reverse: create "audit_logs" table
+DROP TABLE `audit_logs`;
+-- reverse: create index "adminsession_family_id" to table: "admin_sessions"
+DROP INDEX `adminsession_family_id`;
+-- reverse: create index "adminsession_parent_id" to table: "admin_sessions"
+DROP INDEX `adminsession_parent_id`;
+-- reverse: create "admin_sessions" table
+DROP TABLE `admin_sessions`;
+-- reverse: create index "adminapikey_uid" to table: "admin_api_keys"
+DROP INDEX `adminapikey_uid`;
+-- reverse: create index "admin_api_keys_key_hash_key" to table: "admin_api_keys"
+DROP INDEX `admin_api_keys_key_hash_key`;
+-- reverse: create "admin_api_keys" table
+DROP TABLE `admin_api_keys`;
+-- reverse: create index "admin_oidc_subject" to table: "admins"
+DROP INDEX `admin_oidc_subject`;
+-- reverse: create index "admins_username_key" to table: "admins"
+DROP INDEX `admins_username_key`;
+-- reverse: create "admins" table
+DROP TABLE `admins`;
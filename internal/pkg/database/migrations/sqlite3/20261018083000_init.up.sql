@@ -0,0 +1,179 @@
+-- Create "admins" table
+CREATE TABLE `admins` (
+  `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `username` text NOT NULL,
+  `nickname` text NOT NULL DEFAULT '',
+  `avatar` text NOT NULL DEFAULT '',
+  `password` text NOT NULL,
+  `roles` json NOT NULL,
+  `created_at` integer NOT NULL,
+  `updated_at` integer NOT NULL,
+  `totp_secret` text NOT NULL DEFAULT '',
+  `totp_enabled` bool NOT NULL DEFAULT false,
+  `recovery_codes` json NOT NULL,
+  `password_history` json NOT NULL,
+  `login_failures` integer NOT NULL DEFAULT 0,
+  `locked_until` integer NOT NULL DEFAULT 0,
+  `oidc_subject` text NOT NULL DEFAULT ''
+);
+-- Create index "admins_username_key" to table: "admins"
+CREATE UNIQUE INDEX `admins_username_key` ON `admins` (`username`);
+-- Create index "admin_oidc_subject" to table: "admins"
+CREATE INDEX `admin_oidc_subject` ON `admins` (`oidc_subject`);
+-- Create "admin_api_keys" table
+CREATE TABLE `admin_api_keys` (
+  `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `uid` integer NOT NULL,
+  `name` text NOT NULL,
+  `prefix` text NOT NULL,
+  `key_hash` text NOT NULL,
+  `scopes` json NOT NULL,
+  `expires_at` integer NOT NULL DEFAULT 0,
+  `last_used_at` integer NOT NULL DEFAULT 0,
+  `is_revoked` bool NOT NULL DEFAULT false,
+  `created_at` integer NOT NULL,
+  `updated_at` integer NOT NULL
+);
+-- Create index "admin_api_keys_key_hash_key" to table: "admin_api_keys"
+CREATE UNIQUE INDEX `admin_api_keys_key_hash_key` ON `admin_api_keys` (`key_hash`);
+-- Create index "adminapikey_uid" to table: "admin_api_keys"
+CREATE INDEX `adminapikey_uid` ON `admin_api_keys` (`uid`);
+-- Create "admin_sessions" table
+CREATE TABLE `admin_sessions` (
+  `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `uid` integer NOT NULL,
+  `session_key` text NOT NULL,
+  `expires` integer NOT NULL,
+  `is_revoked` bool NOT NULL DEFAULT false,
+  `device_info` text NOT NULL DEFAULT '',
+  `ip_address` text NOT NULL DEFAULT '',
+  `created_at` integer NOT NULL,
+  `updated_at` integer NOT NULL,
+  `parent_id` integer NOT NULL DEFAULT 0,
+  `family_id` integer NOT NULL DEFAULT 0
+);
+-- Create index "adminsession_parent_id" to table: "admin_sessions"
+CREATE INDEX `adminsession_parent_id` ON `admin_sessions` (`parent_id`);
+-- Create index "adminsession_family_id" to table: "admin_sessions"
+CREATE INDEX `adminsession_family_id` ON `admin_sessions` (`family_id`);
+-- Create "audit_logs" table
+CREATE TABLE `audit_logs` (
+  `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `uid` integer NOT NULL DEFAULT 0,
+  `operation` text NOT NULL DEFAULT '',
+  `entity` text NOT NULL,
+  `target_id` integer NOT NULL DEFAULT 0,
+  `action` text NOT NULL,
+  `changes` text NOT NULL DEFAULT '',
+  `ip_address` text NOT NULL DEFAULT '',
+  `user_agent` text NOT NULL DEFAULT '',
+  `created_at` integer NOT NULL,
+  `updated_at` integer NOT NULL
+);
+-- Create index "auditlog_uid" to table: "audit_logs"
+CREATE INDEX `auditlog_uid` ON `audit_logs` (`uid`);
+-- Create index "auditlog_operation" to table: "audit_logs"
+CREATE INDEX `auditlog_operation` ON `audit_logs` (`operation`);
+-- Create index "auditlog_entity_target_id" to table: "audit_logs"
+CREATE INDEX `auditlog_entity_target_id` ON `audit_logs` (`entity`, `target_id`);
+-- Create index "auditlog_created_at" to table: "audit_logs"
+CREATE INDEX `auditlog_created_at` ON `audit_logs` (`created_at`);
+-- Create "key_value_stores" table
+CREATE TABLE `key_value_stores` (
+  `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `key` text NOT NULL,
+  `value` blob NOT NULL,
+  `created_at` integer NOT NULL,
+  `updated_at` integer NOT NULL
+);
+-- Create index "key_value_stores_key_key" to table: "key_value_stores"
+CREATE UNIQUE INDEX `key_value_stores_key_key` ON `key_value_stores` (`key`);
+-- Create "permissions" table
+CREATE TABLE `permissions` (
+  `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `name` text NOT NULL,
+  `description` text NOT NULL DEFAULT '',
+  `builtin` bool NOT NULL DEFAULT false,
+  `created_at` integer NOT NULL,
+  `updated_at` integer NOT NULL
+);
+-- Create index "permissions_name_key" to table: "permissions"
+CREATE UNIQUE INDEX `permissions_name_key` ON `permissions` (`name`);
+-- Create "roles" table
+CREATE TABLE `roles` (
+  `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `name` text NOT NULL,
+  `description` text NOT NULL DEFAULT '',
+  `permissions` json NOT NULL,
+  `builtin` bool NOT NULL DEFAULT false,
+  `created_at` integer NOT NULL,
+  `updated_at` integer NOT NULL
+);
+-- Create index "roles_name_key" to table: "roles"
+CREATE UNIQUE INDEX `roles_name_key` ON `roles` (`name`);
+-- Create "users" table
+CREATE TABLE `users` (
+  `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `username` text NOT NULL,
+  `nickname` text NOT NULL DEFAULT '',
+  `remark` text NOT NULL DEFAULT '',
+  `avatar` text NOT NULL DEFAULT '',
+  `flags` integer NOT NULL DEFAULT 0,
+  `created_at` integer NOT NULL,
+  `updated_at` integer NOT NULL,
+  `delete_at` integer NOT NULL DEFAULT 0,
+  `deleted_at` integer NOT NULL DEFAULT 0
+);
+-- Create index "user_delete_at" to table: "users"
+CREATE INDEX `user_delete_at` ON `users` (`delete_at`);
+-- Create "user_flag_records" table
+CREATE TABLE `user_flag_records` (
+  `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL,
+  `flag` text NOT NULL,
+  `reason` text NOT NULL DEFAULT '',
+  `expires_at` integer NOT NULL DEFAULT 0,
+  `operator_id` integer NOT NULL DEFAULT 0,
+  `created_at` integer NOT NULL,
+  `updated_at` integer NOT NULL
+);
+-- Create index "userflagrecord_user_id_flag" to table: "user_flag_records"
+CREATE UNIQUE INDEX `userflagrecord_user_id_flag` ON `user_flag_records` (`user_id`, `flag`);
+-- Create "user_platforms" table
+CREATE TABLE `user_platforms` (
+  `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL,
+  `platform` text NOT NULL,
+  `platform_id` text NOT NULL,
+  `second_id` text NOT NULL DEFAULT '',
+  `private_key` text NOT NULL DEFAULT '',
+  `created_at` integer NOT NULL,
+  `updated_at` integer NOT NULL,
+  `verified_at` integer NOT NULL DEFAULT 0,
+  `app_id` text NOT NULL DEFAULT ''
+);
+-- Create index "userplatform_platform_platform_id" to table: "user_platforms"
+CREATE UNIQUE INDEX `userplatform_platform_platform_id` ON `user_platforms` (`platform`, `platform_id`);
+-- Create index "userplatform_second_id" to table: "user_platforms"
+CREATE INDEX `userplatform_second_id` ON `user_platforms` (`second_id`);
+-- Create "user_sessions" table
+CREATE TABLE `user_sessions` (
+  `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `uid` integer NOT NULL,
+  `session_key` text NOT NULL,
+  `expires` integer NOT NULL,
+  `is_revoked` bool NOT NULL DEFAULT false,
+  `device_info` text NOT NULL DEFAULT '',
+  `ip_address` text NOT NULL DEFAULT '',
+  `created_at` integer NOT NULL,
+  `updated_at` integer NOT NULL,
+  `parent_id` integer NOT NULL DEFAULT 0,
+  `family_id` integer NOT NULL DEFAULT 0,
+  `subject` text NOT NULL DEFAULT ''
+);
+-- Create index "usersession_uid" to table: "user_sessions"
+CREATE INDEX `usersession_uid` ON `user_sessions` (`uid`);
+-- Create index "usersession_parent_id" to table: "user_sessions"
+CREATE INDEX `usersession_parent_id` ON `user_sessions` (`parent_id`);
+-- Create index "usersession_family_id" to table: "user_sessions"
+CREATE INDEX `usersession_family_id` ON `user_sessions` (`family_id`);
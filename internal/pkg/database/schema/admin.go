@@ -4,6 +4,7 @@ import (
 	"entgo.io/ent"
 	"entgo.io/ent/schema"
 	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
 	"github.com/go-sphere/entc-extensions/entproto"
 	"github.com/go-sphere/sphere/utils/idgenerator"
 )
@@ -22,6 +23,13 @@ func (Admin) Fields() []ent.Field {
 		field.String("password").Annotations(entproto.Field(5)).Comment("密码").Sensitive(),
 		field.Strings("roles").Annotations(entproto.Field(6)).Default([]string{}).Comment("权限").Sensitive(),
 		times[0], times[1],
+		field.String("totp_secret").Annotations(entproto.Field(9)).Default("").Comment("TOTP密钥(加密)").Sensitive(),
+		field.Bool("totp_enabled").Annotations(entproto.Field(10)).Default(false).Comment("是否启用TOTP"),
+		field.Strings("recovery_codes").Annotations(entproto.Field(11)).Default([]string{}).Comment("恢复码(哈希)").Sensitive(),
+		field.Strings("password_history").Annotations(entproto.Field(12)).Default([]string{}).Comment("历史密码(哈希)").Sensitive(),
+		field.Int("login_failures").Annotations(entproto.Field(13)).Default(0).Comment("连续登录失败次数"),
+		field.Int64("locked_until").Annotations(entproto.Field(14)).Default(0).Comment("锁定截止时间"),
+		field.String("oidc_subject").Annotations(entproto.Field(15)).Default("").Comment("OIDC 用户标识"),
 	}
 }
 func (Admin) Annotations() []schema.Annotation {
@@ -30,6 +38,44 @@ func (Admin) Annotations() []schema.Annotation {
 	}
 }
 
+func (Admin) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("oidc_subject"),
+	}
+}
+
+type AdminApiKey struct {
+	ent.Schema
+}
+
+func (AdminApiKey) Fields() []ent.Field {
+	times := DefaultTimeProtoFields([2]int{10, 11})
+	return []ent.Field{
+		field.Int64("id").Annotations(entproto.Field(1)).Unique().Immutable().DefaultFunc(idgenerator.NextId).Comment("密钥ID"),
+		field.Int64("uid").Annotations(entproto.Field(2)).Immutable().Comment("管理员ID"),
+		field.String("name").Annotations(entproto.Field(3)).MinLen(1).MaxLen(64).Comment("名称"),
+		field.String("prefix").Annotations(entproto.Field(4)).Immutable().Comment("密钥前缀, 用于识别"),
+		field.String("key_hash").Annotations(entproto.Field(5)).Immutable().Unique().Sensitive().Comment("密钥哈希"),
+		field.Strings("scopes").Annotations(entproto.Field(6)).Immutable().Default([]string{}).Comment("授权范围"),
+		field.Int64("expires_at").Annotations(entproto.Field(7)).Immutable().Default(0).Comment("过期时间, 0 表示永不过期"),
+		field.Int64("last_used_at").Annotations(entproto.Field(8)).Default(0).Comment("最后使用时间"),
+		field.Bool("is_revoked").Annotations(entproto.Field(9)).Default(false).Comment("是否已撤销"),
+		times[0], times[1],
+	}
+}
+
+func (AdminApiKey) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entproto.Message(),
+	}
+}
+
+func (AdminApiKey) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("uid"),
+	}
+}
+
 type AdminSession struct {
 	ent.Schema
 }
@@ -45,6 +91,8 @@ func (AdminSession) Fields() []ent.Field {
 		field.String("device_info").Annotations(entproto.Field(6)).Default("").Comment("设备信息"),
 		field.String("ip_address").Annotations(entproto.Field(7)).Default("").Comment("IP地址"),
 		times[0], times[1],
+		field.Int64("parent_id").Annotations(entproto.Field(10)).Immutable().Default(0).Comment("轮换前的会话ID"),
+		field.Int64("family_id").Annotations(entproto.Field(11)).Immutable().Default(0).Comment("会话族ID"),
 	}
 }
 
@@ -53,3 +101,88 @@ func (AdminSession) Annotations() []schema.Annotation {
 		entproto.Message(),
 	}
 }
+
+func (AdminSession) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("parent_id"),
+		index.Fields("family_id"),
+	}
+}
+
+type Role struct {
+	ent.Schema
+}
+
+func (Role) Fields() []ent.Field {
+	times := DefaultTimeProtoFields([2]int{6, 7})
+	return []ent.Field{
+		field.Int64("id").Annotations(entproto.Field(1)).Unique().Immutable().DefaultFunc(idgenerator.NextId).Comment("角色ID"),
+		field.String("name").Annotations(entproto.Field(2)).Unique().MinLen(1).MaxLen(64).Comment("角色名"),
+		field.String("description").Annotations(entproto.Field(3)).Default("").Comment("描述"),
+		field.Strings("permissions").Annotations(entproto.Field(4)).Default([]string{}).Comment("权限列表"),
+		field.Bool("builtin").Annotations(entproto.Field(5)).Default(false).Comment("是否内置"),
+		times[0], times[1],
+	}
+}
+
+func (Role) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entproto.Message(),
+	}
+}
+
+type Permission struct {
+	ent.Schema
+}
+
+func (Permission) Fields() []ent.Field {
+	times := DefaultTimeProtoFields([2]int{5, 6})
+	return []ent.Field{
+		field.Int64("id").Annotations(entproto.Field(1)).Unique().Immutable().DefaultFunc(idgenerator.NextId).Comment("权限ID"),
+		field.String("name").Annotations(entproto.Field(2)).Unique().MinLen(1).MaxLen(64).Comment("权限名"),
+		field.String("description").Annotations(entproto.Field(3)).Default("").Comment("描述"),
+		field.Bool("builtin").Annotations(entproto.Field(4)).Default(false).Comment("是否内置"),
+		times[0], times[1],
+	}
+}
+
+func (Permission) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entproto.Message(),
+	}
+}
+
+type AuditLog struct {
+	ent.Schema
+}
+
+func (AuditLog) Fields() []ent.Field {
+	times := DefaultTimeProtoFields([2]int{10, 11})
+	return []ent.Field{
+		field.Int64("id").Annotations(entproto.Field(1)).Unique().Immutable().DefaultFunc(idgenerator.NextId).Comment("日志ID"),
+		field.Int64("uid").Annotations(entproto.Field(2)).Immutable().Default(0).Comment("操作人ID"),
+		field.String("operation").Annotations(entproto.Field(3)).Immutable().Default("").Comment("操作名称"),
+		field.String("entity").Annotations(entproto.Field(4)).Immutable().Comment("目标类型"),
+		field.Int64("target_id").Annotations(entproto.Field(5)).Immutable().Default(0).Comment("目标ID"),
+		field.String("action").Annotations(entproto.Field(6)).Immutable().Comment("变更类型"),
+		field.String("changes").Annotations(entproto.Field(7)).Immutable().Default("").Comment("字段变更(JSON)"),
+		field.String("ip_address").Annotations(entproto.Field(8)).Immutable().Default("").Comment("IP地址"),
+		field.String("user_agent").Annotations(entproto.Field(9)).Immutable().Default("").Comment("User-Agent"),
+		times[0], times[1],
+	}
+}
+
+func (AuditLog) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entproto.Message(),
+	}
+}
+
+func (AuditLog) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("uid"),
+		index.Fields("operation"),
+		index.Fields("entity", "target_id"),
+		index.Fields("created_at"),
+	}
+}
@@ -23,6 +23,8 @@ func (User) Fields() []ent.Field {
 		field.String("avatar").Annotations(entproto.Field(5)).Comment("头像").Default(""),
 		field.Uint64("flags").Annotations(entproto.Field(6)).Default(0).Comment("标记位"),
 		times[0], times[1],
+		field.Int64("delete_at").Annotations(entproto.Field(9)).Default(0).Comment("计划注销时间"),
+		field.Int64("deleted_at").Annotations(entproto.Field(10)).Default(0).Comment("注销时间"),
 	}
 }
 
@@ -32,6 +34,43 @@ func (User) Annotations() []schema.Annotation {
 	}
 }
 
+func (User) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("delete_at"),
+	}
+}
+
+// UserFlagRecord records why and until when a flag of User.flags is set.
+// Flags without a record are permanent.
+type UserFlagRecord struct {
+	ent.Schema
+}
+
+func (UserFlagRecord) Fields() []ent.Field {
+	times := DefaultTimeProtoFields([2]int{7, 8})
+	return []ent.Field{
+		field.Int64("id").Annotations(entproto.Field(1)).Unique().Immutable().DefaultFunc(idgenerator.NextId).Comment("ID"),
+		field.Int64("user_id").Annotations(entproto.Field(2)).Immutable().Comment("用户ID"),
+		field.String("flag").Annotations(entproto.Field(3)).Immutable().Comment("标记名称"),
+		field.String("reason").Annotations(entproto.Field(4)).Default("").MaxLen(256).Comment("原因"),
+		field.Int64("expires_at").Annotations(entproto.Field(5)).Default(0).Comment("过期时间, 0 表示永不过期"),
+		field.Int64("operator_id").Annotations(entproto.Field(6)).Default(0).Comment("操作管理员ID"),
+		times[0], times[1],
+	}
+}
+
+func (UserFlagRecord) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entproto.Message(),
+	}
+}
+
+func (UserFlagRecord) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("user_id", "flag").Unique(),
+	}
+}
+
 type UserPlatform struct {
 	ent.Schema
 }
@@ -41,12 +80,17 @@ func (UserPlatform) Fields() []ent.Field {
 	return []ent.Field{
 		field.Int64("id").Annotations(entproto.Field(1)).Comment("ID"),
 		field.Int64("user_id").Annotations(entproto.Field(2)).Comment("用户ID"),
-		field.Enum("platform").Values("wechat_mini", "phone").
+		field.Enum("platform").Values("wechat_mini", "phone", "email", "github", "google", "apple", "wechat_oa").
 			Annotations(
 				entproto.Field(3),
 				entproto.Enum(map[string]int32{
 					"wechat_mini": 1,
 					"phone":       2,
+					"email":       3,
+					"github":      4,
+					"google":      5,
+					"apple":       6,
+					"wechat_oa":   7,
 				}),
 			).
 			Comment("平台"),
@@ -54,6 +98,8 @@ func (UserPlatform) Fields() []ent.Field {
 		field.String("second_id").Annotations(entproto.Field(5)).Default("").Comment("第二ID"),
 		field.String("private_key").Annotations(entproto.Field(6)).Default("").Comment("私钥").Sensitive(),
 		times[0], times[1],
+		field.Int64("verified_at").Annotations(entproto.Field(9)).Default(0).Comment("验证时间"),
+		field.String("app_id").Annotations(entproto.Field(10)).Default("").Comment("微信应用AppID"),
 	}
 }
 
@@ -65,6 +111,42 @@ func (UserPlatform) Annotations() []schema.Annotation {
 
 func (UserPlatform) Indexes() []ent.Index {
 	return []ent.Index{
-		index.Fields("platform", "platform_id"),
+		index.Fields("platform", "platform_id").Unique(),
+		index.Fields("second_id"),
+	}
+}
+
+type UserSession struct {
+	ent.Schema
+}
+
+func (UserSession) Fields() []ent.Field {
+	times := DefaultTimeProtoFields([2]int{8, 9})
+	return []ent.Field{
+		field.Int64("id").Annotations(entproto.Field(1)).Unique().Immutable().DefaultFunc(idgenerator.NextId).Comment("会话ID"),
+		field.Int64("uid").Annotations(entproto.Field(2)).Immutable().Comment("用户ID"),
+		field.String("session_key").Annotations(entproto.Field(3)).Immutable().Sensitive().MaxLen(36).Comment("会话密钥"),
+		field.Int64("expires").Annotations(entproto.Field(4)).Immutable().DefaultFunc(TimestampDefaultFunc).Comment("过期时间"),
+		field.Bool("is_revoked").Annotations(entproto.Field(5)).Default(false).Comment("是否已撤销"),
+		field.String("device_info").Annotations(entproto.Field(6)).Default("").Comment("设备信息"),
+		field.String("ip_address").Annotations(entproto.Field(7)).Default("").Comment("IP地址"),
+		times[0], times[1],
+		field.Int64("parent_id").Annotations(entproto.Field(10)).Immutable().Default(0).Comment("轮换前的会话ID"),
+		field.Int64("family_id").Annotations(entproto.Field(11)).Immutable().Default(0).Comment("会话族ID"),
+		field.String("subject").Annotations(entproto.Field(12)).Immutable().Default("").Comment("访问令牌主体"),
+	}
+}
+
+func (UserSession) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entproto.Message(),
+	}
+}
+
+func (UserSession) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("uid"),
+		index.Fields("parent_id"),
+		index.Fields("family_id"),
 	}
 }
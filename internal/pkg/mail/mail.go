@@ -0,0 +1,182 @@
+// Package mail sends plain text emails through SMTP.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sphere/sphere/log"
+)
+
+var ErrInvalidAddress = errors.New("mail: invalid address")
+
+type Config struct {
+	Host     string `json:"host" yaml:"host"`
+	Port     int    `json:"port" yaml:"port"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	From     string `json:"from" yaml:"from"`
+	// ImplicitTLS connects with TLS directly, as required on port 465. Otherwise STARTTLS is used when offered.
+	ImplicitTLS bool `json:"implicit_tls" yaml:"implicit_tls"`
+}
+
+// SMTPSender delivers each email over a new SMTP connection.
+type SMTPSender struct {
+	config  Config
+	timeout time.Duration
+}
+
+func NewSMTPSender(config Config) *SMTPSender {
+	if config.Port == 0 {
+		config.Port = 587
+		if config.ImplicitTLS {
+			config.Port = 465
+		}
+	}
+	return &SMTPSender{
+		config:  config,
+		timeout: 30 * time.Second,
+	}
+}
+
+func (s *SMTPSender) SendEmail(ctx context.Context, to string, subject string, body string) error {
+	from, err := netmail.ParseAddress(s.config.From)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAddress, s.config.From)
+	}
+	rcpt, err := netmail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAddress, to)
+	}
+	msg, err := buildMessage(from, rcpt, subject, body)
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(s.timeout)
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !s.config.ImplicitTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if s.config.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err = client.Rcpt(rcpt.Address); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(msg); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (s *SMTPSender) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	dialer := &net.Dialer{Timeout: s.timeout}
+	if s.config.ImplicitTLS {
+		tlsDialer := &tls.Dialer{
+			NetDialer: dialer,
+			Config:    &tls.Config{ServerName: s.config.Host},
+		}
+		return tlsDialer.DialContext(ctx, "tcp", addr)
+	}
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+func buildMessage(from *netmail.Address, to *netmail.Address, subject string, body string) ([]byte, error) {
+	subject = strings.NewReplacer("\r", "", "\n", "").Replace(subject)
+	var buf bytes.Buffer
+	buf.WriteString("From: " + from.String() + "\r\n")
+	buf.WriteString("To: " + to.String() + "\r\n")
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+	writer := quotedprintable.NewWriter(&buf)
+	if _, err := writer.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// LogSender writes emails to the log instead of sending them, for local development and tests.
+type LogSender struct {
+	mu       sync.Mutex
+	messages map[string]Message
+}
+
+func NewLogSender() *LogSender {
+	return &LogSender{messages: make(map[string]Message)}
+}
+
+func (s *LogSender) SendEmail(ctx context.Context, to string, subject string, body string) error {
+	log.Info("email", log.Any("to", to), log.Any("subject", subject), log.Any("body", body))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[to] = Message{To: to, Subject: subject, Body: body}
+	return nil
+}
+
+// LastMessage returns the last email sent to the address.
+func (s *LogSender) LastMessage(to string) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg, ok := s.messages[to]
+	return msg, ok
+}
@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"testing"
+
+	"github.com/go-sphere/sphere-layout/internal/pkg/mail/mailtest"
+)
+
+func TestSMTPSender(t *testing.T) {
+	server := mailtest.NewServerWithAuth("sender", "secret")
+	defer server.Close()
+
+	sender := NewSMTPSender(Config{
+		Host:     server.Host(),
+		Port:     server.Port(),
+		Username: "sender",
+		Password: "secret",
+		From:     "Sphere <noreply@example.com>",
+	})
+	err := sender.SendEmail(context.Background(), "user@example.com", "验证邮箱", "点击链接完成验证:\nhttps://example.com/verify?token=abc")
+	if err != nil {
+		t.Fatalf("SendEmail failed: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	if messages[0].From != "noreply@example.com" {
+		t.Fatalf("unexpected sender %q", messages[0].From)
+	}
+	if len(messages[0].To) != 1 || messages[0].To[0] != "user@example.com" {
+		t.Fatalf("unexpected recipients %v", messages[0].To)
+	}
+	msg, err := netmail.ReadMessage(bytes.NewReader(messages[0].Data))
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "验证邮箱" {
+		t.Fatalf("unexpected subject %q: %v", subject, err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatalf("read body failed: %v", err)
+	}
+	if !bytes.Contains(body, []byte("https://example.com/verify?token=abc")) {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestSMTPSenderAuthFailed(t *testing.T) {
+	server := mailtest.NewServerWithAuth("sender", "secret")
+	defer server.Close()
+
+	sender := NewSMTPSender(Config{
+		Host:     server.Host(),
+		Port:     server.Port(),
+		Username: "sender",
+		Password: "wrong",
+		From:     "noreply@example.com",
+	})
+	if err := sender.SendEmail(context.Background(), "user@example.com", "subject", "body"); err == nil {
+		t.Fatal("expected auth error")
+	}
+	if len(server.Messages()) != 0 {
+		t.Fatal("expected no message")
+	}
+}
+
+func TestSMTPSenderInvalidAddress(t *testing.T) {
+	sender := NewSMTPSender(Config{Host: "127.0.0.1", From: "noreply@example.com"})
+	err := sender.SendEmail(context.Background(), "user@example.com\r\nBcc: other@example.com", "subject", "body")
+	if !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("expected ErrInvalidAddress, got %v", err)
+	}
+}
+
+func TestLogSender(t *testing.T) {
+	sender := NewLogSender()
+	if err := sender.SendEmail(context.Background(), "user@example.com", "subject", "body"); err != nil {
+		t.Fatalf("SendEmail failed: %v", err)
+	}
+	msg, ok := sender.LastMessage("user@example.com")
+	if !ok || msg.Subject != "subject" || msg.Body != "body" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	if _, ok = sender.LastMessage("other@example.com"); ok {
+		t.Fatal("expected no message")
+	}
+}
@@ -0,0 +1,174 @@
+// Package mailtest provides a local stand-in SMTP server for tests.
+package mailtest
+
+import (
+	"encoding/base64"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Message struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// Server accepts mail over plain SMTP and keeps it in memory. AUTH PLAIN is offered when
+// credentials are configured, STARTTLS is never offered.
+type Server struct {
+	listener net.Listener
+	username string
+	password string
+
+	mu       sync.Mutex
+	messages []Message
+	wg       sync.WaitGroup
+}
+
+func NewServer() *Server {
+	return NewServerWithAuth("", "")
+}
+
+func NewServerWithAuth(username string, password string) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{
+		listener: listener,
+		username: username,
+		password: password,
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host
+}
+
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// Messages returns the messages received so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *Server) Close() {
+	_ = s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(code int, lines ...string) {
+		for i, line := range lines {
+			sep := "-"
+			if i == len(lines)-1 {
+				sep = " "
+			}
+			_ = tp.PrintfLine("%d%s%s", code, sep, line)
+		}
+	}
+	reply(220, "mailtest ESMTP")
+
+	authed := s.username == ""
+	var current Message
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if s.username != "" {
+				reply(250, "mailtest", "8BITMIME", "AUTH PLAIN")
+			} else {
+				reply(250, "mailtest", "8BITMIME")
+			}
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			if !strings.EqualFold(mechanism, "PLAIN") {
+				reply(504, "unsupported mechanism")
+				continue
+			}
+			raw, dErr := base64.StdEncoding.DecodeString(initial)
+			parts := strings.Split(string(raw), "\x00")
+			if dErr != nil || len(parts) != 3 || parts[1] != s.username || parts[2] != s.password {
+				reply(535, "authentication failed")
+				continue
+			}
+			authed = true
+			reply(235, "authenticated")
+		case "MAIL":
+			if !authed {
+				reply(530, "authentication required")
+				continue
+			}
+			current = Message{From: trimPath(arg, "FROM:")}
+			reply(250, "ok")
+		case "RCPT":
+			current.To = append(current.To, trimPath(arg, "TO:"))
+			reply(250, "ok")
+		case "DATA":
+			reply(354, "end data with <CR><LF>.<CR><LF>")
+			data, rErr := io.ReadAll(tp.DotReader())
+			if rErr != nil {
+				return
+			}
+			current.Data = data
+			s.mu.Lock()
+			s.messages = append(s.messages, current)
+			s.mu.Unlock()
+			current = Message{}
+			reply(250, "queued")
+		case "RSET":
+			current = Message{}
+			reply(250, "ok")
+		case "NOOP":
+			reply(250, "ok")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "command not implemented")
+		}
+	}
+}
+
+func trimPath(arg string, prefix string) string {
+	if len(arg) >= len(prefix) && strings.EqualFold(arg[:len(prefix)], prefix) {
+		arg = arg[len(prefix):]
+	}
+	arg, _, _ = strings.Cut(strings.TrimSpace(arg), " ")
+	return strings.Trim(arg, "<>")
+}
@@ -0,0 +1,128 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc"
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc/oidctest"
+)
+
+func newTestProvider(t *testing.T) (*oidctest.Provider, *oidc.Provider) {
+	t.Helper()
+	idp := oidctest.NewProvider()
+	t.Cleanup(idp.Close)
+	return idp, oidc.NewProvider(oidc.Config{
+		Issuer:      idp.Issuer(),
+		ClientID:    "dash",
+		RedirectURL: "http://localhost/callback",
+	}, nil)
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp, provider := newTestProvider(t)
+	ctx := context.Background()
+
+	state, nonce, verifier := oidc.NewState(), oidc.NewState(), oidc.NewState()
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	code, gotState, err := idp.Authorize(authURL, map[string]any{
+		"sub":    "user-1",
+		"groups": []string{"ops", "dev"},
+	})
+	if err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	if gotState != state {
+		t.Fatalf("expected state %q, got %q", state, gotState)
+	}
+
+	if _, err = provider.Exchange(ctx, code, oidc.NewState(), nonce); err == nil {
+		t.Fatal("expected exchange with wrong verifier to fail")
+	}
+
+	code, _, _ = idp.Authorize(authURL, map[string]any{"sub": "user-1", "groups": []string{"ops", "dev"}})
+	token, err := provider.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if token.Subject != "user-1" {
+		t.Fatalf("expected subject user-1, got %q", token.Subject)
+	}
+	if groups := token.Strings("groups"); len(groups) != 2 || groups[0] != "ops" {
+		t.Fatalf("unexpected groups claim: %v", groups)
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	idp, provider := newTestProvider(t)
+	ctx := context.Background()
+	now := time.Now()
+	valid := map[string]any{
+		"iss":   idp.Issuer(),
+		"aud":   "dash",
+		"sub":   "user-1",
+		"nonce": "n",
+		"exp":   now.Add(time.Minute).Unix(),
+	}
+
+	cases := map[string]func(claims map[string]any){
+		"issuer":   func(c map[string]any) { c["iss"] = "https://other.example.com" },
+		"audience": func(c map[string]any) { c["aud"] = "other" },
+		"expired":  func(c map[string]any) { c["exp"] = now.Add(-time.Hour).Unix() },
+		"nonce":    func(c map[string]any) { c["nonce"] = "other" },
+		"subject":  func(c map[string]any) { delete(c, "sub") },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			claims := make(map[string]any, len(valid))
+			for k, v := range valid {
+				claims[k] = v
+			}
+			mutate(claims)
+			raw, err := idp.Sign(claims)
+			if err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+			if _, err = provider.Verify(ctx, raw, "n"); !errors.Is(err, oidc.ErrInvalidToken) {
+				t.Fatalf("expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+
+	raw, _ := idp.Sign(valid)
+	if _, err := provider.Verify(ctx, raw[:len(raw)-4]+"abcd", "n"); !errors.Is(err, oidc.ErrInvalidToken) {
+		t.Fatalf("expected tampered signature to be rejected, got %v", err)
+	}
+	if _, err := provider.Verify(ctx, raw, "n"); err != nil {
+		t.Fatalf("expected valid token, got %v", err)
+	}
+}
+
+func TestVerifyLimitsKeyRefresh(t *testing.T) {
+	idp, provider := newTestProvider(t)
+	ctx := context.Background()
+	claims := map[string]any{
+		"iss": idp.Issuer(),
+		"aud": "dash",
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+	raw, _ := idp.Sign(claims)
+	if _, err := provider.Verify(ctx, raw, ""); err != nil {
+		t.Fatalf("expected valid token, got %v", err)
+	}
+	for range 5 {
+		forged, _ := idp.SignWithKeyID("unknown", claims)
+		if _, err := provider.Verify(ctx, forged, ""); !errors.Is(err, oidc.ErrInvalidToken) {
+			t.Fatalf("expected unknown key to be rejected, got %v", err)
+		}
+	}
+	if n := idp.JwksRequests(); n != 1 {
+		t.Fatalf("expected keys to be fetched once within the refresh interval, got %d", n)
+	}
+}
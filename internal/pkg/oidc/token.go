@@ -0,0 +1,180 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	clockSkew = time.Minute
+	// keysRefreshInterval limits how often an unknown key id can trigger a JWKS fetch,
+	// so forged tokens cannot be used to flood the identity provider.
+	keysRefreshInterval = time.Minute
+)
+
+// IDToken is a verified ID token. Claims holds every claim of the token.
+type IDToken struct {
+	Subject string
+	Claims  map[string]any
+}
+
+// String returns a string claim, or "" if it is missing or not a string.
+func (t *IDToken) String(name string) string {
+	value, _ := t.Claims[name].(string)
+	return value
+}
+
+// Strings returns a claim which may be a single string or an array of strings.
+func (t *IDToken) Strings(name string) []string {
+	switch value := t.Claims[name].(type) {
+	case string:
+		return []string{value}
+	case []any:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type keySet struct {
+	keys map[string]*rsa.PublicKey
+}
+
+func (p *Provider) loadKeys(ctx context.Context, refresh bool) (*keySet, error) {
+	doc, err := p.loadDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil && (!refresh || time.Since(p.keysAt) < keysRefreshInterval) {
+		return p.keys, nil
+	}
+	var raw struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = p.getJSON(ctx, doc.JwksURI, &raw); err != nil {
+		return nil, err
+	}
+	set := &keySet{keys: make(map[string]*rsa.PublicKey, len(raw.Keys))}
+	for _, key := range raw.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		n, nErr := base64.RawURLEncoding.DecodeString(key.N)
+		e, eErr := base64.RawURLEncoding.DecodeString(key.E)
+		if nErr != nil || eErr != nil {
+			continue
+		}
+		set.keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	p.keys = set
+	p.keysAt = time.Now()
+	return p.keys, nil
+}
+
+// Verify checks the signature, issuer, audience, expiry and nonce of an RS256 signed ID token.
+func (p *Provider) Verify(ctx context.Context, raw, nonce string) (*IDToken, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	keys, err := p.loadKeys(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys.keys[header.Kid]
+	if !ok {
+		// The provider may have rotated its keys since they were cached.
+		if keys, err = p.loadKeys(ctx, true); err != nil {
+			return nil, err
+		}
+		if key, ok = keys.keys[header.Kid]; !ok {
+			return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, header.Kid)
+		}
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	claims := make(map[string]any)
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	token := &IDToken{Claims: claims}
+	token.Subject = token.String("sub")
+	if token.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	if token.String("iss") != p.config.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch", ErrInvalidToken)
+	}
+	audienceOK := false
+	for _, aud := range token.Strings("aud") {
+		if aud == p.config.ClientID {
+			audienceOK = true
+			break
+		}
+	}
+	if !audienceOK {
+		return nil, fmt.Errorf("%w: audience mismatch", ErrInvalidToken)
+	}
+	exp, _ := claims["exp"].(float64)
+	if time.Unix(int64(exp), 0).Add(clockSkew).Before(time.Now()) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if nonce != "" && token.String("nonce") != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	return token, nil
+}
+
+func decodeSegment(segment string, value any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	if err = json.Unmarshal(raw, value); err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	return nil
+}
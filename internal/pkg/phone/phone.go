@@ -0,0 +1,74 @@
+// Package phone normalises phone numbers to the E.164 format used as user platform id.
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidNumber = errors.New("phone: invalid number")
+
+const (
+	minDigits = 7
+	maxDigits = 15
+)
+
+// Normalize returns the number in E.164 format, e.g. +8613800138000. Numbers starting with + or 00
+// are international, others are national numbers of countryCode. Spaces, dashes, dots and
+// parentheses are ignored.
+func Normalize(number string, countryCode string) (string, error) {
+	var digits strings.Builder
+	international := false
+	for i, r := range strings.TrimSpace(number) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+			international = true
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidNumber
+		}
+	}
+	value := digits.String()
+	if !international {
+		if rest, ok := strings.CutPrefix(value, "00"); ok {
+			value = rest
+		} else {
+			code := strings.TrimPrefix(countryCode, "+")
+			if code == "" || !isDigits(code) {
+				return "", ErrInvalidNumber
+			}
+			// 国内号码的长途前缀 0 不属于 E.164, 意大利号码除外
+			if code != "39" {
+				value = strings.TrimPrefix(value, "0")
+			}
+			value = code + value
+		}
+	}
+	if len(value) < minDigits || len(value) > maxDigits || value[0] == '0' {
+		return "", ErrInvalidNumber
+	}
+	return "+" + value, nil
+}
+
+// InCountries reports whether the E.164 number belongs to one of the country calling codes.
+// Calling codes are prefix free, so matching the prefix is enough.
+func InCountries(number string, countryCodes []string) bool {
+	for _, code := range countryCodes {
+		code = strings.TrimPrefix(code, "+")
+		if code != "" && strings.HasPrefix(number, "+"+code) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
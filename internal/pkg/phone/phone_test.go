@@ -0,0 +1,63 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		number      string
+		countryCode string
+		want        string
+	}{
+		{"13800138000", "86", "+8613800138000"},
+		{"138 0013 8000", "+86", "+8613800138000"},
+		{"+86 138-0013-8000", "1", "+8613800138000"},
+		{"0086 13800138000", "1", "+8613800138000"},
+		{"(202) 555-0123", "1", "+12025550123"},
+		{"07911 123456", "44", "+447911123456"},
+		{"06 1234 5678", "39", "+390612345678"},
+	}
+	for _, c := range cases {
+		got, err := Normalize(c.number, c.countryCode)
+		if err != nil {
+			t.Fatalf("Normalize(%q, %q) failed: %v", c.number, c.countryCode, err)
+		}
+		if got != c.want {
+			t.Fatalf("Normalize(%q, %q) = %q, want %q", c.number, c.countryCode, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	cases := []struct {
+		number      string
+		countryCode string
+	}{
+		{"", "86"},
+		{"1234", "86"},
+		{"+0123456789", "86"},
+		{"1380013800a", "86"},
+		{"138+00138000", "86"},
+		{"+1234567890123456", "86"},
+		{"13800138000", ""},
+	}
+	for _, c := range cases {
+		if _, err := Normalize(c.number, c.countryCode); !errors.Is(err, ErrInvalidNumber) {
+			t.Fatalf("Normalize(%q, %q) expected ErrInvalidNumber, got %v", c.number, c.countryCode, err)
+		}
+	}
+}
+
+func TestInCountries(t *testing.T) {
+	if !InCountries("+8613800138000", []string{"1", "86"}) {
+		t.Fatal("expected +86 number to be allowed")
+	}
+	if !InCountries("+12025550123", []string{"+1"}) {
+		t.Fatal("expected country code with + to match")
+	}
+	if InCountries("+447911123456", []string{"86", ""}) {
+		t.Fatal("expected +44 number to be rejected")
+	}
+}
@@ -24,6 +24,7 @@ func (r *Render) UserLite(value *ent.User) *sharedv1.User {
 		Username: value.Username,
 		Avatar:   r.storage.GenerateURL(value.Avatar),
 		Phone:    "",
+		Nickname: value.Nickname,
 	}
 }
 
@@ -33,6 +34,9 @@ func (r *Render) Admin(value *ent.Admin) *entpb.Admin {
 		return nil
 	}
 	val.Password = ""
+	val.TotpSecret = ""
+	val.RecoveryCodes = nil
+	val.PasswordHistory = nil
 	val.Avatar = r.storage.GenerateURL(value.Avatar)
 	return val
 }
@@ -41,11 +45,67 @@ func (r *Render) User(value *ent.User) *sharedv1.User {
 	return r.UserLite(value)
 }
 
+func (r *Render) UserDetail(value *ent.User) *entpb.User {
+	val, _ := entmap.ToProtoUser(value)
+	if val == nil {
+		return nil
+	}
+	val.Avatar = r.storage.GenerateURL(value.Avatar)
+	return val
+}
+
+func (r *Render) UserFlagRecord(value *ent.UserFlagRecord) *entpb.UserFlagRecord {
+	val, _ := entmap.ToProtoUserFlagRecord(value)
+	return val
+}
+
+func (r *Render) UserPlatform(value *ent.UserPlatform) *entpb.UserPlatform {
+	val, _ := entmap.ToProtoUserPlatform(value)
+	if val == nil {
+		return nil
+	}
+	val.PrivateKey = ""
+	return val
+}
+
+func (r *Render) UserSession(value *ent.UserSession) *entpb.UserSession {
+	val, _ := entmap.ToProtoUserSession(value)
+	if val == nil {
+		return nil
+	}
+	val.SessionKey = ""
+	return val
+}
+
+func (r *Render) AdminApiKey(value *ent.AdminApiKey) *entpb.AdminApiKey {
+	val, _ := entmap.ToProtoAdminApiKey(value)
+	if val == nil {
+		return nil
+	}
+	val.KeyHash = ""
+	return val
+}
+
 func (r *Render) AdminSession(value *ent.AdminSession) *entpb.AdminSession {
 	val, _ := entmap.ToProtoAdminSession(value)
 	return val
 }
 
+func (r *Render) Role(value *ent.Role) *entpb.Role {
+	val, _ := entmap.ToProtoRole(value)
+	return val
+}
+
+func (r *Render) Permission(value *ent.Permission) *entpb.Permission {
+	val, _ := entmap.ToProtoPermission(value)
+	return val
+}
+
+func (r *Render) AuditLog(value *ent.AuditLog) *entpb.AuditLog {
+	val, _ := entmap.ToProtoAuditLog(value)
+	return val
+}
+
 func (r *Render) KeyValueStore(value *ent.KeyValueStore) *entpb.KeyValueStore {
 	val, _ := entmap.ToProtoKeyValueStore(value)
 	return val
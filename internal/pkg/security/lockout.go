@@ -0,0 +1,31 @@
+package security
+
+import "time"
+
+// LockoutPolicy locks an account after MaxAttempts consecutive failures.
+// Every further failure doubles the lock duration, starting at BaseSeconds and capped at MaxSeconds.
+type LockoutPolicy struct {
+	MaxAttempts int   `json:"max_attempts" yaml:"max_attempts"`
+	BaseSeconds int64 `json:"base_seconds" yaml:"base_seconds"`
+	MaxSeconds  int64 `json:"max_seconds" yaml:"max_seconds"`
+}
+
+func (p LockoutPolicy) Enabled() bool {
+	return p.MaxAttempts > 0 && p.BaseSeconds > 0
+}
+
+// LockDuration returns how long the account should be locked after the given number of consecutive failures.
+func (p LockoutPolicy) LockDuration(failures int) time.Duration {
+	if !p.Enabled() || failures < p.MaxAttempts {
+		return 0
+	}
+	limit := p.MaxSeconds
+	if limit <= 0 {
+		limit = p.BaseSeconds
+	}
+	seconds := p.BaseSeconds
+	for i := p.MaxAttempts; i < failures && seconds < limit; i++ {
+		seconds *= 2
+	}
+	return time.Duration(min(seconds, limit)) * time.Second
+}
@@ -0,0 +1,81 @@
+package security
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PasswordPolicy describes the complexity rules a new password must satisfy.
+// Zero values disable the corresponding rule.
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length" yaml:"min_length"`
+	RequireUpper  bool `json:"require_upper" yaml:"require_upper"`
+	RequireLower  bool `json:"require_lower" yaml:"require_lower"`
+	RequireDigit  bool `json:"require_digit" yaml:"require_digit"`
+	RequireSymbol bool `json:"require_symbol" yaml:"require_symbol"`
+	HistorySize   int  `json:"history_size" yaml:"history_size"` // 不允许与最近 N 次使用过的密码相同
+}
+
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return strings.Join(e.Violations, ",")
+}
+
+// Validate checks the password against the policy and returns a *PasswordPolicyError listing every violated rule.
+func (p PasswordPolicy) Validate(password string) error {
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	var violations []string
+	if p.MinLength > 0 && len([]rune(password)) < p.MinLength {
+		violations = append(violations, "密码长度不能小于"+strconv.Itoa(p.MinLength)+"位")
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "密码必须包含大写字母")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "密码必须包含小写字母")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "密码必须包含数字")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "密码必须包含特殊字符")
+	}
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// NextHistory prepends the current password hash to the history and trims it to HistorySize.
+func (p PasswordPolicy) NextHistory(current string, history []string) []string {
+	if p.HistorySize <= 0 {
+		return []string{}
+	}
+	next := make([]string, 0, p.HistorySize)
+	if current != "" {
+		next = append(next, current)
+	}
+	for _, h := range history {
+		if len(next) >= p.HistorySize {
+			break
+		}
+		next = append(next, h)
+	}
+	return next
+}
@@ -0,0 +1,80 @@
+package security
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:    8,
+		RequireUpper: true,
+		RequireLower: true,
+		RequireDigit: true,
+	}
+	tests := []struct {
+		password   string
+		violations int
+	}{
+		{"aA1234567", 0},
+		{"aA1", 1},
+		{"abcdefgh", 2},
+		{"", 4},
+	}
+	for _, tt := range tests {
+		err := policy.Validate(tt.password)
+		if tt.violations == 0 {
+			if err != nil {
+				t.Errorf("Validate(%q) error = %v, want nil", tt.password, err)
+			}
+			continue
+		}
+		var pe *PasswordPolicyError
+		if !errors.As(err, &pe) {
+			t.Fatalf("Validate(%q) error = %v, want *PasswordPolicyError", tt.password, err)
+		}
+		if len(pe.Violations) != tt.violations {
+			t.Errorf("Validate(%q) violations = %v, want %d", tt.password, pe.Violations, tt.violations)
+		}
+	}
+	if err := (PasswordPolicy{RequireSymbol: true}).Validate("abc!"); err != nil {
+		t.Errorf("Validate() with symbol error = %v", err)
+	}
+}
+
+func TestPasswordPolicy_NextHistory(t *testing.T) {
+	policy := PasswordPolicy{HistorySize: 3}
+	got := policy.NextHistory("d", []string{"c", "b", "a"})
+	if want := []string{"d", "c", "b"}; !slices.Equal(got, want) {
+		t.Errorf("NextHistory() = %v, want %v", got, want)
+	}
+	if got = (PasswordPolicy{}).NextHistory("d", []string{"c"}); len(got) != 0 {
+		t.Errorf("NextHistory() without history = %v, want empty", got)
+	}
+}
+
+func TestLockoutPolicy_LockDuration(t *testing.T) {
+	policy := LockoutPolicy{MaxAttempts: 3, BaseSeconds: 60, MaxSeconds: 300}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := policy.LockDuration(tt.failures); got != tt.want {
+			t.Errorf("LockDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+	if got := (LockoutPolicy{}).LockDuration(100); got != 0 {
+		t.Errorf("LockDuration() with disabled policy = %v, want 0", got)
+	}
+}
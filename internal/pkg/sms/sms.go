@@ -0,0 +1,63 @@
+// Package sms provides helpers for verification codes sent by SMS.
+package sms
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"sync"
+
+	"github.com/go-sphere/sphere/log"
+)
+
+// NewCode returns a random numeric code of the given length.
+func NewCode(length int) (string, error) {
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		code[i] = byte('0' + n.Int64())
+	}
+	return string(code), nil
+}
+
+// ProviderLog is the provider name of LogSender in the config.
+const ProviderLog = "log"
+
+// LogSenderMaxCodes bounds the codes kept by LogSender, the oldest phone is dropped first.
+const LogSenderMaxCodes = 1000
+
+// LogSender writes codes to the log instead of sending them, for local development and tests.
+type LogSender struct {
+	mu     sync.Mutex
+	codes  map[string]string
+	phones []string
+}
+
+func NewLogSender() *LogSender {
+	return &LogSender{codes: make(map[string]string)}
+}
+
+func (s *LogSender) SendCode(ctx context.Context, phone string, code string) error {
+	log.Info("sms code", log.Any("phone", phone), log.Any("code", code))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.codes[phone]; !ok {
+		if len(s.phones) >= LogSenderMaxCodes {
+			delete(s.codes, s.phones[0])
+			s.phones = s.phones[1:]
+		}
+		s.phones = append(s.phones, phone)
+	}
+	s.codes[phone] = code
+	return nil
+}
+
+// LastCode returns the last code sent to the phone.
+func (s *LogSender) LastCode(phone string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.codes[phone]
+}
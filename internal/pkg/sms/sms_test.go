@@ -0,0 +1,60 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestNewCode(t *testing.T) {
+	seen := make(map[string]struct{})
+	for i := 0; i < 20; i++ {
+		code, err := NewCode(6)
+		if err != nil {
+			t.Fatalf("NewCode failed: %v", err)
+		}
+		if len(code) != 6 {
+			t.Fatalf("expected 6 digits, got %q", code)
+		}
+		for _, c := range code {
+			if c < '0' || c > '9' {
+				t.Fatalf("expected digits only, got %q", code)
+			}
+		}
+		seen[code] = struct{}{}
+	}
+	if len(seen) < 2 {
+		t.Fatal("expected random codes")
+	}
+}
+
+func TestLogSender(t *testing.T) {
+	sender := NewLogSender()
+	if err := sender.SendCode(context.Background(), "13800138000", "123456"); err != nil {
+		t.Fatalf("SendCode failed: %v", err)
+	}
+	if got := sender.LastCode("13800138000"); got != "123456" {
+		t.Fatalf("expected last code 123456, got %q", got)
+	}
+	if got := sender.LastCode("13900139000"); got != "" {
+		t.Fatalf("expected no code, got %q", got)
+	}
+}
+
+func TestLogSenderBounded(t *testing.T) {
+	sender := NewLogSender()
+	for i := 0; i <= LogSenderMaxCodes; i++ {
+		if err := sender.SendCode(context.Background(), fmt.Sprintf("1380000%04d", i), "123456"); err != nil {
+			t.Fatalf("SendCode failed: %v", err)
+		}
+	}
+	if got := sender.LastCode("13800000000"); got != "" {
+		t.Fatalf("expected the oldest code to be dropped, got %q", got)
+	}
+	if got := sender.LastCode(fmt.Sprintf("1380000%04d", LogSenderMaxCodes)); got != "123456" {
+		t.Fatalf("expected the newest code, got %q", got)
+	}
+	if len(sender.codes) != LogSenderMaxCodes {
+		t.Fatalf("expected %d codes, got %d", LogSenderMaxCodes, len(sender.codes))
+	}
+}
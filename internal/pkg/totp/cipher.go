@@ -0,0 +1,63 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrInvalidCiphertext = errors.New("totp: invalid ciphertext")
+	ErrEmptyPassphrase   = errors.New("totp: empty passphrase")
+)
+
+// Cipher encrypts secrets before they are persisted, so a database leak does not expose them.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates an AES-GCM cipher whose key is derived from the given passphrase.
+// An empty passphrase is rejected, otherwise every secret would be encrypted under a well known key.
+func NewCipher(passphrase string) (*Cipher, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+func (c *Cipher) Encrypt(plain string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Decrypt(text string) (string, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(text)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	size := c.aead.NonceSize()
+	if len(raw) < size {
+		return "", ErrInvalidCiphertext
+	}
+	plain, err := c.aead.Open(nil, raw[:size], raw[size:], nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plain), nil
+}
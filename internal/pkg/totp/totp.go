@@ -0,0 +1,134 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPeriod = 30
+	DefaultDigits = 6
+	DefaultSkew   = 1
+
+	secretSize = 20
+)
+
+var (
+	ErrInvalidSecret = errors.New("totp: invalid secret")
+	secretEncoding   = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// GenerateSecret creates a new random base32 encoded secret suitable for authenticator apps.
+func GenerateSecret() (string, error) {
+	raw := make([]byte, secretSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return secretEncoding.EncodeToString(raw), nil
+}
+
+// GenerateCode returns the code for the given secret at time t.
+func GenerateCode(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(t.Unix()/DefaultPeriod), DefaultDigits), nil
+}
+
+// Validate reports whether code matches the secret at time t, allowing DefaultSkew periods of clock drift.
+func Validate(code, secret string, t time.Time) bool {
+	_, ok := ValidateCounter(code, secret, t)
+	return ok
+}
+
+// ValidateCounter works like Validate and also returns the time step the code belongs to.
+// Callers remember the last accepted step to reject a code which is replayed within its validity window.
+func ValidateCounter(code, secret string, t time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != DefaultDigits {
+		return 0, false
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+	counter := t.Unix() / DefaultPeriod
+	for i := -DefaultSkew; i <= DefaultSkew; i++ {
+		step := counter + int64(i)
+		expected := hotp(key, uint64(step), DefaultDigits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// KeyURI builds an otpauth:// URI which can be rendered as a QR code for enrollment.
+func KeyURI(issuer, account, secret string) string {
+	label := account
+	if issuer != "" {
+		label = issuer + ":" + account
+	}
+	query := url.Values{}
+	query.Set("secret", secret)
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(DefaultDigits))
+	query.Set("period", fmt.Sprint(DefaultPeriod))
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+// GenerateRecoveryCodes creates n single-use recovery codes formatted as xxxxx-xxxxx.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	raw := make([]byte, 7)
+	for range n {
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(secretEncoding.EncodeToString(raw))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	key, err := secretEncoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+func hotp(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
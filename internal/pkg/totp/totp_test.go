@@ -0,0 +1,109 @@
+package totp
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateCode(t *testing.T) {
+	// RFC 6238 Appendix B test vectors (SHA1), truncated to 6 digits.
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := GenerateCode(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("GenerateCode() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("GenerateCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	now := time.Now()
+	code, err := GenerateCode(secret, now)
+	if err != nil {
+		t.Fatalf("GenerateCode() error = %v", err)
+	}
+	if !Validate(code, secret, now) {
+		t.Errorf("Validate() = false for current code")
+	}
+	if !Validate(code, secret, now.Add(DefaultPeriod*time.Second)) {
+		t.Errorf("Validate() = false for code within skew")
+	}
+	if Validate(code, secret, now.Add(DefaultPeriod*3*time.Second)) {
+		t.Errorf("Validate() = true for code outside skew")
+	}
+	if Validate("abc", secret, now) {
+		t.Errorf("Validate() = true for malformed code")
+	}
+	step, ok := ValidateCounter(code, secret, now.Add(DefaultPeriod*time.Second))
+	if !ok || step != now.Unix()/DefaultPeriod {
+		t.Errorf("ValidateCounter() = %d, %v, want %d, true", step, ok, now.Unix()/DefaultPeriod)
+	}
+}
+
+func TestCipher(t *testing.T) {
+	if _, err := NewCipher(""); !errors.Is(err, ErrEmptyPassphrase) {
+		t.Fatalf("NewCipher(\"\") error = %v, want ErrEmptyPassphrase", err)
+	}
+	c, err := NewCipher("test-passphrase")
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+	enc, err := c.Encrypt("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if strings.Contains(enc, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("Encrypt() leaked plaintext: %s", enc)
+	}
+	dec, err := c.Decrypt(enc)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if dec != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Decrypt() = %s, want JBSWY3DPEHPK3PXP", dec)
+	}
+	other, _ := NewCipher("other-passphrase")
+	if _, err = other.Decrypt(enc); err == nil {
+		t.Errorf("Decrypt() with wrong key should fail")
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("GenerateRecoveryCodes() len = %d, want 10", len(codes))
+	}
+	seen := make(map[string]struct{})
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("unexpected recovery code format: %s", code)
+		}
+		seen[code] = struct{}{}
+	}
+	if len(seen) != len(codes) {
+		t.Errorf("GenerateRecoveryCodes() returned duplicates")
+	}
+}
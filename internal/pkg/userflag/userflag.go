@@ -0,0 +1,74 @@
+// Package userflag defines the bits stored in User.flags.
+// Bits are persisted, so never reuse or reorder them; append new flags at the end.
+package userflag
+
+import (
+	"strconv"
+)
+
+const (
+	Banned uint64 = 1 << iota
+	Muted
+	Verified
+)
+
+const (
+	NameBanned   = "banned"
+	NameMuted    = "muted"
+	NameVerified = "verified"
+)
+
+type Flag struct {
+	Bit         uint64 `json:"bit"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+var registry = []Flag{
+	{Bit: Banned, Name: NameBanned, Description: "封禁, 禁止登录和使用已签发的令牌"},
+	{Bit: Muted, Name: NameMuted, Description: "禁言"},
+	{Bit: Verified, Name: NameVerified, Description: "已认证"},
+}
+
+// All returns the registered flags ordered by bit.
+func All() []Flag {
+	return append([]Flag(nil), registry...)
+}
+
+func Lookup(name string) (Flag, bool) {
+	for _, f := range registry {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Flag{}, false
+}
+
+func Has(flags uint64, flag uint64) bool {
+	return flags&flag != 0
+}
+
+// Unknown returns the bits of flags which are not registered.
+func Unknown(flags uint64) uint64 {
+	for _, f := range registry {
+		flags &^= f.Bit
+	}
+	return flags
+}
+
+// Names returns the names of the registered flags set in flags.
+func Names(flags uint64) []string {
+	var names []string
+	for _, f := range registry {
+		if Has(flags, f.Bit) {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+// CacheKey is the key under which the API server caches the effective flags of a user.
+// Dash deletes it whenever it changes the flags so the change applies at once.
+func CacheKey(uid int64) string {
+	return "user_flags:" + strconv.FormatInt(uid, 10)
+}
@@ -0,0 +1,43 @@
+package userflag
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	seen := make(map[uint64]string)
+	for _, f := range All() {
+		if f.Bit == 0 || f.Bit&(f.Bit-1) != 0 {
+			t.Fatalf("flag %q must be a single bit, got %b", f.Name, f.Bit)
+		}
+		if other, ok := seen[f.Bit]; ok {
+			t.Fatalf("flags %q and %q share bit %b", f.Name, other, f.Bit)
+		}
+		seen[f.Bit] = f.Name
+		if got, ok := Lookup(f.Name); !ok || got.Bit != f.Bit {
+			t.Fatalf("Lookup(%q) = %+v, %v", f.Name, got, ok)
+		}
+	}
+	if _, ok := Lookup("unknown"); ok {
+		t.Fatal("expected unknown flag lookup to fail")
+	}
+}
+
+func TestNames(t *testing.T) {
+	if got := Names(Banned | Verified | 1<<63); !slices.Equal(got, []string{"banned", "verified"}) {
+		t.Fatalf("unexpected names: %v", got)
+	}
+	if got := Names(0); len(got) != 0 {
+		t.Fatalf("expected no names, got %v", got)
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	if got := Unknown(Banned | Muted | Verified); got != 0 {
+		t.Fatalf("expected registered flags to be known, got %b", got)
+	}
+	if got := Unknown(Banned | 1<<63); got != 1<<63 {
+		t.Fatalf("expected unregistered bit to be reported, got %b", got)
+	}
+}
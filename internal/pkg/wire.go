@@ -3,10 +3,13 @@ package pkg
 import (
 	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/client"
+	"github.com/go-sphere/sphere-layout/internal/pkg/wxapp"
 	"github.com/google/wire"
 )
 
 var ProviderSet = wire.NewSet(
 	dao.NewDao,
 	client.NewDataBaseClient,
+	wxapp.NewApps,
+	wire.FieldsOf(new(wxapp.Config), "Config"),
 )
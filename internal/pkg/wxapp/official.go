@@ -0,0 +1,90 @@
+package wxapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OfficialAccountAPI is the base URL of the web authorization API of official accounts.
+const OfficialAccountAPI = "https://api.weixin.qq.com"
+
+var ErrOAuthFailed = errors.New("wxapp: official account oauth failed")
+
+type OfficialAccountConfig struct {
+	AppID     string `json:"app_id" yaml:"app_id"`
+	AppSecret string `json:"app_secret" yaml:"app_secret"`
+	// BaseURL replaces OfficialAccountAPI, for proxies and tests.
+	BaseURL string `json:"base_url" yaml:"base_url"`
+}
+
+// OAuthResult is the user of a web authorization, UnionID is only set when the official account
+// is bound to an open platform account.
+type OAuthResult struct {
+	OpenID  string `json:"openid"`
+	UnionID string `json:"unionid"`
+	Scope   string `json:"scope"`
+}
+
+// OfficialAccount exchanges the codes of the web authorization (snsapi_base or snsapi_userinfo)
+// of an official account, which mini programs log in with JsCode2Session instead.
+type OfficialAccount struct {
+	config OfficialAccountConfig
+	client *http.Client
+}
+
+func NewOfficialAccount(config OfficialAccountConfig, client *http.Client) *OfficialAccount {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = OfficialAccountAPI
+	}
+	return &OfficialAccount{config: config, client: client}
+}
+
+func (a *OfficialAccount) AppID() string {
+	return a.config.AppID
+}
+
+// ExchangeCode trades the code the web page received after the authorization for the openid of the user.
+func (a *OfficialAccount) ExchangeCode(ctx context.Context, code string) (*OAuthResult, error) {
+	query := url.Values{}
+	query.Set("appid", a.config.AppID)
+	query.Set("secret", a.config.AppSecret)
+	query.Set("code", code)
+	query.Set("grant_type", "authorization_code")
+	endpoint := strings.TrimRight(a.config.BaseURL, "/") + "/sns/oauth2/access_token?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrOAuthFailed, resp.StatusCode)
+	}
+	var body struct {
+		OAuthResult
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOAuthFailed, err)
+	}
+	if body.ErrCode != 0 {
+		return nil, fmt.Errorf("%w: %d %s", ErrOAuthFailed, body.ErrCode, body.ErrMsg)
+	}
+	if body.OpenID == "" {
+		return nil, fmt.Errorf("%w: missing openid", ErrOAuthFailed)
+	}
+	return &body.OAuthResult, nil
+}
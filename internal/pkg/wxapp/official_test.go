@@ -0,0 +1,83 @@
+package wxapp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestOfficialAccount(t *testing.T, handler http.HandlerFunc) *OfficialAccount {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewOfficialAccount(OfficialAccountConfig{
+		AppID:     "wx-oa",
+		AppSecret: "secret",
+		BaseURL:   server.URL,
+	}, server.Client())
+}
+
+func TestOfficialAccountExchangeCode(t *testing.T) {
+	account := newTestOfficialAccount(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/sns/oauth2/access_token" || query.Get("appid") != "wx-oa" || query.Get("secret") != "secret" ||
+			query.Get("code") != "code" || query.Get("grant_type") != "authorization_code" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		_, _ = w.Write([]byte(`{"access_token":"token","openid":"openid","unionid":"unionid","scope":"snsapi_base"}`))
+	})
+	result, err := account.ExchangeCode(context.Background(), "code")
+	if err != nil {
+		t.Fatalf("ExchangeCode failed: %v", err)
+	}
+	if result.OpenID != "openid" || result.UnionID != "unionid" || result.Scope != "snsapi_base" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestOfficialAccountExchangeCodeError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		payload string
+	}{
+		{"wechat error", http.StatusOK, `{"errcode":40029,"errmsg":"invalid code"}`},
+		{"missing openid", http.StatusOK, `{"access_token":"token"}`},
+		{"http error", http.StatusBadGateway, `{}`},
+		{"invalid body", http.StatusOK, `not json`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := newTestOfficialAccount(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.payload))
+			})
+			if _, err := account.ExchangeCode(context.Background(), "code"); !errors.Is(err, ErrOAuthFailed) {
+				t.Fatalf("expected ErrOAuthFailed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAppsOfficialAccount(t *testing.T) {
+	apps := &Apps{official: []*OfficialAccount{
+		NewOfficialAccount(OfficialAccountConfig{AppID: "wx-oa-1"}, nil),
+		NewOfficialAccount(OfficialAccountConfig{AppID: "wx-oa-2"}, nil),
+	}}
+	if account, ok := apps.OfficialAccount(""); !ok || account.AppID() != "wx-oa-1" {
+		t.Fatalf("expected the first account by default, got %v", account)
+	}
+	if account, ok := apps.OfficialAccount("wx-oa-2"); !ok || account.AppID() != "wx-oa-2" {
+		t.Fatalf("expected wx-oa-2, got %v", account)
+	}
+	if _, ok := apps.OfficialAccount("wx-oa-3"); ok {
+		t.Fatal("expected an unknown account not to be found")
+	}
+	var missing *Apps
+	if _, ok := missing.OfficialAccount(""); ok {
+		t.Fatal("expected no account without apps")
+	}
+}
@@ -0,0 +1,80 @@
+// Package wxapp holds the clients of the WeChat apps users can log in with.
+package wxapp
+
+import (
+	"github.com/go-sphere/sphere/cache/mcache"
+	"github.com/go-sphere/weixin-mp-api/wechat"
+)
+
+// Config is the default mini program, kept at the top level for existing configs, additional mini programs
+// and the official accounts. Apps bound to the same WeChat open platform account share the unionid of a user.
+type Config struct {
+	wechat.Config    `yaml:",inline"`
+	Apps             []wechat.Config         `json:"apps" yaml:"apps"`
+	OfficialAccounts []OfficialAccountConfig `json:"official_accounts" yaml:"official_accounts"`
+}
+
+type Apps struct {
+	mainID   string
+	main     *wechat.Wechat
+	apps     map[string]*wechat.Wechat
+	official []*OfficialAccount
+}
+
+func NewApps(main *wechat.Wechat, config Config) *Apps {
+	apps := make(map[string]*wechat.Wechat, len(config.Apps)+1)
+	apps[config.AppID] = main
+	for _, app := range config.Apps {
+		if _, ok := apps[app.AppID]; ok {
+			continue
+		}
+		// 每个应用的 access token 分开缓存
+		apps[app.AppID] = wechat.NewWechat(app, mcache.NewCache[string]())
+	}
+	official := make([]*OfficialAccount, 0, len(config.OfficialAccounts))
+	for _, account := range config.OfficialAccounts {
+		official = append(official, NewOfficialAccount(account, nil))
+	}
+	return &Apps{
+		mainID:   config.AppID,
+		main:     main,
+		apps:     apps,
+		official: official,
+	}
+}
+
+// MainAppID returns the app id of the default mini program.
+func (a *Apps) MainAppID() string {
+	if a == nil {
+		return ""
+	}
+	return a.mainID
+}
+
+// Get returns the client of the mini program and its app id, the default mini program when appID is empty.
+func (a *Apps) Get(appID string) (string, *wechat.Wechat, bool) {
+	if a == nil {
+		return "", nil, false
+	}
+	if appID == "" {
+		return a.mainID, a.main, a.main != nil
+	}
+	app, ok := a.apps[appID]
+	return appID, app, ok
+}
+
+// OfficialAccount returns the official account, the first configured one when appID is empty.
+func (a *Apps) OfficialAccount(appID string) (*OfficialAccount, bool) {
+	if a == nil || len(a.official) == 0 {
+		return nil, false
+	}
+	if appID == "" {
+		return a.official[0], true
+	}
+	for _, account := range a.official {
+		if account.AppID() == appID {
+			return account, true
+		}
+	}
+	return nil, false
+}
@@ -1,11 +1,49 @@
 package api
 
+import (
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth/oauth"
+	"github.com/go-sphere/sphere-layout/internal/pkg/mail"
+	"github.com/go-sphere/sphere-layout/internal/pkg/security"
+	"github.com/go-sphere/sphere-layout/internal/service/api"
+)
+
 type HTTPConfig struct {
 	Address string   `json:"address" yaml:"address"`
 	Cors    []string `json:"cors" yaml:"cors"`
 }
 
+type ImpersonationConfig struct {
+	// BlockWrites rejects non-GET requests made with a token issued by dash impersonation.
+	BlockWrites bool `json:"block_writes" yaml:"block_writes"`
+}
+
+type EmailConfig struct {
+	// SMTP is used to send emails, they are only written to the log when no host is configured.
+	SMTP           mail.Config             `json:"smtp" yaml:"smtp"`
+	Links          api.EmailLinks          `json:"links" yaml:"links"`
+	PasswordPolicy security.PasswordPolicy `json:"password_policy" yaml:"password_policy"`
+}
+
+type SmsConfig struct {
+	// Provider sends the login codes, SMS login is disabled when it is empty.
+	// "log" only writes the codes to the log and must not be used in production.
+	Provider string `json:"provider" yaml:"provider"`
+}
+
+type AccountDeletionConfig struct {
+	// GraceDays is how long a user may cancel the deletion of the account.
+	GraceDays int `json:"grace_days" yaml:"grace_days"`
+}
+
 type Config struct {
-	JWT  string     `json:"jwt" yaml:"jwt"`
-	HTTP HTTPConfig `json:"http" yaml:"http"`
+	JWT             string                `json:"jwt" yaml:"jwt"`
+	RefreshJWT      string                `json:"refresh_jwt" yaml:"refresh_jwt"`
+	HTTP            HTTPConfig            `json:"http" yaml:"http"`
+	Impersonation   ImpersonationConfig   `json:"impersonation" yaml:"impersonation"`
+	Email           EmailConfig           `json:"email" yaml:"email"`
+	Sms             SmsConfig             `json:"sms" yaml:"sms"`
+	Phone           api.PhoneRegions      `json:"phone" yaml:"phone"`
+	AccountDeletion AccountDeletionConfig `json:"account_deletion" yaml:"account_deletion"`
+	// OAuth are the enabled third party logins, the key is the provider and user platform name.
+	OAuth map[string]oauth.ProviderConfig `json:"oauth" yaml:"oauth"`
 }
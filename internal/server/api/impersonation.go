@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-sphere/httpx"
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
+	"github.com/go-sphere/sphere-layout/internal/service/api"
+	"github.com/go-sphere/sphere/server/auth/authorizer"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
+	authmw "github.com/go-sphere/sphere/server/middleware/auth"
+)
+
+type ImpersonationRecorder = func(ctx context.Context, request api.ImpersonatedRequest) error
+
+// NewImpersonationMiddleware records every write made with an impersonation token and
+// rejects it when blockWrites is set. Invalid tokens are left to the auth middleware.
+func NewImpersonationMiddleware(parser authorizer.Parser[int64, *jwtauth.RBACClaims[int64]], blockWrites bool, recorder ImpersonationRecorder) httpx.Middleware {
+	return func(ctx httpx.Context) error {
+		if ctx.Method() == http.MethodGet || ctx.Method() == http.MethodHead || ctx.Method() == http.MethodOptions {
+			return ctx.Next()
+		}
+		token := strings.TrimSpace(strings.TrimPrefix(ctx.Header(authmw.AuthorizationHeader), authmw.AuthorizationPrefixBearer))
+		if token == "" {
+			return ctx.Next()
+		}
+		claims, err := parser.ParseToken(ctx.Context(), token)
+		if err != nil {
+			return ctx.Next()
+		}
+		impersonator, ok := auth.Impersonator(claims)
+		if !ok {
+			return ctx.Next()
+		}
+		operation := ctx.FullPath()
+		if operation == "" {
+			operation = ctx.Path()
+		}
+		err = recorder(ctx.Context(), api.ImpersonatedRequest{
+			Impersonator: impersonator,
+			UserID:       claims.UID,
+			Operation:    ctx.Method() + " " + operation,
+			IPAddress:    ctx.ClientIP(),
+			UserAgent:    ctx.Header("User-Agent"),
+			Blocked:      blockWrites,
+		})
+		if err != nil {
+			return err
+		}
+		if blockWrites {
+			return apiv1.AuthError_AUTH_ERROR_IMPERSONATION_READ_ONLY
+		}
+		return ctx.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-sphere/httpx"
+	"github.com/go-sphere/sphere-layout/internal/service/api"
+	"github.com/go-sphere/sphere/server/auth/authorizer"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
+	authmw "github.com/go-sphere/sphere/server/middleware/auth"
+)
+
+func NewSessionMetaData() httpx.Middleware {
+	return func(ctx httpx.Context) error {
+		ctx.Set(api.AuthContextKeyIP, ctx.ClientIP())
+		ctx.Set(api.AuthContextKeyUA, ctx.Header("User-Agent"))
+		return ctx.Next()
+	}
+}
+
+type AccessTokenChecker = func(ctx context.Context, claims *jwtauth.RBACClaims[int64]) error
+
+// NewAccessTokenCheckMiddleware rejects bearer tokens which are still valid but may no longer be used,
+// e.g. of a revoked device or a banned user, and stores the claims for the service.
+// Invalid tokens are left to the auth middleware, which does not abort on the API server.
+func NewAccessTokenCheckMiddleware(parser authorizer.Parser[int64, *jwtauth.RBACClaims[int64]], checker AccessTokenChecker) httpx.Middleware {
+	return func(ctx httpx.Context) error {
+		token := strings.TrimSpace(strings.TrimPrefix(ctx.Header(authmw.AuthorizationHeader), authmw.AuthorizationPrefixBearer))
+		if token == "" {
+			return ctx.Next()
+		}
+		claims, err := parser.ParseToken(ctx.Context(), token)
+		if err != nil {
+			return ctx.Next()
+		}
+		if err = checker(ctx.Context(), claims); err != nil {
+			return err
+		}
+		ctx.Set(api.AuthContextKeyClaims, claims)
+		return ctx.Next()
+	}
+}
@@ -2,11 +2,16 @@ package api
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/go-sphere/httpx"
 	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
 	sharedv1 "github.com/go-sphere/sphere-layout/api/shared/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth/oauth"
 	"github.com/go-sphere/sphere-layout/internal/pkg/httpsrv"
+	"github.com/go-sphere/sphere-layout/internal/pkg/mail"
+	"github.com/go-sphere/sphere-layout/internal/pkg/sms"
 	"github.com/go-sphere/sphere-layout/internal/service/api"
 	"github.com/go-sphere/sphere-layout/internal/service/shared"
 	"github.com/go-sphere/sphere/server/auth/jwtauth"
@@ -27,7 +32,7 @@ func NewWebServer(conf Config, storage storage.CDNStorage, service *api.Service)
 		config:    conf,
 		engine:    httpsrv.NewGinServer("api", conf.HTTP.Address),
 		service:   service,
-		sharedSvc: shared.NewService(storage, "user"),
+		sharedSvc: shared.NewService(storage, api.UserStorageDir),
 	}
 }
 
@@ -37,6 +42,7 @@ func (w *Web) Identifier() string {
 
 func (w *Web) Start(ctx context.Context) error {
 	jwtAuthorizer := jwtauth.NewJwtAuth[jwtauth.RBACClaims[int64]](w.config.JWT)
+	jwtRefresher := jwtauth.NewJwtAuth[jwtauth.RBACClaims[int64]](w.config.RefreshJWT)
 
 	authMiddleware := auth.NewAuthMiddleware[int64, jwtauth.RBACClaims[int64]](
 		jwtAuthorizer,
@@ -49,14 +55,42 @@ func (w *Web) Start(ctx context.Context) error {
 		w.engine.Use(cors.NewCORS(cors.WithAllowOrigins(w.config.HTTP.Cors...)))
 	}
 
-	w.service.Init(jwtAuthorizer)
+	w.service.Init(jwtAuthorizer, jwtRefresher)
+	// 未配置短信服务商时不启用短信登录, 接入服务商时在这里添加对应的 SmsSender 实现
+	switch w.config.Sms.Provider {
+	case "":
+	case sms.ProviderLog:
+		w.service.InitSms(sms.NewLogSender())
+	default:
+		return fmt.Errorf("unknown sms provider %q", w.config.Sms.Provider)
+	}
+	if w.config.Email.SMTP.Host != "" {
+		w.service.InitEmail(mail.NewSMTPSender(w.config.Email.SMTP), w.config.Email.Links, w.config.Email.PasswordPolicy)
+	} else {
+		w.service.InitEmail(mail.NewLogSender(), w.config.Email.Links, w.config.Email.PasswordPolicy)
+	}
+	w.service.InitPhoneRegions(w.config.Phone)
+	w.service.InitAccountDeletion(time.Duration(w.config.AccountDeletion.GraceDays) * time.Hour * 24)
+	oauthRegistry, err := oauth.NewRegistryFromConfig(w.config.OAuth, nil)
+	if err != nil {
+		return err
+	}
+	if err = w.service.InitOAuth(oauthRegistry); err != nil {
+		return err
+	}
 
-	route := w.engine.Group("/", authMiddleware)
+	route := w.engine.Group("/",
+		authMiddleware,
+		NewAccessTokenCheckMiddleware(jwtAuthorizer, w.service.CheckAccessToken),
+		NewSessionMetaData(),
+		NewImpersonationMiddleware(jwtAuthorizer, w.config.Impersonation.BlockWrites, w.service.RecordImpersonatedRequest),
+	)
 
 	sharedv1.RegisterStorageServiceHTTPServer(route, w.sharedSvc)
 	apiv1.RegisterAuthServiceHTTPServer(route, w.service)
 	apiv1.RegisterSystemServiceHTTPServer(route, w.service)
 	apiv1.RegisterUserServiceHTTPServer(route, w.service)
+	apiv1.RegisterUserSessionServiceHTTPServer(route, w.service)
 
 	return w.engine.Start()
 }
@@ -0,0 +1,26 @@
+package dash
+
+import (
+	"github.com/go-sphere/httpx"
+	"github.com/go-sphere/sphere-layout/internal/service/dash"
+	"github.com/go-sphere/sphere/server/httpz"
+	"github.com/go-sphere/sphere/server/middleware/selector"
+)
+
+// NewAuditOperationMiddleware tags requests of the given operations with their operation name,
+// so that the database mutations they make are recorded in the audit log.
+func NewAuditOperationMiddleware(basePath string, endpoints [][3]string, operations ...string) []httpx.Middleware {
+	var middlewares []httpx.Middleware
+	for _, operation := range operations {
+		middlewares = append(middlewares, selector.NewSelectorMiddleware(
+			selector.MatchFunc(
+				httpz.MatchOperation(basePath, endpoints, operation),
+			),
+			func(ctx httpx.Context) error {
+				ctx.Set(dash.AuditContextKeyOperation, operation)
+				return ctx.Next()
+			},
+		)...)
+	}
+	return middlewares
+}
@@ -1,13 +1,85 @@
 package dash
 
+import (
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc"
+	"github.com/go-sphere/sphere-layout/internal/pkg/security"
+	"github.com/go-sphere/sphere-layout/internal/service/dash"
+)
+
 type HTTPConfig struct {
 	Address string   `json:"address" yaml:"address"`
 	Cors    []string `json:"cors" yaml:"cors"`
 	Static  string   `json:"static" yaml:"static"`
 }
 
+type TOTPConfig struct {
+	Issuer    string `json:"issuer" yaml:"issuer"`
+	SecretKey string `json:"secret_key" yaml:"secret_key"`
+}
+
+type OIDCConfig struct {
+	Enabled  bool                 `json:"enabled" yaml:"enabled"`
+	Provider oidc.Config          `json:"provider" yaml:"provider"`
+	Login    dash.OidcLoginPolicy `json:"login" yaml:"login"`
+}
+
 type Config struct {
 	AuthJWT    string     `json:"auth_jwt" yaml:"auth_jwt"`
 	RefreshJWT string     `json:"refresh_jwt" yaml:"refresh_jwt"`
 	HTTP       HTTPConfig `json:"http" yaml:"http"`
+	TOTP       TOTPConfig `json:"totp" yaml:"totp"`
+	OIDC       OIDCConfig `json:"oidc" yaml:"oidc"`
+
+	PasswordPolicy security.PasswordPolicy `json:"password_policy" yaml:"password_policy"`
+	LoginLockout   security.LockoutPolicy  `json:"login_lockout" yaml:"login_lockout"`
+
+	Menus []PureMenu `json:"menus" yaml:"menus"`
+}
+
+// DefaultMenus returns the menus of the builtin dashboard modules.
+func DefaultMenus() []PureMenu {
+	return []PureMenu{
+		{
+			Path: "/user",
+			Meta: PureMenuMeta{Title: "用户管理", Icon: "ri:user-3-line", Rank: 9},
+			Children: []PureMenu{
+				{
+					Path:        "/user/index",
+					Name:        "User",
+					Meta:        PureMenuMeta{Title: "用户列表"},
+					Permissions: []string{dash.PermissionUserRead},
+				},
+			},
+		},
+		{
+			Path: "/system",
+			Meta: PureMenuMeta{Title: "系统管理", Icon: "ri:settings-3-line", Rank: 10},
+			Children: []PureMenu{
+				{
+					Path:        "/system/admin/index",
+					Name:        "SystemAdmin",
+					Meta:        PureMenuMeta{Title: "管理员"},
+					Permissions: []string{dash.PermissionAdminRead},
+				},
+				{
+					Path:        "/system/role/index",
+					Name:        "SystemRole",
+					Meta:        PureMenuMeta{Title: "角色管理"},
+					Permissions: []string{dash.PermissionAdminRead},
+				},
+				{
+					Path:        "/system/session/index",
+					Name:        "SystemSession",
+					Meta:        PureMenuMeta{Title: "会话管理"},
+					Permissions: []string{dash.PermissionAdminRead},
+				},
+				{
+					Path:        "/system/audit-log/index",
+					Name:        "SystemAuditLog",
+					Meta:        PureMenuMeta{Title: "审计日志"},
+					Permissions: []string{dash.PermissionAuditRead},
+				},
+			},
+		},
+	}
 }
@@ -0,0 +1,81 @@
+package dash
+
+import (
+	"sort"
+
+	"github.com/go-sphere/httpx"
+	sharedv1 "github.com/go-sphere/sphere-layout/api/shared/v1"
+	"github.com/go-sphere/sphere-layout/internal/service/dash"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
+	"github.com/go-sphere/sphere/server/httpz"
+	"github.com/go-sphere/sphere/server/middleware/selector"
+)
+
+type PermissionChecker = func(roles []string, resource string) bool
+
+// NewPermissionMiddleware checks the roles of the current token against the resource.
+// It relies on the claims stored by NewAccessTokenCheckMiddleware, so it must be placed after it.
+func NewPermissionMiddleware(resource string, checker PermissionChecker) httpx.Middleware {
+	return func(ctx httpx.Context) error {
+		value, ok := ctx.Get(dash.AuthContextKeyClaims)
+		if !ok {
+			return httpx.NewForbiddenError("permission denied")
+		}
+		claims, ok := value.(*jwtauth.RBACClaims[int64])
+		if !ok || !checker(claims.Roles, resource) {
+			return httpx.NewForbiddenError("permission denied")
+		}
+		return ctx.Next()
+	}
+}
+
+// permissionsStorageService covers the shared StorageService, which cannot carry the dash method option.
+var permissionsStorageService = map[string][]string{
+	sharedv1.OperationStorageServiceUploadToken: {dash.PermissionAuthenticated},
+}
+
+func denyPermission(ctx httpx.Context) error {
+	return httpx.NewForbiddenError("permission denied")
+}
+
+// NewOperationPermissionMiddleware enforces the per-RPC permissions declared with the (dash.v1.permissions)
+// method option. Operations missing from the table are denied, so every new RPC has to declare its permissions.
+func NewOperationPermissionMiddleware(basePath string, endpoints [][3]string, table map[string][]string, checker PermissionChecker) []httpx.Middleware {
+	var undeclared []string
+	for _, endpoint := range endpoints {
+		if _, ok := table[endpoint[0]]; !ok {
+			undeclared = append(undeclared, endpoint[0])
+		}
+	}
+	var middlewares []httpx.Middleware
+	if len(undeclared) > 0 {
+		middlewares = append(middlewares, selector.NewSelectorMiddleware(
+			selector.MatchFunc(
+				httpz.MatchOperation(basePath, endpoints, undeclared...),
+			),
+			denyPermission,
+		)...)
+	}
+
+	operations := make(map[string][]string)
+	for operation, permissions := range table {
+		for _, permission := range permissions {
+			operations[permission] = append(operations[permission], operation)
+		}
+	}
+	permissions := make([]string, 0, len(operations))
+	for permission := range operations {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+
+	for _, permission := range permissions {
+		middlewares = append(middlewares, selector.NewSelectorMiddleware(
+			selector.MatchFunc(
+				httpz.MatchOperation(basePath, endpoints, operations[permission]...),
+			),
+			NewPermissionMiddleware(permission, checker),
+		)...)
+	}
+	return middlewares
+}
@@ -5,15 +5,93 @@ import (
 	"net/http"
 
 	"github.com/go-sphere/httpx"
+	"github.com/go-sphere/sphere-layout/internal/service/dash"
 	"github.com/go-sphere/sphere/server/auth/authorizer"
 	"github.com/go-sphere/sphere/server/auth/jwtauth"
 	"github.com/go-sphere/sphere/server/httpz"
 	"github.com/go-sphere/sphere/server/middleware/auth"
 )
 
-func RegisterPureRute(route httpx.Router) {
-	route.Handle(http.MethodGet, "/api/get-async-routes", httpz.WithJson(func(ctx httpx.Context) ([]struct{}, error) {
-		return []struct{}{}, nil
+// PureMenuMeta is the meta of a pure-admin async route.
+type PureMenuMeta struct {
+	Title    string `json:"title" yaml:"title"`
+	Icon     string `json:"icon,omitempty" yaml:"icon,omitempty"`
+	Rank     int    `json:"rank,omitempty" yaml:"rank,omitempty"`
+	ShowLink *bool  `json:"showLink,omitempty" yaml:"show_link,omitempty"`
+}
+
+// PureMenu is a menu entry served to pure-admin as an async route.
+// An entry is visible only if the admin has all of its permissions, and a
+// parent entry is hidden once none of its children remain visible.
+type PureMenu struct {
+	Path        string       `json:"path" yaml:"path"`
+	Name        string       `json:"name,omitempty" yaml:"name,omitempty"`
+	Component   string       `json:"component,omitempty" yaml:"component,omitempty"`
+	Redirect    string       `json:"redirect,omitempty" yaml:"redirect,omitempty"`
+	Meta        PureMenuMeta `json:"meta" yaml:"meta"`
+	Permissions []string     `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+	Children    []PureMenu   `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+type pureRoute struct {
+	Path      string       `json:"path"`
+	Name      string       `json:"name,omitempty"`
+	Component string       `json:"component,omitempty"`
+	Redirect  string       `json:"redirect,omitempty"`
+	Meta      PureMenuMeta `json:"meta"`
+	Children  []pureRoute  `json:"children,omitempty"`
+}
+
+func filterPureMenus(menus []PureMenu, permissions map[string]struct{}) []pureRoute {
+	routes := make([]pureRoute, 0, len(menus))
+	for _, menu := range menus {
+		if !hasAllPermissions(menu.Permissions, permissions) {
+			continue
+		}
+		route := pureRoute{
+			Path:      menu.Path,
+			Name:      menu.Name,
+			Component: menu.Component,
+			Redirect:  menu.Redirect,
+			Meta:      menu.Meta,
+		}
+		if len(menu.Children) > 0 {
+			route.Children = filterPureMenus(menu.Children, permissions)
+			if len(route.Children) == 0 {
+				continue
+			}
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+func hasAllPermissions(required []string, permissions map[string]struct{}) bool {
+	for _, p := range required {
+		if _, ok := permissions[p]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// RegisterPureRute serves the pure-admin async routes filtered by the permissions of the current admin.
+// It relies on the claims stored by NewAccessTokenCheckMiddleware.
+func RegisterPureRute(route httpx.Router, menus []PureMenu, resolver func(roles []string) []string) {
+	route.Handle(http.MethodGet, "/api/get-async-routes", httpz.WithJson(func(ctx httpx.Context) ([]pureRoute, error) {
+		value, ok := ctx.Get(dash.AuthContextKeyClaims)
+		if !ok {
+			return []pureRoute{}, nil
+		}
+		claims, ok := value.(*jwtauth.RBACClaims[int64])
+		if !ok {
+			return []pureRoute{}, nil
+		}
+		permissions := make(map[string]struct{})
+		for _, p := range resolver(claims.Roles) {
+			permissions[p] = struct{}{}
+		}
+		return filterPureMenus(menus, permissions), nil
 	}))
 }
 
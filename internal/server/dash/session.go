@@ -1,8 +1,14 @@
 package dash
 
 import (
+	"context"
+	"strings"
+
 	"github.com/go-sphere/httpx"
 	"github.com/go-sphere/sphere-layout/internal/service/dash"
+	"github.com/go-sphere/sphere/server/auth/authorizer"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
+	"github.com/go-sphere/sphere/server/middleware/auth"
 )
 
 func NewSessionMetaData() httpx.Middleware {
@@ -12,3 +18,66 @@ func NewSessionMetaData() httpx.Middleware {
 		return ctx.Next()
 	}
 }
+
+type AccessTokenChecker = func(ctx context.Context, claims *jwtauth.RBACClaims[int64]) error
+
+type TokenResolver = func(ctx context.Context, raw string) (string, error)
+
+// NewBearerTokenResolver strips the mandatory bearer prefix and exchanges API keys for access tokens,
+// so that API keys are accepted wherever a JWT is.
+func NewBearerTokenResolver(exchanger func(ctx context.Context, key string) (string, error)) TokenResolver {
+	return func(ctx context.Context, raw string) (string, error) {
+		token, ok := strings.CutPrefix(raw, auth.AuthorizationPrefixBearer)
+		if !ok {
+			return "", httpx.NewUnauthorizedError("invalid authorization header")
+		}
+		token = strings.TrimSpace(token)
+		if dash.IsApiKey(token) {
+			return exchanger(ctx, token)
+		}
+		return token, nil
+	}
+}
+
+// NewBearerAuthMiddleware authenticates the bearer token of the request. The token is resolved before
+// the auth middleware runs, so that API keys are exchanged with the request context.
+func NewBearerAuthMiddleware(parser authorizer.Parser[int64, *jwtauth.RBACClaims[int64]], resolver TokenResolver) httpx.Middleware {
+	return func(ctx httpx.Context) error {
+		token, err := resolver(ctx.Context(), ctx.Header(auth.AuthorizationHeader))
+		if err != nil {
+			return err
+		}
+		return auth.NewAuthMiddleware[int64, jwtauth.RBACClaims[int64]](
+			parser,
+			auth.WithHeaderLoader(auth.AuthorizationHeader),
+			auth.WithTransform(func(string) (string, error) {
+				return token, nil
+			}),
+			auth.WithAbortOnError(true),
+		)(ctx)
+	}
+}
+
+// NewAccessTokenCheckMiddleware rejects bearer tokens which are still valid but have been revoked,
+// e.g. after a forced logout, and stores the claims for the permission middleware.
+// It must be placed after the auth middleware.
+func NewAccessTokenCheckMiddleware(parser authorizer.Parser[int64, *jwtauth.RBACClaims[int64]], resolver TokenResolver, checker AccessTokenChecker) httpx.Middleware {
+	return func(ctx httpx.Context) error {
+		token, err := resolver(ctx.Context(), ctx.Header(auth.AuthorizationHeader))
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return ctx.Next()
+		}
+		claims, err := parser.ParseToken(ctx.Context(), token)
+		if err != nil {
+			return err
+		}
+		if err = checker(ctx.Context(), claims); err != nil {
+			return err
+		}
+		ctx.Set(dash.AuthContextKeyClaims, claims)
+		return ctx.Next()
+	}
+}
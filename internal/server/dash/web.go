@@ -2,18 +2,22 @@ package dash
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/go-sphere/httpx"
 	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
 	sharedv1 "github.com/go-sphere/sphere-layout/api/shared/v1"
 	"github.com/go-sphere/sphere-layout/internal/pkg/httpsrv"
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc"
+	"github.com/go-sphere/sphere-layout/internal/pkg/totp"
+	apiweb "github.com/go-sphere/sphere-layout/internal/server/api"
 	"github.com/go-sphere/sphere-layout/internal/service/dash"
 	"github.com/go-sphere/sphere-layout/internal/service/shared"
-	"github.com/go-sphere/sphere/server/auth/acl"
 	"github.com/go-sphere/sphere/server/auth/jwtauth"
 	"github.com/go-sphere/sphere/server/httpz"
-	"github.com/go-sphere/sphere/server/middleware/auth"
 	"github.com/go-sphere/sphere/server/middleware/cors"
 	"github.com/go-sphere/sphere/server/middleware/ratelimiter"
 	"github.com/go-sphere/sphere/server/middleware/selector"
@@ -22,16 +26,16 @@ import (
 
 type Web struct {
 	config    Config
-	acl       *acl.ACL
+	appConfig apiweb.Config
 	engine    httpx.Engine
 	service   *dash.Service
 	sharedSvc *shared.Service
 }
 
-func NewWebServer(conf Config, storage storage.CDNStorage, service *dash.Service) *Web {
+func NewWebServer(conf Config, appConf apiweb.Config, storage storage.CDNStorage, service *dash.Service) *Web {
 	return &Web{
 		config:    conf,
-		acl:       acl.NewACL(),
+		appConfig: appConf,
 		engine:    httpsrv.NewGinServer("dash", conf.HTTP.Address),
 		service:   service,
 		sharedSvc: shared.NewService(storage, "dash"),
@@ -46,12 +50,8 @@ func (w *Web) Start(ctx context.Context) error {
 	jwtAuthorizer := jwtauth.NewJwtAuth[jwtauth.RBACClaims[int64]](w.config.AuthJWT)
 	jwtRefresher := jwtauth.NewJwtAuth[jwtauth.RBACClaims[int64]](w.config.RefreshJWT)
 
-	authMiddleware := auth.NewAuthMiddleware[int64, jwtauth.RBACClaims[int64]](
-		jwtAuthorizer,
-		auth.WithHeaderLoader(auth.AuthorizationHeader),
-		auth.WithPrefixTransform(auth.AuthorizationPrefixBearer),
-		auth.WithAbortOnError(true),
-	)
+	tokenResolver := NewBearerTokenResolver(w.service.ExchangeApiKey)
+	authMiddleware := NewBearerAuthMiddleware(jwtAuthorizer, tokenResolver)
 
 	// dashboard 静态资源
 	// 1. 不设置 `embed_dash` 编译选项，使用默认的静态资源, 在配置中设置静态资源的绝对路径
@@ -60,15 +60,32 @@ func (w *Web) Start(ctx context.Context) error {
 	w.RegisterDashStatic(w.engine.Group("/dash"))
 
 	api := w.engine.Group("/")
-	needAuthRoute := api.Group("/", authMiddleware)
+	needAuthRoute := api.Group("/", authMiddleware, NewAccessTokenCheckMiddleware(jwtAuthorizer, tokenResolver, w.service.CheckAccessToken), NewSessionMetaData())
 	w.service.Init(jwtAuthorizer, jwtRefresher)
+	totpCipher, err := totp.NewCipher(w.config.TOTP.SecretKey)
+	if err != nil {
+		return fmt.Errorf("dash totp.secret_key is required: %w", err)
+	}
+	w.service.InitTotp(w.config.TOTP.Issuer, totpCipher)
+	w.service.InitSecurity(w.config.PasswordPolicy, w.config.LoginLockout)
+	if w.config.OIDC.Enabled {
+		w.service.InitOidc(oidc.NewProvider(w.config.OIDC.Provider, nil), w.config.OIDC.Login)
+	}
+	if w.appConfig.JWT != "" {
+		// 使用 API 服务的密钥签发模拟令牌, 因此两个服务的 jwt 配置需要保持一致
+		w.service.InitImpersonation(jwtauth.NewJwtAuth[jwtauth.RBACClaims[int64]](w.appConfig.JWT))
+	}
 
 	if len(w.config.HTTP.Cors) > 0 {
 		w.engine.Use(cors.NewCORS(cors.WithAllowOrigins(w.config.HTTP.Cors...)))
 	}
-	initDefaultRolesACL(w.acl)
+	if err = w.service.InitACL(ctx); err != nil {
+		return err
+	}
 
-	sharedv1.RegisterStorageServiceHTTPServer(needAuthRoute, w.sharedSvc)
+	storageRoute := needAuthRoute.Group("/")
+	storageRoute.Use(w.withOperationPermissions(storageRoute, sharedv1.EndpointsStorageService[:], permissionsStorageService)...)
+	sharedv1.RegisterStorageServiceHTTPServer(storageRoute, w.sharedSvc)
 	sharedv1.RegisterTestServiceHTTPServer(api, w.sharedSvc)
 
 	authRoute := api.Group("/", NewSessionMetaData())
@@ -81,21 +98,74 @@ func (w *Web) Start(ctx context.Context) error {
 					authRoute.BasePath(),
 					dashv1.EndpointsAuthService[:],
 					dashv1.OperationAuthServiceLoginWithPassword,
+					dashv1.OperationAuthServiceLoginWithTotp,
+					dashv1.OperationAuthServiceLoginWithOidc,
 				),
 			),
 			rateLimiter,
 		)...,
 	)
-	RegisterPureRute(authRoute)
 	dashv1.RegisterAuthServiceHTTPServer(authRoute, w.service)
 
-	adminRoute := needAuthRoute.Group("/", w.withPermission(dash.PermissionAdmin))
+	totpRoute := needAuthRoute.Group("/")
+	totpRoute.Use(w.withOperationPermissions(totpRoute, dashv1.EndpointsTotpService[:], permissionsTotpService)...)
+	dashv1.RegisterTotpServiceHTTPServer(totpRoute, w.service)
+
+	profileRoute := needAuthRoute.Group("/")
+	profileRoute.Use(w.withOperationPermissions(profileRoute, dashv1.EndpointsProfileService[:], permissionsProfileService)...)
+	dashv1.RegisterProfileServiceHTTPServer(profileRoute, w.service)
+
+	apiKeyRoute := needAuthRoute.Group("/")
+	apiKeyRoute.Use(w.withOperationPermissions(apiKeyRoute, dashv1.EndpointsApiKeyService[:], permissionsApiKeyService)...)
+	apiKeyRoute.Use(w.withAudit(apiKeyRoute, dashv1.EndpointsApiKeyService[:],
+		dashv1.OperationApiKeyServiceCreateApiKey,
+		dashv1.OperationApiKeyServiceRevokeApiKey,
+	)...)
+	dashv1.RegisterApiKeyServiceHTTPServer(apiKeyRoute, w.service)
+	menuRoute := needAuthRoute.Group("/", NewPermissionMiddleware(dash.PermissionAuthenticated, w.service.IsAllowed))
+	RegisterPureRute(menuRoute, w.menus(), w.service.EffectivePermissions)
+
+	adminRoute := needAuthRoute.Group("/")
+	adminRoute.Use(w.withOperationPermissions(adminRoute, dashv1.EndpointsAdminService[:], permissionsAdminService)...)
+	adminRoute.Use(w.withAudit(adminRoute, dashv1.EndpointsAdminService[:], slices.Sorted(maps.Keys(permissionsAdminService))...)...)
 	dashv1.RegisterAdminServiceHTTPServer(adminRoute, w.service)
-	dashv1.RegisterAdminSessionServiceHTTPServer(adminRoute, w.service)
+
+	adminSessionRoute := needAuthRoute.Group("/")
+	adminSessionRoute.Use(w.withOperationPermissions(adminSessionRoute, dashv1.EndpointsAdminSessionService[:], permissionsAdminSessionService)...)
+	adminSessionRoute.Use(w.withAudit(adminSessionRoute, dashv1.EndpointsAdminSessionService[:], slices.Sorted(maps.Keys(permissionsAdminSessionService))...)...)
+	dashv1.RegisterAdminSessionServiceHTTPServer(adminSessionRoute, w.service)
+
+	roleRoute := needAuthRoute.Group("/")
+	roleRoute.Use(w.withOperationPermissions(roleRoute, dashv1.EndpointsRoleService[:], permissionsRoleService)...)
+	roleRoute.Use(w.withAudit(roleRoute, dashv1.EndpointsRoleService[:], slices.Sorted(maps.Keys(permissionsRoleService))...)...)
+	dashv1.RegisterRoleServiceHTTPServer(roleRoute, w.service)
+
+	auditLogRoute := needAuthRoute.Group("/")
+	auditLogRoute.Use(w.withOperationPermissions(auditLogRoute, dashv1.EndpointsAuditLogService[:], permissionsAuditLogService)...)
+	dashv1.RegisterAuditLogServiceHTTPServer(auditLogRoute, w.service)
+
+	userRoute := needAuthRoute.Group("/")
+	userRoute.Use(w.withOperationPermissions(userRoute, dashv1.EndpointsUserService[:], permissionsUserService)...)
+	userRoute.Use(w.withAudit(userRoute, dashv1.EndpointsUserService[:], slices.Sorted(maps.Keys(permissionsUserService))...)...)
+	dashv1.RegisterUserServiceHTTPServer(userRoute, w.service)
+
+	impersonationRoute := needAuthRoute.Group("/")
+	impersonationRoute.Use(w.withOperationPermissions(impersonationRoute, dashv1.EndpointsImpersonationService[:], permissionsImpersonationService)...)
+	impersonationRoute.Use(w.withAudit(impersonationRoute, dashv1.EndpointsImpersonationService[:], slices.Sorted(maps.Keys(permissionsImpersonationService))...)...)
+	dashv1.RegisterImpersonationServiceHTTPServer(impersonationRoute, w.service)
 
 	systemRoute := needAuthRoute.Group("/")
+	systemRoute.Use(w.withOperationPermissions(systemRoute, dashv1.EndpointsSystemService[:], permissionsSystemService)...)
 	dashv1.RegisterSystemServiceHTTPServer(systemRoute, w.service)
-	dashv1.RegisterKeyValueStoreServiceHTTPServer(systemRoute, w.service)
+
+	keyValueStoreRoute := needAuthRoute.Group("/")
+	keyValueStoreRoute.Use(w.withOperationPermissions(keyValueStoreRoute, dashv1.EndpointsKeyValueStoreService[:], permissionsKeyValueStoreService)...)
+	keyValueStoreRoute.Use(w.withAudit(keyValueStoreRoute, dashv1.EndpointsKeyValueStoreService[:],
+		dashv1.OperationKeyValueStoreServiceCreateKeyValueStore,
+		dashv1.OperationKeyValueStoreServiceUpdateKeyValueStore,
+		dashv1.OperationKeyValueStoreServiceDeleteKeyValueStore,
+	)...)
+	dashv1.RegisterKeyValueStoreServiceHTTPServer(keyValueStoreRoute, w.service)
 
 	return w.engine.Start()
 }
@@ -104,16 +174,19 @@ func (w *Web) Stop(ctx context.Context) error {
 	return w.engine.Stop(ctx)
 }
 
-func (w *Web) withPermission(resource string) httpx.Middleware {
-	return auth.NewPermissionMiddleware[int64](resource, w.acl)
+// menus returns the configured menus, falling back to the builtin ones for config files written
+// before menus became configurable. An explicit empty list still disables the menus.
+func (w *Web) menus() []PureMenu {
+	if w.config.Menus == nil {
+		return DefaultMenus()
+	}
+	return w.config.Menus
+}
+
+func (w *Web) withAudit(route httpx.Router, endpoints [][3]string, operations ...string) []httpx.Middleware {
+	return NewAuditOperationMiddleware(route.BasePath(), endpoints, operations...)
 }
 
-func initDefaultRolesACL(acl *acl.ACL) {
-	roles := []string{
-		dash.PermissionAdmin,
-	}
-	for _, r := range roles {
-		acl.Allow(dash.PermissionAll, r)
-		acl.Allow(r, r)
-	}
+func (w *Web) withOperationPermissions(route httpx.Router, endpoints [][3]string, table map[string][]string) []httpx.Middleware {
+	return NewOperationPermissionMiddleware(route.BasePath(), endpoints, table, w.service.IsAllowed)
 }
@@ -10,14 +10,25 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
 	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/client"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc"
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc/oidctest"
+	"github.com/go-sphere/sphere-layout/internal/pkg/security"
+	"github.com/go-sphere/sphere-layout/internal/pkg/totp"
+	"github.com/go-sphere/sphere-layout/internal/pkg/userflag"
+	apiweb "github.com/go-sphere/sphere-layout/internal/server/api"
 	servicedash "github.com/go-sphere/sphere-layout/internal/service/dash"
 	"github.com/go-sphere/sphere/cache/memory"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
 	"github.com/go-sphere/sphere/storage"
 	"github.com/go-sphere/sphere/utils/secure"
 )
@@ -25,6 +36,7 @@ import (
 const (
 	testAdminUsername = "admin"
 	testAdminPassword = "aA1234567"
+	testAppJWT        = "test-app-jwt-secret"
 )
 
 func TestWebAuthAndAdminEndpoints(t *testing.T) {
@@ -108,7 +120,822 @@ func TestWebAuthAndAdminEndpoints(t *testing.T) {
 	})
 }
 
-func setupTestWeb(t *testing.T) (string, func()) {
+func TestWebLogoutEverywhere(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t)
+	defer cleanup()
+
+	_, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/login", map[string]string{
+		"username": testAdminUsername,
+		"password": testAdminPassword,
+	}, nil)
+	var login struct {
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	parseResponseData(t, body, &login)
+	if login.AccessToken == "" || login.RefreshToken == "" {
+		t.Fatalf("expected token pair, body=%s", body)
+	}
+	authHeader := map[string]string{"Authorization": "Bearer " + login.AccessToken}
+
+	status, body := doJSONRequest(t, http.MethodGet, baseURL+"/api/admin/list", nil, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 before logout, got %d, body=%s", status, body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/logout/everywhere", map[string]string{
+		"refreshToken": login.RefreshToken,
+	}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected logout status 200, got %d, body=%s", status, body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/admin/list", nil, authHeader)
+	if status == http.StatusOK {
+		t.Fatalf("expected denied access token after logout, body=%s", body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/refresh-token", map[string]string{
+		"refreshToken": login.RefreshToken,
+	}, nil)
+	if status == http.StatusOK {
+		t.Fatalf("expected revoked refresh token to be rejected, body=%s", body)
+	}
+
+	// 同一秒内重新登录签发的令牌不能被登出记录拒绝
+	token := loginAsDefaultAdmin(t, baseURL)
+	status, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/admin/list", nil, map[string]string{"Authorization": "Bearer " + token})
+	if status != http.StatusOK {
+		t.Fatalf("expected new login after logout to be accepted, got %d, body=%s", status, body)
+	}
+}
+
+func TestWebRefreshTokenReuse(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t)
+	defer cleanup()
+
+	_, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/login", map[string]string{
+		"username": testAdminUsername,
+		"password": testAdminPassword,
+	}, nil)
+	var first struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	parseResponseData(t, body, &first)
+
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/refresh-token", map[string]string{
+		"refreshToken": first.RefreshToken,
+	}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected refresh status 200, got %d, body=%s", status, body)
+	}
+	var second struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	parseResponseData(t, body, &second)
+	if second.RefreshToken == "" {
+		t.Fatalf("expected rotated refresh token, body=%s", body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/refresh-token", map[string]string{
+		"refreshToken": first.RefreshToken,
+	}, nil)
+	if status != http.StatusForbidden {
+		t.Fatalf("expected status 403 for reused refresh token, got %d, body=%s", status, body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/refresh-token", map[string]string{
+		"refreshToken": second.RefreshToken,
+	}, nil)
+	if status == http.StatusOK {
+		t.Fatalf("expected whole session family to be revoked, body=%s", body)
+	}
+}
+
+func TestWebTotpLogin(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t)
+	defer cleanup()
+
+	secret, code := enrollDefaultAdminTotp(t, baseURL)
+	challengeToken := requestTotpChallenge(t, baseURL)
+
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/login/totp", map[string]string{
+		"challengeToken": challengeToken,
+		"code":           "000000",
+	}, nil)
+	if status == http.StatusOK && parseLoginToken(t, body) != "" {
+		t.Fatalf("expected wrong totp code to be rejected, body=%s", body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/login/totp", map[string]string{
+		"challengeToken": challengeToken,
+		"code":           code,
+	}, nil)
+	if status == http.StatusOK && parseLoginToken(t, body) != "" {
+		t.Fatalf("expected replayed totp code to be rejected, body=%s", body)
+	}
+
+	code, _ = totp.GenerateCode(secret, time.Now().Add(totp.DefaultPeriod*time.Second))
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/login/totp", map[string]string{
+		"challengeToken": challengeToken,
+		"code":           code,
+	}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d, body=%s", status, body)
+	}
+	if parseLoginToken(t, body) == "" {
+		t.Fatalf("expected accessToken after totp verification, body=%s", body)
+	}
+}
+
+func TestWebTotpLockout(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t, func(conf *Config) {
+		conf.LoginLockout = security.LockoutPolicy{MaxAttempts: 3, BaseSeconds: 60, MaxSeconds: 600}
+	})
+	defer cleanup()
+
+	enrollDefaultAdminTotp(t, baseURL)
+	for i := 0; i < 2; i++ {
+		// 每次重新获取挑战也不能重置失败次数
+		challengeToken := requestTotpChallenge(t, baseURL)
+		status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/login/totp", map[string]string{
+			"challengeToken": challengeToken,
+			"code":           "000000",
+		}, nil)
+		if status != http.StatusUnauthorized {
+			t.Fatalf("expected status 401 for wrong totp code, got %d, body=%s", status, body)
+		}
+	}
+	challengeToken := requestTotpChallenge(t, baseURL)
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/login/totp", map[string]string{
+		"challengeToken": challengeToken,
+		"code":           "000000",
+	}, nil)
+	if status != http.StatusLocked {
+		t.Fatalf("expected status 423 once locked by totp failures, got %d, body=%s", status, body)
+	}
+}
+
+// enrollDefaultAdminTotp enables TOTP for the default admin and returns the secret with the code used to confirm it.
+func enrollDefaultAdminTotp(t *testing.T, baseURL string) (string, string) {
+	t.Helper()
+
+	token := loginAsDefaultAdmin(t, baseURL)
+	authHeader := map[string]string{"Authorization": "Bearer " + token}
+
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/totp/enroll", map[string]string{}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected enroll status 200, got %d, body=%s", status, body)
+	}
+	var enroll struct {
+		Secret string `json:"secret"`
+	}
+	parseResponseData(t, body, &enroll)
+	if enroll.Secret == "" {
+		t.Fatalf("expected totp secret, body=%s", body)
+	}
+
+	code, err := totp.GenerateCode(enroll.Secret, time.Now())
+	if err != nil {
+		t.Fatalf("generate totp code failed: %v", err)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/totp/confirm", map[string]string{"code": code}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected confirm status 200, got %d, body=%s", status, body)
+	}
+	return enroll.Secret, code
+}
+
+// requestTotpChallenge logs in with the default admin password and returns the TOTP challenge token.
+func requestTotpChallenge(t *testing.T, baseURL string) string {
+	t.Helper()
+
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/login", map[string]string{
+		"username": testAdminUsername,
+		"password": testAdminPassword,
+	}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d, body=%s", status, body)
+	}
+	if parseLoginToken(t, body) != "" {
+		t.Fatalf("expected no accessToken before totp verification, body=%s", body)
+	}
+	var login struct {
+		TotpRequired   bool   `json:"totpRequired"`
+		ChallengeToken string `json:"challengeToken"`
+	}
+	parseResponseData(t, body, &login)
+	if !login.TotpRequired || login.ChallengeToken == "" {
+		t.Fatalf("expected totp challenge, body=%s", body)
+	}
+	return login.ChallengeToken
+}
+
+func TestWebLoginLockout(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t, func(conf *Config) {
+		conf.LoginLockout = security.LockoutPolicy{MaxAttempts: 2, BaseSeconds: 60, MaxSeconds: 600}
+	})
+	defer cleanup()
+
+	wrong := map[string]string{
+		"username": testAdminUsername,
+		"password": "wrong-password",
+	}
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/login", wrong, nil)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 for first failure, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/login", wrong, nil)
+	if status != http.StatusLocked {
+		t.Fatalf("expected status 423 once locked, got %d, body=%s", status, body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/login", map[string]string{
+		"username": testAdminUsername,
+		"password": testAdminPassword,
+	}, nil)
+	if status != http.StatusLocked {
+		t.Fatalf("expected locked account to reject valid password, got %d, body=%s", status, body)
+	}
+	if token := parseLoginToken(t, body); token != "" {
+		t.Fatalf("expected empty accessToken for locked account, got %q", token)
+	}
+}
+
+func TestWebDynamicRoles(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t)
+	defer cleanup()
+
+	token := loginAsDefaultAdmin(t, baseURL)
+	authHeader := map[string]string{"Authorization": "Bearer " + token}
+
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/admin/create", map[string]any{
+		"admin": map[string]any{
+			"username": "reporter",
+			"password": "Reporter123",
+			"roles":    []string{"reporter"},
+		},
+	}, authHeader)
+	if status == http.StatusOK {
+		t.Fatalf("expected unknown role to be rejected, body=%s", body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/permission/create", map[string]any{
+		"permission": map[string]any{"name": "report"},
+	}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected create permission status 200, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/role/create", map[string]any{
+		"role": map[string]any{"name": "reporter", "permissions": []string{"report"}},
+	}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected create role status 200, got %d, body=%s", status, body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/admin/role/list", nil, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected list roles status 200, got %d, body=%s", status, body)
+	}
+	var roles struct {
+		Roles []string `json:"roles"`
+	}
+	parseResponseData(t, body, &roles)
+	if !slices.Contains(roles.Roles, "reporter") {
+		t.Fatalf("expected new role in admin roles, body=%s", body)
+	}
+}
+
+func TestWebOperationPermissions(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t)
+	defer cleanup()
+
+	token := loginAsDefaultAdmin(t, baseURL)
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/admin/create", map[string]any{
+		"admin": map[string]any{
+			"username": "viewer",
+			"password": "Viewer1234",
+			"roles":    []string{"admin_viewer"},
+		},
+	}, map[string]string{"Authorization": "Bearer " + token})
+	if status != http.StatusOK {
+		t.Fatalf("expected create admin status 200, got %d, body=%s", status, body)
+	}
+
+	_, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/login", map[string]string{
+		"username": "viewer",
+		"password": "Viewer1234",
+	}, nil)
+	viewerToken := parseLoginToken(t, body)
+	if viewerToken == "" {
+		t.Fatalf("expected viewer login token, body=%s", body)
+	}
+	authHeader := map[string]string{"Authorization": "Bearer " + viewerToken}
+
+	status, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/admin/list", nil, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected read-only role to list admins, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/admin/create", map[string]any{
+		"admin": map[string]any{
+			"username": "another",
+			"password": "Another1234",
+		},
+	}, authHeader)
+	if status != http.StatusForbidden {
+		t.Fatalf("expected read-only role to be forbidden from creating admins, got %d, body=%s", status, body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/profile", nil, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected any admin to read the profile, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/key-value-store/list", nil, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected read-only role to list key value stores, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/cache/reset", map[string]any{}, authHeader)
+	if status != http.StatusForbidden {
+		t.Fatalf("expected read-only role to be forbidden from resetting the cache, got %d, body=%s", status, body)
+	}
+}
+
+func TestWebPermissionsAndMenus(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t)
+	defer cleanup()
+
+	_, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/login", map[string]string{
+		"username": testAdminUsername,
+		"password": testAdminPassword,
+	}, nil)
+	var login struct {
+		AccessToken string   `json:"accessToken"`
+		Permissions []string `json:"permissions"`
+	}
+	parseResponseData(t, body, &login)
+	if !slices.Contains(login.Permissions, servicedash.PermissionAdminRead) {
+		t.Fatalf("expected effective permissions in login response, body=%s", body)
+	}
+	authHeader := map[string]string{"Authorization": "Bearer " + login.AccessToken}
+
+	status, body := doJSONRequest(t, http.MethodGet, baseURL+"/api/get-async-routes", nil, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected async routes status 200, got %d, body=%s", status, body)
+	}
+	var routes []struct {
+		Path     string `json:"path"`
+		Children []any  `json:"children"`
+	}
+	parseResponseData(t, body, &routes)
+	if len(routes) != 2 || len(routes[1].Children) != 4 {
+		t.Fatalf("expected full menu for super admin, body=%s", body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/role/create", map[string]any{
+		"role": map[string]any{"name": "guest", "permissions": []string{}},
+	}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected create role status 200, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/admin/create", map[string]any{
+		"admin": map[string]any{
+			"username": "guest",
+			"password": "Guest12345",
+			"roles":    []string{"guest"},
+		},
+	}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected create admin status 200, got %d, body=%s", status, body)
+	}
+	_, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/login", map[string]string{
+		"username": "guest",
+		"password": "Guest12345",
+	}, nil)
+	guestToken := parseLoginToken(t, body)
+
+	_, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/get-async-routes", nil, map[string]string{
+		"Authorization": "Bearer " + guestToken,
+	})
+	routes = nil
+	parseResponseData(t, body, &routes)
+	if len(routes) != 0 {
+		t.Fatalf("expected empty menu without permissions, body=%s", body)
+	}
+}
+
+func TestWebDefaultMenus(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t, func(conf *Config) {
+		conf.Menus = nil
+	})
+	defer cleanup()
+
+	token := loginAsDefaultAdmin(t, baseURL)
+	status, body := doJSONRequest(t, http.MethodGet, baseURL+"/api/get-async-routes", nil, map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("expected async routes status 200, got %d, body=%s", status, body)
+	}
+	var routes []struct {
+		Path string `json:"path"`
+	}
+	parseResponseData(t, body, &routes)
+	if len(routes) != len(DefaultMenus()) {
+		t.Fatalf("expected builtin menus without menus config, body=%s", body)
+	}
+}
+
+func TestWebAuditLog(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t)
+	defer cleanup()
+
+	token := loginAsDefaultAdmin(t, baseURL)
+	authHeader := map[string]string{"Authorization": "Bearer " + token}
+
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/admin/create", map[string]any{
+		"admin": map[string]any{
+			"username": "audited",
+			"password": "Audited1234",
+		},
+	}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected create admin status 200, got %d, body=%s", status, body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/audit-log/list?entity=Admin", nil, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected list audit logs status 200, got %d, body=%s", status, body)
+	}
+	var logs struct {
+		AuditLogs []struct {
+			Operation string `json:"operation"`
+			Action    string `json:"action"`
+			Changes   string `json:"changes"`
+		} `json:"audit_logs"`
+	}
+	parseResponseData(t, body, &logs)
+	if len(logs.AuditLogs) != 1 {
+		t.Fatalf("expected one audit log for admin creation, body=%s", body)
+	}
+	entry := logs.AuditLogs[0]
+	if entry.Action != "create" || !strings.Contains(entry.Operation, "CreateAdmin") {
+		t.Fatalf("unexpected audit log entry: %+v", entry)
+	}
+	if !strings.Contains(entry.Changes, "audited") || strings.Contains(entry.Changes, "password") {
+		t.Fatalf("expected changes without sensitive fields, got %s", entry.Changes)
+	}
+}
+
+func TestWebOidcLogin(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	baseURL, cleanup := setupTestWeb(t, func(conf *Config) {
+		conf.OIDC = OIDCConfig{
+			Enabled: true,
+			Provider: oidc.Config{
+				Issuer:      idp.Issuer(),
+				ClientID:    "dash",
+				RedirectURL: "http://localhost/callback",
+			},
+			Login: servicedash.OidcLoginPolicy{
+				GroupRoles: map[string][]string{
+					"ops":    {servicedash.PermissionAdmin},
+					"legacy": {"removed-role"},
+				},
+			},
+		}
+	})
+	defer cleanup()
+
+	authorize := func(claims map[string]any) (string, string) {
+		t.Helper()
+		status, body := doJSONRequest(t, http.MethodGet, baseURL+"/api/login/oidc/authorize", nil, nil)
+		if status != http.StatusOK {
+			t.Fatalf("expected authorize status 200, got %d, body=%s", status, body)
+		}
+		var resp struct {
+			AuthorizeURL string `json:"authorizeUrl"`
+		}
+		parseResponseData(t, body, &resp)
+		code, state, err := idp.Authorize(resp.AuthorizeURL, claims)
+		if err != nil {
+			t.Fatalf("authorize at provider failed: %v", err)
+		}
+		return code, state
+	}
+
+	code, state := authorize(map[string]any{"sub": "alice-id", "preferred_username": "alice", "groups": []string{"ops"}})
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/login/oidc", map[string]string{"code": code, "state": state}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected oidc login status 200, got %d, body=%s", status, body)
+	}
+	var login struct {
+		Username    string   `json:"username"`
+		Roles       []string `json:"roles"`
+		AccessToken string   `json:"accessToken"`
+	}
+	parseResponseData(t, body, &login)
+	if login.Username != "alice" || !slices.Equal(login.Roles, []string{servicedash.PermissionAdmin}) || login.AccessToken == "" {
+		t.Fatalf("unexpected oidc login response, body=%s", body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/login/oidc", map[string]string{"code": code, "state": state}, nil)
+	if status == http.StatusOK {
+		t.Fatalf("expected state replay to be rejected, body=%s", body)
+	}
+
+	code, state = authorize(map[string]any{"sub": "bob-id", "preferred_username": "bob"})
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/login/oidc", map[string]string{"code": code, "state": state}, nil)
+	if status != http.StatusForbidden {
+		t.Fatalf("expected user without mapped roles to be forbidden, got %d, body=%s", status, body)
+	}
+
+	code, state = authorize(map[string]any{"sub": "other-id", "preferred_username": testAdminUsername, "groups": []string{"ops"}})
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/login/oidc", map[string]string{"code": code, "state": state}, nil)
+	if status != http.StatusConflict {
+		t.Fatalf("expected local username conflict, got %d, body=%s", status, body)
+	}
+
+	code, state = authorize(map[string]any{"sub": "carol-id", "preferred_username": "carol", "groups": []string{"legacy"}})
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/login/oidc", map[string]string{"code": code, "state": state}, nil)
+	if status == http.StatusOK {
+		t.Fatalf("expected group mapped to an unknown role to be rejected, body=%s", body)
+	}
+}
+
+func TestWebApiKey(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t)
+	defer cleanup()
+
+	token := loginAsDefaultAdmin(t, baseURL)
+	authHeader := map[string]string{"Authorization": "Bearer " + token}
+
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/api-key/create", map[string]any{
+		"name":   "backup",
+		"scopes": []string{"not-a-permission"},
+	}, authHeader)
+	if status == http.StatusOK {
+		t.Fatalf("expected unknown scope to be rejected, body=%s", body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/api-key/create", map[string]any{
+		"name":   "backup",
+		"scopes": []string{servicedash.PermissionAdminRead},
+	}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected create api key status 200, got %d, body=%s", status, body)
+	}
+	var created struct {
+		ApiKey struct {
+			ID int64 `json:"id"`
+		} `json:"api_key"`
+		Key string `json:"key"`
+	}
+	parseResponseData(t, body, &created)
+	if !strings.HasPrefix(created.Key, servicedash.ApiKeyPrefix) {
+		t.Fatalf("expected plaintext api key, body=%s", body)
+	}
+	keyHeader := map[string]string{"Authorization": "Bearer " + created.Key}
+
+	status, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/admin/list", nil, keyHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected api key to read admins, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/admin/create", map[string]any{
+		"admin": map[string]any{"username": "script", "password": "Script1234"},
+	}, keyHeader)
+	if status != http.StatusForbidden {
+		t.Fatalf("expected api key without write scope to be forbidden, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/api-key/create", map[string]any{
+		"name":   "nested",
+		"scopes": []string{servicedash.PermissionAdminRead},
+	}, keyHeader)
+	if status != http.StatusForbidden {
+		t.Fatalf("expected api key to be unable to mint api keys, got %d, body=%s", status, body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, fmt.Sprintf("%s/api/api-key/revoke/%d", baseURL, created.ApiKey.ID), map[string]any{}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected revoke status 200, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/admin/list", nil, keyHeader)
+	if status == http.StatusOK {
+		t.Fatalf("expected revoked api key to be rejected, body=%s", body)
+	}
+}
+
+func TestWebApiKeyRestrictions(t *testing.T) {
+	baseURL, cleanup := setupTestWeb(t)
+	defer cleanup()
+
+	_, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/login", map[string]string{
+		"username": testAdminUsername,
+		"password": testAdminPassword,
+	}, nil)
+	var login struct {
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	parseResponseData(t, body, &login)
+	authHeader := map[string]string{"Authorization": "Bearer " + login.AccessToken}
+
+	status, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/api-key/create", map[string]any{
+		"name":   "reader",
+		"scopes": []string{servicedash.PermissionAdminRead},
+	}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected create api key status 200, got %d, body=%s", status, body)
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	parseResponseData(t, body, &created)
+	keyHeader := map[string]string{"Authorization": "Bearer " + created.Key}
+
+	for _, path := range []string{"/api/profile", "/api/get-async-routes"} {
+		status, body = doJSONRequest(t, http.MethodGet, baseURL+path, nil, keyHeader)
+		if status != http.StatusForbidden {
+			t.Fatalf("expected api key to be forbidden from %s, got %d, body=%s", path, status, body)
+		}
+	}
+	status, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/admin/list", nil, map[string]string{"Authorization": created.Key})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("expected api key without bearer prefix to be rejected, got %d, body=%s", status, body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, baseURL+"/api/logout/everywhere", map[string]string{
+		"refreshToken": login.RefreshToken,
+	}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected logout status 200, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodGet, baseURL+"/api/admin/list", nil, keyHeader)
+	if status == http.StatusOK {
+		t.Fatalf("expected api key to be revoked by logout everywhere, body=%s", body)
+	}
+}
+
+func TestWebImpersonation(t *testing.T) {
+	baseURL, db, cleanup := setupTestWebWithDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	user, err := db.User.Create().SetUsername("customer").Save(ctx)
+	if err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	administrator, err := db.Admin.Query().Only(ctx)
+	if err != nil {
+		t.Fatalf("query admin failed: %v", err)
+	}
+	token := loginAsDefaultAdmin(t, baseURL)
+	authHeader := map[string]string{"Authorization": "Bearer " + token}
+	target := fmt.Sprintf("%s/api/impersonation/user/%d", baseURL, user.ID)
+
+	status, body := doJSONRequest(t, http.MethodPost, target, map[string]any{}, authHeader)
+	if status == http.StatusOK {
+		t.Fatalf("expected impersonation without reason to be rejected, body=%s", body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, target, map[string]any{"reason": "ticket #42"}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected impersonation status 200, got %d, body=%s", status, body)
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	parseResponseData(t, body, &resp)
+	claims, err := jwtauth.NewJwtAuth[jwtauth.RBACClaims[int64]](testAppJWT).ParseToken(ctx, resp.AccessToken)
+	if err != nil {
+		t.Fatalf("expected token signed with the app secret: %v", err)
+	}
+	if impersonator, ok := auth.Impersonator(claims); !ok || impersonator != administrator.ID || claims.UID != user.ID {
+		t.Fatalf("unexpected impersonation claims: %+v", claims)
+	}
+
+	status, body = doJSONRequest(t, http.MethodGet, fmt.Sprintf("%s/api/audit-log/list?entity=User&target_id=%d", baseURL, user.ID), nil, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected list audit logs status 200, got %d, body=%s", status, body)
+	}
+	var logs struct {
+		AuditLogs []struct {
+			UID     int64  `json:"uid"`
+			Action  string `json:"action"`
+			Changes string `json:"changes"`
+		} `json:"audit_logs"`
+	}
+	parseResponseData(t, body, &logs)
+	if len(logs.AuditLogs) != 1 || logs.AuditLogs[0].Action != "impersonate" || logs.AuditLogs[0].UID != administrator.ID {
+		t.Fatalf("expected impersonation to be audited, body=%s", body)
+	}
+	if !strings.Contains(logs.AuditLogs[0].Changes, "ticket #42") {
+		t.Fatalf("expected reason in audit log, got %s", logs.AuditLogs[0].Changes)
+	}
+}
+
+func TestWebUserManagement(t *testing.T) {
+	baseURL, db, cleanup := setupTestWebWithDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	user, err := db.User.Create().SetUsername("customer").Save(ctx)
+	if err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	_, err = db.UserPlatform.Create().SetID(1).SetUserID(user.ID).SetPlatform(userplatform.PlatformWechatMini).SetPlatformID("openid").Save(ctx)
+	if err != nil {
+		t.Fatalf("create wechat platform failed: %v", err)
+	}
+	phone, err := db.UserPlatform.Create().SetID(2).SetUserID(user.ID).SetPlatform(userplatform.PlatformPhone).SetPlatformID("13800138000").Save(ctx)
+	if err != nil {
+		t.Fatalf("create phone platform failed: %v", err)
+	}
+	if _, err = db.User.Create().SetUsername("other").Save(ctx); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	token := loginAsDefaultAdmin(t, baseURL)
+	authHeader := map[string]string{"Authorization": "Bearer " + token}
+
+	status, body := doJSONRequest(t, http.MethodGet, baseURL+"/api/user/list?keyword=0013", nil, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected list users status 200, got %d, body=%s", status, body)
+	}
+	var users struct {
+		Users []struct {
+			ID int64 `json:"id"`
+		} `json:"users"`
+	}
+	parseResponseData(t, body, &users)
+	if len(users.Users) != 1 || users.Users[0].ID != user.ID {
+		t.Fatalf("expected phone search to find the user, body=%s", body)
+	}
+
+	status, body = doJSONRequest(t, http.MethodPost, fmt.Sprintf("%s/api/user/update/%d", baseURL, user.ID), map[string]any{"remark": "vip"}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected update user status 200, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, fmt.Sprintf("%s/api/user/update/%d", baseURL, user.ID), map[string]any{"remark": "vip", "flags": uint64(1) << 40}, authHeader)
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected unregistered flag bits to be rejected, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, fmt.Sprintf("%s/api/user/flag/set/%d", baseURL, user.ID), map[string]any{"flag": "unknown", "enabled": true}, authHeader)
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected unknown flag to be rejected, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, fmt.Sprintf("%s/api/user/ban/%d", baseURL, user.ID), map[string]any{
+		"reason":     "spam",
+		"expires_at": time.Now().Add(-time.Hour).Unix(),
+	}, authHeader)
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected ban expiring in the past to be rejected, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodPost, fmt.Sprintf("%s/api/user/ban/%d", baseURL, user.ID), map[string]any{
+		"reason":     "spam",
+		"expires_at": time.Now().Add(time.Hour).Unix(),
+	}, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected ban user status 200, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodGet, fmt.Sprintf("%s/api/user/flag/list/%d", baseURL, user.ID), nil, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected list user flags status 200, got %d, body=%s", status, body)
+	}
+	var flags struct {
+		Flags []struct {
+			Flag   string `json:"flag"`
+			Reason string `json:"reason"`
+		} `json:"flags"`
+	}
+	parseResponseData(t, body, &flags)
+	if len(flags.Flags) != 1 || flags.Flags[0].Flag != userflag.NameBanned || flags.Flags[0].Reason != "spam" {
+		t.Fatalf("expected ban reason to be recorded, body=%s", body)
+	}
+	banned, err := db.User.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("query user failed: %v", err)
+	}
+	if banned.Remark != "vip" || !userflag.Has(banned.Flags, userflag.Banned) {
+		t.Fatalf("expected remark and banned flag to be saved, got %+v", banned)
+	}
+
+	status, body = doJSONRequest(t, http.MethodDelete, fmt.Sprintf("%s/api/user/platform/delete/%d", baseURL, phone.ID), nil, authHeader)
+	if status != http.StatusOK {
+		t.Fatalf("expected unlink platform status 200, got %d, body=%s", status, body)
+	}
+	status, body = doJSONRequest(t, http.MethodDelete, fmt.Sprintf("%s/api/user/platform/delete/%d", baseURL, 1), nil, authHeader)
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected unlinking the last platform to be rejected, got %d, body=%s", status, body)
+	}
+}
+
+func setupTestWeb(t *testing.T, options ...func(conf *Config)) (string, func()) {
+	t.Helper()
+
+	baseURL, _, cleanup := setupTestWebWithDB(t, options...)
+	return baseURL, cleanup
+}
+
+func setupTestWebWithDB(t *testing.T, options ...func(conf *Config)) (string, *ent.Client, func()) {
 	t.Helper()
 
 	addr := randomLocalAddress(t)
@@ -117,13 +944,21 @@ func setupTestWeb(t *testing.T) (string, func()) {
 
 	testStorage := &noopStorage{}
 	service := servicedash.NewService(dao.NewDao(db), nil, memory.NewByteCache(), testStorage)
-	web := NewWebServer(Config{
+	conf := Config{
 		AuthJWT:    "test-auth-jwt-secret",
 		RefreshJWT: "test-refresh-jwt-secret",
 		HTTP: HTTPConfig{
 			Address: addr,
 		},
-	}, testStorage, service)
+		TOTP: TOTPConfig{
+			SecretKey: "test-totp-secret-key",
+		},
+		Menus: DefaultMenus(),
+	}
+	for _, option := range options {
+		option(&conf)
+	}
+	web := NewWebServer(conf, apiweb.Config{JWT: testAppJWT}, testStorage, service)
 
 	startErr := make(chan error, 1)
 	go func() {
@@ -146,7 +981,7 @@ func setupTestWeb(t *testing.T) (string, func()) {
 		case <-time.After(time.Second):
 		}
 	}
-	return baseURL, cleanup
+	return baseURL, db, cleanup
 }
 
 func waitServerReady(t *testing.T, baseURL string, startErr <-chan error) {
@@ -260,6 +1095,31 @@ func parseLoginToken(t *testing.T, body string) string {
 	return resp.Data.AccessToken
 }
 
+func loginAsDefaultAdmin(t *testing.T, baseURL string) string {
+	t.Helper()
+
+	_, body := doJSONRequest(t, http.MethodPost, baseURL+"/api/login", map[string]string{
+		"username": testAdminUsername,
+		"password": testAdminPassword,
+	}, nil)
+	token := parseLoginToken(t, body)
+	if token == "" {
+		t.Fatalf("expected login token, body=%s", body)
+	}
+	return token
+}
+
+func parseResponseData(t *testing.T, body string, data any) {
+	t.Helper()
+
+	resp := struct {
+		Data any `json:"data"`
+	}{Data: data}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("decode response failed: %v, body=%s", err, body)
+	}
+}
+
 func parseAdminCount(t *testing.T, body string) int {
 	t.Helper()
 
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/api/entpb"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
+	"github.com/go-sphere/sphere-layout/internal/pkg/conv"
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/user"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userflagrecord"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/usersession"
+	"github.com/go-sphere/sphere-layout/internal/pkg/userflag"
+	"github.com/go-sphere/sphere/log"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
+)
+
+const (
+	AccountDeletionDefaultGracePeriod = time.Hour * 24 * 15
+	AccountPurgeBatchSize             = 100
+
+	DataExportInterval = time.Minute * 10
+)
+
+// accountExport is the document returned by ExportMyData.
+type accountExport struct {
+	ExportedAt  int64                   `json:"exported_at"`
+	User        *entpb.User             `json:"user"`
+	Platforms   []*entpb.UserPlatform   `json:"platforms"`
+	Sessions    []*entpb.UserSession    `json:"sessions"`
+	FlagRecords []*entpb.UserFlagRecord `json:"flag_records"`
+}
+
+func (s *Service) InitAccountDeletion(gracePeriod time.Duration) {
+	if gracePeriod <= 0 {
+		gracePeriod = AccountDeletionDefaultGracePeriod
+	}
+	s.deletionGracePeriod = gracePeriod
+}
+
+// rejectImpersonation rejects requests made with an impersonation token, for actions only the user may take.
+func rejectImpersonation(ctx context.Context) error {
+	claims, _ := ctx.Value(AuthContextKeyClaims).(*jwtauth.RBACClaims[int64])
+	if _, ok := auth.Impersonator(claims); ok {
+		return apiv1.AuthError_AUTH_ERROR_IMPERSONATION_READ_ONLY
+	}
+	return nil
+}
+
+func (s *Service) RequestAccountDeletion(ctx context.Context, request *apiv1.RequestAccountDeletionRequest) (*apiv1.RequestAccountDeletionResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	me, err := s.db.User.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if me.DeleteAt == 0 {
+		me, err = s.db.User.UpdateOne(me).
+			SetDeleteAt(time.Now().Add(s.deletionGracePeriod).Unix()).
+			Save(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &apiv1.RequestAccountDeletionResponse{
+		DeleteAt: me.DeleteAt,
+	}, nil
+}
+
+func (s *Service) CancelAccountDeletion(ctx context.Context, request *apiv1.CancelAccountDeletionRequest) (*apiv1.CancelAccountDeletionResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	err = s.db.User.Update().
+		Where(user.IDEQ(uid), user.DeletedAtEQ(0)).
+		SetDeleteAt(0).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.CancelAccountDeletionResponse{}, nil
+}
+
+func (s *Service) ExportMyData(ctx context.Context, request *apiv1.ExportMyDataRequest) (*apiv1.ExportMyDataResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	limited := rateLimit{"data_export:" + strconv.FormatInt(uid, 10), 1, DataExportInterval}
+	if err = s.checkRateLimits(ctx, apiv1.UserError_USER_ERROR_EXPORT_TOO_FREQUENT, limited); err != nil {
+		return nil, err
+	}
+	me, err := s.db.User.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	platforms, err := s.db.UserPlatform.Query().Where(userplatform.UserIDEQ(uid)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.db.UserSession.Query().Where(usersession.UIDEQ(uid)).Order(usersession.ByID()).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.db.UserFlagRecord.Query().Where(userflagrecord.UserIDEQ(uid)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	content, err := json.MarshalIndent(accountExport{
+		ExportedAt:  now.Unix(),
+		User:        s.render.UserDetail(me),
+		Platforms:   conv.Map(platforms, s.render.UserPlatform),
+		Sessions:    conv.Map(sessions, s.render.UserSession),
+		FlagRecords: conv.Map(records, s.render.UserFlagRecord),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.ExportMyDataResponse{
+		Filename: fmt.Sprintf("user-%d-%s.json", uid, now.Format("20060102150405")),
+		Content:  string(content),
+	}, nil
+}
+
+// purgeAccount anonymises the user and removes its login methods, sessions and uploaded avatar.
+// The row itself is kept so that references, e.g. in audit logs, stay valid.
+func (s *Service) purgeAccount(ctx context.Context, uid int64) error {
+	var avatar string
+	sessions, err := dao.WithTx[[]int64](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*[]int64, error) {
+		item, err := client.User.Get(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		if item.DeleteAt == 0 || item.DeletedAt != 0 {
+			return &[]int64{}, nil
+		}
+		avatar = item.Avatar
+		err = client.User.UpdateOne(item).
+			SetUsername(fmt.Sprintf("deleted_%d", item.ID)).
+			SetNickname("").
+			SetRemark("").
+			SetAvatar("").
+			SetFlags(0).
+			SetDeleteAt(0).
+			SetDeletedAt(time.Now().Unix()).
+			Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = client.UserPlatform.Delete().Where(userplatform.UserIDEQ(uid)).Exec(ctx); err != nil {
+			return nil, err
+		}
+		if _, err = client.UserFlagRecord.Delete().Where(userflagrecord.UserIDEQ(uid)).Exec(ctx); err != nil {
+			return nil, err
+		}
+		ids, err := client.UserSession.Query().
+			Where(usersession.UIDEQ(uid), usersession.IsRevokedEQ(false)).
+			IDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &ids, nil
+	})
+	if err != nil {
+		return err
+	}
+	if err = s.revokeUserSessions(ctx, *sessions...); err != nil {
+		return err
+	}
+	if err = s.cache.Del(ctx, userflag.CacheKey(uid)); err != nil {
+		return err
+	}
+	// 只删除用户自己上传的文件, 其他来源的头像可能被共用
+	if avatar != "" && isUserUploadKey(avatar, uid) {
+		if err = s.storage.DeleteFile(ctx, avatar); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeDeletedAccounts purges the accounts whose deletion grace period has ended and returns how many were purged.
+func (s *Service) PurgeDeletedAccounts(ctx context.Context) (int, error) {
+	ids, err := s.db.User.Query().
+		Where(user.DeleteAtGT(0), user.DeleteAtLTE(time.Now().Unix()), user.DeletedAtEQ(0)).
+		Limit(AccountPurgeBatchSize).
+		IDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, id := range ids {
+		if err = s.purgeAccount(ctx, id); err != nil {
+			log.Warn("purge account failed", log.Any("uid", id), log.Any("error", err.Error()))
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
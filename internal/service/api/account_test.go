@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"testing"
+	"time"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userflagrecord"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/service/shared"
+)
+
+func TestRejectImpersonation(t *testing.T) {
+	user := &ent.User{ID: 1}
+	normal := auth.RenderClaims(user, "test:alice", 1, time.Hour)
+	impersonation := auth.RenderImpersonationClaims(user, 2, time.Hour)
+
+	if err := rejectImpersonation(context.Background()); err != nil {
+		t.Fatalf("expected requests without claims to pass, got %v", err)
+	}
+	if err := rejectImpersonation(context.WithValue(context.Background(), AuthContextKeyClaims, &normal)); err != nil {
+		t.Fatalf("expected user tokens to pass, got %v", err)
+	}
+	ctx := context.WithValue(context.Background(), AuthContextKeyClaims, &impersonation)
+	if err := rejectImpersonation(ctx); !errors.Is(err, apiv1.AuthError_AUTH_ERROR_IMPERSONATION_READ_ONLY) {
+		t.Fatalf("expected impersonation tokens to be rejected, got %v", err)
+	}
+}
+
+func TestPurgeDeletedAccounts(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute).Unix()
+
+	alice := createTestUser(t, db, "alice")
+	avatar := path.Join(UserStorageDir, shared.UploadKeyBuilder(alice.ID)("avatar.png"))
+	alice = db.User.UpdateOne(alice).
+		SetNickname("Alice").
+		SetRemark("remark").
+		SetAvatar(avatar).
+		SetFlags(1).
+		SetDeleteAt(past).
+		SaveX(ctx)
+	email := createTestPlatform(t, db, alice, userplatform.PlatformEmail, "alice@example.com")
+	createTestPlatform(t, db, alice, userplatform.PlatformPhone, "+8613800138000")
+	db.UserFlagRecord.Create().SetUserID(alice.ID).SetFlag("banned").SaveX(ctx)
+	claims := createTestPlatformSession(t, s, db, alice, email)
+
+	// 头像来自其他用户的上传时不能删除
+	bob := createTestUser(t, db, "bob")
+	otherAvatar := path.Join(UserStorageDir, shared.UploadKeyBuilder(alice.ID)("other.png"))
+	bob = db.User.UpdateOne(bob).SetAvatar(otherAvatar).SetDeleteAt(past).SaveX(ctx)
+
+	carol := createTestUser(t, db, "carol")
+	carol = db.User.UpdateOne(carol).SetDeleteAt(time.Now().Add(time.Hour).Unix()).SaveX(ctx)
+
+	purged, err := s.PurgeDeletedAccounts(ctx)
+	if err != nil {
+		t.Fatalf("PurgeDeletedAccounts failed: %v", err)
+	}
+	if purged != 2 {
+		t.Fatalf("expected 2 accounts to be purged, got %d", purged)
+	}
+
+	got := db.User.GetX(ctx, alice.ID)
+	if got.Username != fmt.Sprintf("deleted_%d", alice.ID) || got.Nickname != "" || got.Remark != "" ||
+		got.Avatar != "" || got.Flags != 0 || got.DeleteAt != 0 || got.DeletedAt == 0 {
+		t.Fatalf("expected alice to be anonymised, got %+v", got)
+	}
+	if n := db.UserPlatform.Query().Where(userplatform.UserIDEQ(alice.ID)).CountX(ctx); n != 0 {
+		t.Fatalf("expected the platforms to be deleted, got %d", n)
+	}
+	if n := db.UserFlagRecord.Query().Where(userflagrecord.UserIDEQ(alice.ID)).CountX(ctx); n != 0 {
+		t.Fatalf("expected the flag records to be deleted, got %d", n)
+	}
+	if err = s.CheckAccessToken(ctx, claims); !errors.Is(err, apiv1.UserSessionError_USER_SESSION_ERROR_TOKEN_DENIED) {
+		t.Fatalf("expected the sessions to be revoked, got %v", err)
+	}
+
+	deleted := s.storage.(*testStorage).deleted
+	if !slices.Equal(deleted, []string{avatar}) {
+		t.Fatalf("expected only the own avatar %s to be deleted, got %v", avatar, deleted)
+	}
+	if got = db.User.GetX(ctx, bob.ID); got.DeletedAt == 0 || got.Avatar != "" {
+		t.Fatalf("expected bob to be purged, got %+v", got)
+	}
+	if got = db.User.GetX(ctx, carol.ID); got.DeletedAt != 0 || got.Username != "carol" {
+		t.Fatalf("expected carol to be kept during the grace period, got %+v", got)
+	}
+
+	if err = s.purgeAccount(ctx, alice.ID); err != nil {
+		t.Fatalf("expected purging again to be a no-op, got %v", err)
+	}
+	if n := len(s.storage.(*testStorage).deleted); n != 1 {
+		t.Fatalf("expected no further file to be deleted, got %d", n)
+	}
+}
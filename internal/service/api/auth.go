@@ -8,39 +8,100 @@ import (
 	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/pkg/wxapp"
 	"github.com/go-sphere/sphere/utils/idgenerator"
+	"github.com/go-sphere/weixin-mp-api/wechat"
 )
 
 var _ apiv1.AuthServiceHTTPServer = (*Service)(nil)
 
-func (s *Service) AuthWithWxMini(ctx context.Context, request *apiv1.AuthWithWxMiniRequest) (*apiv1.AuthWithWxMiniResponse, error) {
-	data, err := s.wechat.JsCode2Session(ctx, request.Code)
-	if err != nil {
-		return nil, err
+// wechatApp returns the mini program and its app id, the default mini program when appID is empty.
+func (s *Service) wechatApp(appID string) (string, *wechat.Wechat, error) {
+	appID, app, ok := s.wechat.Get(appID)
+	if !ok {
+		return "", nil, apiv1.AuthError_AUTH_ERROR_WECHAT_APP_NOT_FOUND
 	}
-	if data == nil {
-		return nil, fmt.Errorf("failed to get session data from WeChat")
+	return appID, app, nil
+}
+
+func (s *Service) wechatOfficialAccount(appID string) (*wxapp.OfficialAccount, error) {
+	account, ok := s.wechat.OfficialAccount(appID)
+	if !ok {
+		return nil, apiv1.AuthError_AUTH_ERROR_WECHAT_APP_NOT_FOUND
 	}
+	return account, nil
+}
+
+// authWithWechat logs in the user of the openid, which is resolved to the user of another app
+// of the same open platform account by its unionid before a new user is created.
+func (s *Service) authWithWechat(ctx context.Context, platform userplatform.Platform, appID string, openID string, unionID string) (*auth.Response, *UserToken, error) {
 	res, err := auth.Auth(
-		ctx, s.db, data.OpenID, userplatform.PlatformWechatMini,
-		auth.WithAuthMode(auth.CreateWithoutCheck),
+		ctx, s.db, openID, platform,
+		auth.MatchUnionID(unionID),
+		auth.WithAppID(appID),
 		auth.WithOnCreateUser(func(user *ent.UserCreate) *ent.UserCreate {
 			return user.SetUsername(fmt.Sprintf("wx_%d", idgenerator.NextId()))
 		}),
 		auth.WithOnCreatePlatform(func(platform *ent.UserPlatformCreate) *ent.UserPlatformCreate {
-			return platform.SetSecondID(data.UnionID)
+			return platform.SetSecondID(unionID)
 		}),
 	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = s.CheckUserStatus(ctx, res.User.ID); err != nil {
+		return nil, nil, err
+	}
+	token, err := s.createUserToken(ctx, s.db.Client, res.User, auth.PlatformSubject(res.Platform), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, token, nil
+}
+
+func (s *Service) AuthWithWxMini(ctx context.Context, request *apiv1.AuthWithWxMiniRequest) (*apiv1.AuthWithWxMiniResponse, error) {
+	appID, app, err := s.wechatApp(request.AppId)
 	if err != nil {
 		return nil, err
 	}
-	token, err := s.authorizer.GenerateToken(ctx, auth.RenderClaims(res.User, res.Platform, auth.AppTokenValidDuration))
+	data, err := app.JsCode2Session(ctx, request.Code)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("failed to get session data from WeChat")
+	}
+	res, token, err := s.authWithWechat(ctx, userplatform.PlatformWechatMini, appID, data.OpenID, data.UnionID)
 	if err != nil {
 		return nil, err
 	}
 	return &apiv1.AuthWithWxMiniResponse{
-		IsNew: res.IsNew,
-		Token: token,
-		User:  s.render.User(res.User),
+		IsNew:        res.IsNew,
+		Token:        token.AccessToken,
+		User:         s.render.User(res.User),
+		RefreshToken: token.RefreshToken,
+		Expires:      token.Expires,
+	}, nil
+}
+
+func (s *Service) AuthWithWxOfficial(ctx context.Context, request *apiv1.AuthWithWxOfficialRequest) (*apiv1.AuthWithWxOfficialResponse, error) {
+	account, err := s.wechatOfficialAccount(request.AppId)
+	if err != nil {
+		return nil, err
+	}
+	data, err := account.ExchangeCode(ctx, request.Code)
+	if err != nil {
+		return nil, err
+	}
+	res, token, err := s.authWithWechat(ctx, userplatform.PlatformWechatOa, account.AppID(), data.OpenID, data.UnionID)
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.AuthWithWxOfficialResponse{
+		IsNew:        res.IsNew,
+		Token:        token.AccessToken,
+		User:         s.render.User(res.User),
+		RefreshToken: token.RefreshToken,
+		Expires:      token.Expires,
 	}, nil
 }
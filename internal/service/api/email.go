@@ -0,0 +1,320 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sphere/httpx"
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/usersession"
+	"github.com/go-sphere/sphere-layout/internal/pkg/security"
+	"github.com/go-sphere/sphere/utils/idgenerator"
+	"github.com/go-sphere/sphere/utils/secure"
+	"github.com/google/uuid"
+)
+
+const (
+	EmailVerifyTokenValidDuration   = time.Hour * 24
+	PasswordResetTokenValidDuration = time.Minute * 30
+
+	EmailSendInterval       = time.Minute
+	EmailRegisterIPLimit    = 10
+	EmailRegisterIPWindow   = time.Hour
+	EmailLoginAttemptLimit  = 10
+	EmailLoginAttemptWindow = time.Minute * 15
+)
+
+// EmailSender delivers plain text emails, see mail.SMTPSender.
+type EmailSender interface {
+	SendEmail(ctx context.Context, to string, subject string, body string) error
+}
+
+// EmailLinks are the pages of the client which receive the tokens sent by email,
+// the token is appended as the "token" query parameter.
+type EmailLinks struct {
+	VerifyURL string `json:"verify_url" yaml:"verify_url"`
+	ResetURL  string `json:"reset_url" yaml:"reset_url"`
+}
+
+func emailVerifyKey(token string) string {
+	return "email_verify:" + token
+}
+
+func passwordResetKey(token string) string {
+	return "password_reset:" + token
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func userWeakPasswordError(err error) error {
+	code := apiv1.AuthError_AUTH_ERROR_WEAK_PASSWORD
+	return httpx.NewError(code.GetStatus(), code.GetCode(), err.Error(), code)
+}
+
+func (s *Service) InitEmail(sender EmailSender, links EmailLinks, passwordPolicy security.PasswordPolicy) {
+	s.email = sender
+	s.emailLinks = links
+	s.passwordPolicy = passwordPolicy
+}
+
+func (s *Service) findEmailPlatform(ctx context.Context, email string) (*ent.UserPlatform, error) {
+	return s.db.UserPlatform.Query().
+		Where(userplatform.PlatformEQ(userplatform.PlatformEmail), userplatform.PlatformIDEQ(email)).
+		Only(ctx)
+}
+
+// sendEmailToken stores a one-time token pointing to the email platform and mails the link containing it.
+func (s *Service) sendEmailToken(ctx context.Context, platform *ent.UserPlatform, key func(string) string, ttl time.Duration, link string, subject string, body string) error {
+	limited := rateLimit{"email_interval:" + platform.PlatformID, 1, EmailSendInterval}
+	if err := s.checkRateLimits(ctx, apiv1.AuthError_AUTH_ERROR_EMAIL_TOO_FREQUENT, limited); err != nil {
+		return err
+	}
+	newUUID, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	token := newUUID.String()
+	err = s.cache.SetWithTTL(ctx, key(token), []byte(strconv.FormatInt(platform.ID, 10)), ttl)
+	if err != nil {
+		return err
+	}
+	target, err := url.Parse(link)
+	if err != nil {
+		return err
+	}
+	query := target.Query()
+	query.Set("token", token)
+	target.RawQuery = query.Encode()
+	return s.email.SendEmail(ctx, platform.PlatformID, subject, fmt.Sprintf(body, target.String()))
+}
+
+// consumeEmailToken returns the email platform of a token, the token can only be used once.
+func (s *Service) consumeEmailToken(ctx context.Context, key string) (*ent.UserPlatform, error) {
+	raw, found, err := s.cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, apiv1.AuthError_AUTH_ERROR_EMAIL_TOKEN_INVALID
+	}
+	if err = s.cache.Del(ctx, key); err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return nil, apiv1.AuthError_AUTH_ERROR_EMAIL_TOKEN_INVALID
+	}
+	platform, err := s.db.UserPlatform.Get(ctx, id)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, apiv1.AuthError_AUTH_ERROR_EMAIL_TOKEN_INVALID
+		}
+		return nil, err
+	}
+	return platform, nil
+}
+
+func (s *Service) sendEmailVerification(ctx context.Context, platform *ent.UserPlatform) error {
+	return s.sendEmailToken(
+		ctx, platform, emailVerifyKey, EmailVerifyTokenValidDuration, s.emailLinks.VerifyURL,
+		"验证你的邮箱",
+		"请打开以下链接完成邮箱验证, 链接 24 小时内有效:\n\n%s\n\n如果这不是你本人的操作, 请忽略此邮件.",
+	)
+}
+
+// sendEmailExistsNotice tells the owner of a registered email that someone tried to register with it,
+// the response of the registration stays the same so that it does not reveal registered emails.
+func (s *Service) sendEmailExistsNotice(ctx context.Context, email string) error {
+	limited := rateLimit{"email_interval:" + email, 1, EmailSendInterval}
+	err := s.checkRateLimits(ctx, apiv1.AuthError_AUTH_ERROR_EMAIL_TOO_FREQUENT, limited)
+	if err != nil {
+		if errors.Is(err, apiv1.AuthError_AUTH_ERROR_EMAIL_TOO_FREQUENT) {
+			return nil
+		}
+		return err
+	}
+	return s.email.SendEmail(
+		ctx, email,
+		"邮箱已注册",
+		"有人尝试使用此邮箱注册新账号, 但该邮箱已经注册. 如果忘记了密码, 可以通过找回密码重新设置.\n\n如果这不是你本人的操作, 请忽略此邮件.",
+	)
+}
+
+func (s *Service) RegisterWithEmail(ctx context.Context, request *apiv1.RegisterWithEmailRequest) (*apiv1.RegisterWithEmailResponse, error) {
+	if err := s.passwordPolicy.Validate(request.Password); err != nil {
+		return nil, userWeakPasswordError(err)
+	}
+	if ip, ok := ctx.Value(AuthContextKeyIP).(string); ok && ip != "" {
+		limited := rateLimit{"email_register:ip:" + ip, EmailRegisterIPLimit, EmailRegisterIPWindow}
+		if err := s.checkRateLimits(ctx, apiv1.AuthError_AUTH_ERROR_EMAIL_TOO_FREQUENT, limited); err != nil {
+			return nil, err
+		}
+	}
+	email := normalizeEmail(request.Email)
+	exist, err := s.db.UserPlatform.Query().
+		Where(userplatform.PlatformEQ(userplatform.PlatformEmail), userplatform.PlatformIDEQ(email)).
+		Exist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 不暴露邮箱是否已注册
+	if exist {
+		if err = s.sendEmailExistsNotice(ctx, email); err != nil {
+			return nil, err
+		}
+		return &apiv1.RegisterWithEmailResponse{}, nil
+	}
+	res, err := auth.Auth(
+		ctx, s.db, email, userplatform.PlatformEmail,
+		auth.WithAuthMode(auth.CreateWithoutCheck),
+		auth.WithOnCreateUser(func(user *ent.UserCreate) *ent.UserCreate {
+			return user.SetUsername(fmt.Sprintf("email_%d", idgenerator.NextId()))
+		}),
+		auth.WithOnCreatePlatform(func(platform *ent.UserPlatformCreate) *ent.UserPlatformCreate {
+			return platform.SetPrivateKey(secure.CryptPassword(request.Password))
+		}),
+	)
+	if err != nil {
+		if ent.IsConstraintError(err) {
+			return &apiv1.RegisterWithEmailResponse{}, nil
+		}
+		return nil, err
+	}
+	if err = s.sendEmailVerification(ctx, res.Platform); err != nil {
+		return nil, err
+	}
+	return &apiv1.RegisterWithEmailResponse{}, nil
+}
+
+func (s *Service) SendEmailVerification(ctx context.Context, request *apiv1.SendEmailVerificationRequest) (*apiv1.SendEmailVerificationResponse, error) {
+	platform, err := s.findEmailPlatform(ctx, normalizeEmail(request.Email))
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return &apiv1.SendEmailVerificationResponse{}, nil
+		}
+		return nil, err
+	}
+	if platform.VerifiedAt == 0 {
+		if err = s.sendEmailVerification(ctx, platform); err != nil {
+			return nil, err
+		}
+	}
+	return &apiv1.SendEmailVerificationResponse{}, nil
+}
+
+func (s *Service) VerifyEmail(ctx context.Context, request *apiv1.VerifyEmailRequest) (*apiv1.VerifyEmailResponse, error) {
+	platform, err := s.consumeEmailToken(ctx, emailVerifyKey(request.Token))
+	if err != nil {
+		return nil, err
+	}
+	if platform.VerifiedAt == 0 {
+		err = s.db.UserPlatform.UpdateOneID(platform.ID).SetVerifiedAt(time.Now().Unix()).Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &apiv1.VerifyEmailResponse{}, nil
+}
+
+func (s *Service) AuthWithEmail(ctx context.Context, request *apiv1.AuthWithEmailRequest) (*apiv1.AuthWithEmailResponse, error) {
+	email := normalizeEmail(request.Email)
+	limited := rateLimit{"email_login:" + email, EmailLoginAttemptLimit, EmailLoginAttemptWindow}
+	if err := s.checkRateLimits(ctx, apiv1.AuthError_AUTH_ERROR_EMAIL_TOO_FREQUENT, limited); err != nil {
+		return nil, err
+	}
+	platform, err := s.findEmailPlatform(ctx, email)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, apiv1.AuthError_AUTH_ERROR_INVALID_CREDENTIALS
+		}
+		return nil, err
+	}
+	if platform.PrivateKey == "" || !secure.IsPasswordMatch(request.Password, platform.PrivateKey) {
+		return nil, apiv1.AuthError_AUTH_ERROR_INVALID_CREDENTIALS
+	}
+	if platform.VerifiedAt == 0 {
+		return nil, apiv1.AuthError_AUTH_ERROR_EMAIL_NOT_VERIFIED
+	}
+	if err = s.CheckUserStatus(ctx, platform.UserID); err != nil {
+		return nil, err
+	}
+	user, err := s.db.User.Get(ctx, platform.UserID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.createUserToken(ctx, s.db.Client, user, auth.PlatformSubject(platform), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.AuthWithEmailResponse{
+		Token:        token.AccessToken,
+		User:         s.render.User(user),
+		RefreshToken: token.RefreshToken,
+		Expires:      token.Expires,
+	}, nil
+}
+
+func (s *Service) SendPasswordReset(ctx context.Context, request *apiv1.SendPasswordResetRequest) (*apiv1.SendPasswordResetResponse, error) {
+	platform, err := s.findEmailPlatform(ctx, normalizeEmail(request.Email))
+	if err != nil {
+		// 不暴露邮箱是否已注册
+		if ent.IsNotFound(err) {
+			return &apiv1.SendPasswordResetResponse{}, nil
+		}
+		return nil, err
+	}
+	err = s.sendEmailToken(
+		ctx, platform, passwordResetKey, PasswordResetTokenValidDuration, s.emailLinks.ResetURL,
+		"重置密码",
+		"请打开以下链接重置密码, 链接 30 分钟内有效:\n\n%s\n\n如果这不是你本人的操作, 请忽略此邮件, 你的密码不会改变.",
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.SendPasswordResetResponse{}, nil
+}
+
+func (s *Service) ResetPassword(ctx context.Context, request *apiv1.ResetPasswordRequest) (*apiv1.ResetPasswordResponse, error) {
+	if err := s.passwordPolicy.Validate(request.Password); err != nil {
+		return nil, userWeakPasswordError(err)
+	}
+	platform, err := s.consumeEmailToken(ctx, passwordResetKey(request.Token))
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := dao.WithTx[[]int64](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*[]int64, error) {
+		update := client.UserPlatform.UpdateOneID(platform.ID).SetPrivateKey(secure.CryptPassword(request.Password))
+		// 能收到重置邮件即证明拥有该邮箱
+		if platform.VerifiedAt == 0 {
+			update = update.SetVerifiedAt(time.Now().Unix())
+		}
+		if err := update.Exec(ctx); err != nil {
+			return nil, err
+		}
+		ids, err := client.UserSession.Query().
+			Where(usersession.UIDEQ(platform.UserID), usersession.IsRevokedEQ(false)).
+			IDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &ids, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = s.revokeUserSessions(ctx, *sessions...); err != nil {
+		return nil, err
+	}
+	return &apiv1.ResetPasswordResponse{}, nil
+}
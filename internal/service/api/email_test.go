@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/mail"
+	"github.com/go-sphere/sphere-layout/internal/pkg/security"
+)
+
+func newTestEmailService(t *testing.T) (*Service, *mail.LogSender) {
+	t.Helper()
+
+	s, _ := newTestService(t)
+	sender := mail.NewLogSender()
+	s.InitEmail(sender, EmailLinks{
+		VerifyURL: "http://localhost/verify",
+		ResetURL:  "http://localhost/reset",
+	}, security.PasswordPolicy{MinLength: 8})
+	return s, sender
+}
+
+func TestRegisterWithEmailDoesNotRevealExisting(t *testing.T) {
+	s, sender := newTestEmailService(t)
+	ctx := context.Background()
+	request := &apiv1.RegisterWithEmailRequest{Email: "Alice@Example.com", Password: "password123"}
+
+	if _, err := s.RegisterWithEmail(ctx, request); err != nil {
+		t.Fatalf("RegisterWithEmail failed: %v", err)
+	}
+	verify, ok := sender.LastMessage("alice@example.com")
+	if !ok || !strings.Contains(verify.Body, "http://localhost/verify?token=") {
+		t.Fatalf("expected a verification email, got %+v", verify)
+	}
+
+	// 发送间隔内再次注册不会发送提醒, 但仍然返回成功
+	if _, err := s.RegisterWithEmail(ctx, request); err != nil {
+		t.Fatalf("expected registering an existing email to succeed, got %v", err)
+	}
+	if msg, _ := sender.LastMessage("alice@example.com"); msg != verify {
+		t.Fatalf("expected no notice within the send interval, got %+v", msg)
+	}
+	if err := s.cache.Del(ctx, "email_interval:alice@example.com"); err != nil {
+		t.Fatalf("clear send interval failed: %v", err)
+	}
+	if _, err := s.RegisterWithEmail(ctx, request); err != nil {
+		t.Fatalf("expected registering an existing email to succeed, got %v", err)
+	}
+	notice, _ := sender.LastMessage("alice@example.com")
+	if notice.Subject != "邮箱已注册" || strings.Contains(notice.Body, "token=") {
+		t.Fatalf("expected a notice without token, got %+v", notice)
+	}
+
+	count, err := s.db.UserPlatform.Query().Count(ctx)
+	if err != nil {
+		t.Fatalf("count platforms failed: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected a single email platform, got %d", count)
+	}
+}
+
+func TestRegisterWithEmailIPLimit(t *testing.T) {
+	s, _ := newTestEmailService(t)
+	ctx := context.WithValue(context.Background(), AuthContextKeyIP, "203.0.113.1")
+
+	for i := 0; i < EmailRegisterIPLimit; i++ {
+		request := &apiv1.RegisterWithEmailRequest{Email: fmt.Sprintf("user%d@example.com", i), Password: "password123"}
+		if _, err := s.RegisterWithEmail(ctx, request); err != nil {
+			t.Fatalf("register %d: RegisterWithEmail failed: %v", i, err)
+		}
+	}
+	request := &apiv1.RegisterWithEmailRequest{Email: "user@example.com", Password: "password123"}
+	_, err := s.RegisterWithEmail(ctx, request)
+	if !errors.Is(err, apiv1.AuthError_AUTH_ERROR_EMAIL_TOO_FREQUENT) {
+		t.Fatalf("expected the ip limit to be reached, got %v", err)
+	}
+
+	other := context.WithValue(context.Background(), AuthContextKeyIP, "203.0.113.2")
+	if _, err = s.RegisterWithEmail(other, request); err != nil {
+		t.Fatalf("expected another ip to be allowed, got %v", err)
+	}
+}
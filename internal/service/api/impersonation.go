@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+)
+
+// ImpersonatedRequest describes a write made with a token issued by dash impersonation.
+type ImpersonatedRequest struct {
+	Impersonator int64
+	UserID       int64
+	Operation    string
+	IPAddress    string
+	UserAgent    string
+	Blocked      bool
+}
+
+// RecordImpersonatedRequest writes the request to the audit log shared with dash,
+// attributed to the impersonating admin.
+func (s *Service) RecordImpersonatedRequest(ctx context.Context, request ImpersonatedRequest) error {
+	action := "impersonated_write"
+	if request.Blocked {
+		action = "impersonated_write_blocked"
+	}
+	return s.db.AuditLog.Create().
+		SetUID(request.Impersonator).
+		SetOperation(request.Operation).
+		SetEntity("User").
+		SetTargetID(request.UserID).
+		SetAction(action).
+		SetIPAddress(request.IPAddress).
+		SetUserAgent(request.UserAgent).
+		Exec(ctx)
+}
@@ -0,0 +1,323 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/usersession"
+	"github.com/go-sphere/sphere-layout/internal/pkg/userflag"
+	"github.com/go-sphere/sphere/utils/secure"
+)
+
+// wechatPlatformAppID returns the app a WeChat platform belongs to. Mini program platforms
+// created before the app id was recorded belong to the default mini program.
+func (s *Service) wechatPlatformAppID(platform *ent.UserPlatform) string {
+	if platform.AppID == "" && platform.Platform == userplatform.PlatformWechatMini {
+		return s.wechat.MainAppID()
+	}
+	return platform.AppID
+}
+
+// platformKey identifies the kind of platform a user binds once. A WeChat platform is bound once for every app,
+// so two openids of the same app conflict while those of different apps do not.
+func (s *Service) platformKey(platform *ent.UserPlatform) string {
+	if slices.Contains(auth.WechatPlatforms, platform.Platform) {
+		return string(platform.Platform) + ":" + s.wechatPlatformAppID(platform)
+	}
+	return string(platform.Platform)
+}
+
+type linkResult struct {
+	Platform *ent.UserPlatform
+	Created  bool
+	// Merged is the user which owned the platform and was merged into the current user.
+	Merged   int64
+	Sessions []int64
+}
+
+// linkPlatform attaches the platform to the user. A platform owned by another user is only taken over
+// when merge is set, the other user is then merged into the current one together with all its platforms.
+// The caller must have verified that the user owns the platform.
+func (s *Service) linkPlatform(ctx context.Context, uid int64, platform userplatform.Platform, platformID string, merge bool, onCreate func(*ent.UserPlatformCreate) *ent.UserPlatformCreate) (*linkResult, error) {
+	owner, err := s.db.UserPlatform.Query().
+		Where(userplatform.PlatformEQ(platform), userplatform.PlatformIDEQ(platformID)).
+		First(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		return nil, err
+	}
+	if owner != nil && owner.UserID != uid {
+		if !merge {
+			return nil, apiv1.UserError_USER_ERROR_LINKED_TO_OTHER
+		}
+		// 不允许通过合并绕过封禁
+		if err = s.CheckUserStatus(ctx, owner.UserID); err != nil {
+			return nil, err
+		}
+	}
+	result, err := dao.WithTx[linkResult](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*linkResult, error) {
+		current, err := client.User.Get(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		owned, err := client.UserPlatform.Query().Where(userplatform.UserIDEQ(uid)).All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		existing, err := client.UserPlatform.Query().
+			Where(userplatform.PlatformEQ(platform), userplatform.PlatformIDEQ(platformID)).
+			First(ctx)
+		if err != nil && !ent.IsNotFound(err) {
+			return nil, err
+		}
+		if existing != nil && existing.UserID == uid {
+			return &linkResult{Platform: existing}, nil
+		}
+		if existing == nil {
+			create := client.UserPlatform.Create().
+				SetUserID(uid).
+				SetPlatform(platform).
+				SetPlatformID(platformID)
+			if onCreate != nil {
+				create = onCreate(create)
+			}
+			appID, _ := create.Mutation().AppID()
+			key := s.platformKey(&ent.UserPlatform{Platform: platform, AppID: appID})
+			for _, p := range owned {
+				if s.platformKey(p) == key {
+					return nil, apiv1.UserError_USER_ERROR_ALREADY_LINKED
+				}
+			}
+			created, err := create.Save(ctx)
+			if err != nil {
+				if ent.IsConstraintError(err) {
+					return nil, apiv1.UserError_USER_ERROR_PLATFORM_BOUND
+				}
+				return nil, err
+			}
+			return &linkResult{Platform: created, Created: true}, nil
+		}
+		if !merge {
+			return nil, apiv1.UserError_USER_ERROR_LINKED_TO_OTHER
+		}
+		source, err := client.User.Get(ctx, existing.UserID)
+		if err != nil {
+			return nil, err
+		}
+		sourcePlatforms, err := client.UserPlatform.Query().Where(userplatform.UserIDEQ(source.ID)).All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, sp := range sourcePlatforms {
+			for _, p := range owned {
+				if s.platformKey(p) == s.platformKey(sp) {
+					return nil, apiv1.UserError_USER_ERROR_MERGE_CONFLICT
+				}
+			}
+		}
+		sessions, err := dao.MergeUser(ctx, client, current, source)
+		if err != nil {
+			return nil, err
+		}
+		return &linkResult{Platform: existing, Merged: source.ID, Sessions: sessions}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Merged != 0 {
+		if err = s.revokeUserSessions(ctx, result.Sessions...); err != nil {
+			return nil, err
+		}
+		if err = s.cache.Del(ctx, userflag.CacheKey(result.Merged)); err != nil {
+			return nil, err
+		}
+		if err = s.cache.Del(ctx, userflag.CacheKey(uid)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (s *Service) LinkPhone(ctx context.Context, request *apiv1.LinkPhoneRequest) (*apiv1.LinkPhoneResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	phone, err := s.normalizePhone(request.Phone, "")
+	if err != nil {
+		return nil, err
+	}
+	if err = s.verifySmsCode(ctx, phone, request.Code); err != nil {
+		return nil, err
+	}
+	result, err := s.linkPlatform(ctx, uid, userplatform.PlatformPhone, phone, request.Merge, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.LinkPhoneResponse{
+		Merged: result.Merged != 0,
+	}, nil
+}
+
+// checkEmailOwnership checks that the user linking a registered email owns it, which is proven by its password.
+// The email of another user must also be verified, otherwise its registrant never proved owning the address.
+func checkEmailOwnership(existing *ent.UserPlatform, uid int64, password string) error {
+	if existing.PrivateKey == "" || !secure.IsPasswordMatch(password, existing.PrivateKey) {
+		return apiv1.AuthError_AUTH_ERROR_INVALID_CREDENTIALS
+	}
+	if existing.UserID != uid && existing.VerifiedAt == 0 {
+		return apiv1.AuthError_AUTH_ERROR_EMAIL_NOT_VERIFIED
+	}
+	return nil
+}
+
+func (s *Service) LinkEmail(ctx context.Context, request *apiv1.LinkEmailRequest) (*apiv1.LinkEmailResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	email := normalizeEmail(request.Email)
+	existing, err := s.findEmailPlatform(ctx, email)
+	if err != nil && !ent.IsNotFound(err) {
+		return nil, err
+	}
+	if existing != nil {
+		if err = checkEmailOwnership(existing, uid, request.Password); err != nil {
+			return nil, err
+		}
+	} else if err = s.passwordPolicy.Validate(request.Password); err != nil {
+		return nil, userWeakPasswordError(err)
+	}
+	result, err := s.linkPlatform(ctx, uid, userplatform.PlatformEmail, email, request.Merge, func(create *ent.UserPlatformCreate) *ent.UserPlatformCreate {
+		return create.SetPrivateKey(secure.CryptPassword(request.Password))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Created {
+		if err = s.sendEmailVerification(ctx, result.Platform); err != nil {
+			return nil, err
+		}
+	}
+	return &apiv1.LinkEmailResponse{
+		Merged: result.Merged != 0,
+	}, nil
+}
+
+func (s *Service) LinkWxMini(ctx context.Context, request *apiv1.LinkWxMiniRequest) (*apiv1.LinkWxMiniResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	appID, app, err := s.wechatApp(request.AppId)
+	if err != nil {
+		return nil, err
+	}
+	data, err := app.JsCode2Session(ctx, request.Code)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("failed to get session data from WeChat")
+	}
+	result, err := s.linkPlatform(ctx, uid, userplatform.PlatformWechatMini, data.OpenID, request.Merge, func(create *ent.UserPlatformCreate) *ent.UserPlatformCreate {
+		return create.SetSecondID(data.UnionID).SetAppID(appID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.LinkWxMiniResponse{
+		Merged: result.Merged != 0,
+	}, nil
+}
+
+func (s *Service) LinkWxOfficial(ctx context.Context, request *apiv1.LinkWxOfficialRequest) (*apiv1.LinkWxOfficialResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	account, err := s.wechatOfficialAccount(request.AppId)
+	if err != nil {
+		return nil, err
+	}
+	data, err := account.ExchangeCode(ctx, request.Code)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.linkPlatform(ctx, uid, userplatform.PlatformWechatOa, data.OpenID, request.Merge, func(create *ent.UserPlatformCreate) *ent.UserPlatformCreate {
+		return create.SetSecondID(data.UnionID).SetAppID(account.AppID())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.LinkWxOfficialResponse{
+		Merged: result.Merged != 0,
+	}, nil
+}
+
+func (s *Service) UnlinkPlatform(ctx context.Context, request *apiv1.UnlinkPlatformRequest) (*apiv1.UnlinkPlatformResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.unlinkPlatform(ctx, uid, userplatform.Platform(request.Platform), request.AppId); err != nil {
+		return nil, err
+	}
+	return &apiv1.UnlinkPlatformResponse{}, nil
+}
+
+// unlinkPlatform removes the platforms of the kind from the user and revokes the sessions logged in with them.
+// appID limits a WeChat platform to one app, every app of the kind is removed when it is empty.
+// The last platform of a user cannot be removed.
+func (s *Service) unlinkPlatform(ctx context.Context, uid int64, platform userplatform.Platform, appID string) error {
+	if err := userplatform.PlatformValidator(platform); err != nil {
+		return apiv1.UserError_USER_ERROR_NOT_LINKED
+	}
+	sessions, err := dao.WithTx[[]int64](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*[]int64, error) {
+		owned, err := client.UserPlatform.Query().Where(userplatform.UserIDEQ(uid)).All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var removed []*ent.UserPlatform
+		for _, p := range owned {
+			if p.Platform == platform && (appID == "" || s.wechatPlatformAppID(p) == appID) {
+				removed = append(removed, p)
+			}
+		}
+		if len(removed) == 0 {
+			return nil, apiv1.UserError_USER_ERROR_NOT_LINKED
+		}
+		if len(removed) == len(owned) {
+			return nil, apiv1.UserError_USER_ERROR_LAST_PLATFORM
+		}
+		ids := make([]int64, 0, len(removed))
+		subjects := make([]string, 0, len(removed))
+		for _, p := range removed {
+			ids = append(ids, p.ID)
+			subjects = append(subjects, auth.PlatformSubject(p))
+		}
+		if _, err = client.UserPlatform.Delete().Where(userplatform.IDIn(ids...)).Exec(ctx); err != nil {
+			return nil, err
+		}
+		sessions, err := client.UserSession.Query().
+			Where(
+				usersession.UIDEQ(uid),
+				usersession.SubjectIn(subjects...),
+				usersession.IsRevokedEQ(false),
+			).
+			IDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &sessions, nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.revokeUserSessions(ctx, *sessions...)
+}
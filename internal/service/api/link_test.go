@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/pkg/wxapp"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
+	"github.com/go-sphere/sphere/utils/secure"
+	"github.com/go-sphere/weixin-mp-api/wechat"
+)
+
+func createTestPlatform(t *testing.T, db *ent.Client, user *ent.User, platform userplatform.Platform, platformID string) *ent.UserPlatform {
+	t.Helper()
+
+	created, err := db.UserPlatform.Create().
+		SetUserID(user.ID).
+		SetPlatform(platform).
+		SetPlatformID(platformID).
+		Save(context.Background())
+	if err != nil {
+		t.Fatalf("create platform failed: %v", err)
+	}
+	return created
+}
+
+// createTestPlatformSession logs the user in with the platform and returns the claims of the access token.
+func createTestPlatformSession(t *testing.T, s *Service, db *ent.Client, user *ent.User, platform *ent.UserPlatform) *jwtauth.RBACClaims[int64] {
+	t.Helper()
+
+	ctx := context.Background()
+	token, err := s.createUserToken(ctx, db, user, auth.PlatformSubject(platform), nil)
+	if err != nil {
+		t.Fatalf("createUserToken failed: %v", err)
+	}
+	claims, err := s.authorizer.ParseToken(ctx, token.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+	return claims
+}
+
+func TestLinkPlatform(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	alice := createTestUser(t, db, "alice")
+	createTestPlatform(t, db, alice, userplatform.PlatformEmail, "alice@example.com")
+
+	result, err := s.linkPlatform(ctx, alice.ID, userplatform.PlatformPhone, "+8613800138000", false, nil)
+	if err != nil {
+		t.Fatalf("linkPlatform failed: %v", err)
+	}
+	if !result.Created || result.Platform.UserID != alice.ID {
+		t.Fatalf("expected a new phone platform of alice, got %+v", result)
+	}
+	result, err = s.linkPlatform(ctx, alice.ID, userplatform.PlatformPhone, "+8613800138000", false, nil)
+	if err != nil || result.Created {
+		t.Fatalf("expected linking again to return the platform, got %+v, %v", result, err)
+	}
+	_, err = s.linkPlatform(ctx, alice.ID, userplatform.PlatformPhone, "+8613900139000", false, nil)
+	if !errors.Is(err, apiv1.UserError_USER_ERROR_ALREADY_LINKED) {
+		t.Fatalf("expected a second phone to be rejected, got %v", err)
+	}
+
+	bob := createTestUser(t, db, "bob")
+	bobGithub := createTestPlatform(t, db, bob, userplatform.PlatformGithub, "bob")
+	bobClaims := createTestPlatformSession(t, s, db, bob, bobGithub)
+	_, err = s.linkPlatform(ctx, alice.ID, userplatform.PlatformGithub, "bob", false, nil)
+	if !errors.Is(err, apiv1.UserError_USER_ERROR_LINKED_TO_OTHER) {
+		t.Fatalf("expected platform of another user to be rejected without merge, got %v", err)
+	}
+	result, err = s.linkPlatform(ctx, alice.ID, userplatform.PlatformGithub, "bob", true, nil)
+	if err != nil {
+		t.Fatalf("linkPlatform with merge failed: %v", err)
+	}
+	if result.Merged != bob.ID {
+		t.Fatalf("expected bob to be merged, got %+v", result)
+	}
+	if owner := db.UserPlatform.GetX(ctx, bobGithub.ID).UserID; owner != alice.ID {
+		t.Fatalf("expected github platform to move to alice, got owner %d", owner)
+	}
+	if _, err = db.User.Get(ctx, bob.ID); !ent.IsNotFound(err) {
+		t.Fatalf("expected bob to be deleted, got %v", err)
+	}
+	if err = s.CheckAccessToken(ctx, bobClaims); !errors.Is(err, apiv1.UserSessionError_USER_SESSION_ERROR_TOKEN_DENIED) {
+		t.Fatalf("expected the access token of bob to be denied, got %v", err)
+	}
+
+	carol := createTestUser(t, db, "carol")
+	createTestPlatform(t, db, carol, userplatform.PlatformPhone, "+8613700137000")
+	createTestPlatform(t, db, carol, userplatform.PlatformGoogle, "carol")
+	_, err = s.linkPlatform(ctx, alice.ID, userplatform.PlatformGoogle, "carol", true, nil)
+	if !errors.Is(err, apiv1.UserError_USER_ERROR_MERGE_CONFLICT) {
+		t.Fatalf("expected users with a phone each not to be merged, got %v", err)
+	}
+	if _, err = db.User.Get(ctx, carol.ID); err != nil {
+		t.Fatalf("expected carol to be kept, got %v", err)
+	}
+}
+
+func TestCheckEmailOwnership(t *testing.T) {
+	existing := &ent.UserPlatform{
+		UserID:     1,
+		Platform:   userplatform.PlatformEmail,
+		PlatformID: "alice@example.com",
+		PrivateKey: secure.CryptPassword("password123"),
+	}
+	if err := checkEmailOwnership(existing, 1, "wrong-password"); !errors.Is(err, apiv1.AuthError_AUTH_ERROR_INVALID_CREDENTIALS) {
+		t.Fatalf("expected wrong password to be rejected, got %v", err)
+	}
+	if err := checkEmailOwnership(existing, 1, "password123"); err != nil {
+		t.Fatalf("expected the own unverified email to be accepted, got %v", err)
+	}
+	if err := checkEmailOwnership(existing, 2, "password123"); !errors.Is(err, apiv1.AuthError_AUTH_ERROR_EMAIL_NOT_VERIFIED) {
+		t.Fatalf("expected an unverified email of another user not to be merged, got %v", err)
+	}
+	existing.VerifiedAt = time.Now().Unix()
+	if err := checkEmailOwnership(existing, 2, "password123"); err != nil {
+		t.Fatalf("expected a verified email of another user to be accepted, got %v", err)
+	}
+}
+
+func TestUnlinkPlatform(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	alice := createTestUser(t, db, "alice")
+	email := createTestPlatform(t, db, alice, userplatform.PlatformEmail, "alice@example.com")
+	phone := createTestPlatform(t, db, alice, userplatform.PlatformPhone, "+8613800138000")
+	emailClaims := createTestPlatformSession(t, s, db, alice, email)
+	phoneClaims := createTestPlatformSession(t, s, db, alice, phone)
+
+	if err := s.unlinkPlatform(ctx, alice.ID, "unknown", ""); !errors.Is(err, apiv1.UserError_USER_ERROR_NOT_LINKED) {
+		t.Fatalf("expected an unknown platform to be rejected, got %v", err)
+	}
+	if err := s.unlinkPlatform(ctx, alice.ID, userplatform.PlatformGithub, ""); !errors.Is(err, apiv1.UserError_USER_ERROR_NOT_LINKED) {
+		t.Fatalf("expected a platform not linked to be rejected, got %v", err)
+	}
+	if err := s.unlinkPlatform(ctx, alice.ID, userplatform.PlatformPhone, ""); err != nil {
+		t.Fatalf("unlinkPlatform failed: %v", err)
+	}
+	if _, err := db.UserPlatform.Get(ctx, phone.ID); !ent.IsNotFound(err) {
+		t.Fatalf("expected the phone platform to be deleted, got %v", err)
+	}
+	if err := s.CheckAccessToken(ctx, phoneClaims); !errors.Is(err, apiv1.UserSessionError_USER_SESSION_ERROR_TOKEN_DENIED) {
+		t.Fatalf("expected the phone session to be denied, got %v", err)
+	}
+	if err := s.CheckAccessToken(ctx, emailClaims); err != nil {
+		t.Fatalf("expected the email session to be kept, got %v", err)
+	}
+	if err := s.unlinkPlatform(ctx, alice.ID, userplatform.PlatformEmail, ""); !errors.Is(err, apiv1.UserError_USER_ERROR_LAST_PLATFORM) {
+		t.Fatalf("expected the last platform to be kept, got %v", err)
+	}
+}
+
+// createTestWechatPlatform binds an app of the WeChat platform, an empty appID creates a platform of before app ids were recorded.
+func createTestWechatPlatform(t *testing.T, db *ent.Client, user *ent.User, platform userplatform.Platform, appID string, openID string) *ent.UserPlatform {
+	t.Helper()
+
+	created, err := db.UserPlatform.Create().
+		SetUserID(user.ID).
+		SetPlatform(platform).
+		SetPlatformID(openID).
+		SetSecondID("union-" + user.Username).
+		SetAppID(appID).
+		Save(context.Background())
+	if err != nil {
+		t.Fatalf("create wechat platform failed: %v", err)
+	}
+	return created
+}
+
+func newTestWechatApps() *wxapp.Apps {
+	return wxapp.NewApps(nil, wxapp.Config{Config: wechat.Config{AppID: "wx-main"}})
+}
+
+func TestListUserPlatformsWechatApps(t *testing.T) {
+	s, db := newTestService(t)
+	s.wechat = newTestWechatApps()
+	ctx := context.Background()
+	alice := createTestUser(t, db, "alice")
+	createTestPlatform(t, db, alice, userplatform.PlatformPhone, "+8613800138000")
+	createTestWechatPlatform(t, db, alice, userplatform.PlatformWechatMini, "wx-other", "openid-other")
+	createTestWechatPlatform(t, db, alice, userplatform.PlatformWechatMini, "", "openid-main")
+	createTestWechatPlatform(t, db, alice, userplatform.PlatformWechatOa, "wx-oa", "openid-oa")
+
+	res, err := s.listUserPlatforms(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("listUserPlatforms failed: %v", err)
+	}
+	if res.Phone != "+8613800138000" || res.WechatMini != "openid-main" {
+		t.Fatalf("expected the phone and the openid of the default mini program, got %+v", res)
+	}
+	want := []string{"wechat_mini:wx-other:openid-other", "wechat_mini:wx-main:openid-main", "wechat_oa:wx-oa:openid-oa"}
+	var got []string
+	for _, account := range res.WechatAccounts {
+		got = append(got, account.Platform+":"+account.AppId+":"+account.OpenId)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected every wechat app %v, got %v", want, got)
+	}
+}
+
+func TestUnlinkWechatApp(t *testing.T) {
+	s, db := newTestService(t)
+	s.wechat = newTestWechatApps()
+	ctx := context.Background()
+	alice := createTestUser(t, db, "alice")
+	mainApp := createTestWechatPlatform(t, db, alice, userplatform.PlatformWechatMini, "", "openid-main")
+	other := createTestWechatPlatform(t, db, alice, userplatform.PlatformWechatMini, "wx-other", "openid-other")
+	official := createTestWechatPlatform(t, db, alice, userplatform.PlatformWechatOa, "wx-oa", "openid-oa")
+	otherClaims := createTestPlatformSession(t, s, db, alice, other)
+	mainClaims := createTestPlatformSession(t, s, db, alice, mainApp)
+
+	if err := s.unlinkPlatform(ctx, alice.ID, userplatform.PlatformWechatMini, "wx-unknown"); !errors.Is(err, apiv1.UserError_USER_ERROR_NOT_LINKED) {
+		t.Fatalf("expected an app not linked to be rejected, got %v", err)
+	}
+	if err := s.unlinkPlatform(ctx, alice.ID, userplatform.PlatformWechatMini, "wx-other"); err != nil {
+		t.Fatalf("unlinkPlatform failed: %v", err)
+	}
+	if _, err := db.UserPlatform.Get(ctx, other.ID); !ent.IsNotFound(err) {
+		t.Fatalf("expected the other mini program to be unlinked, got %v", err)
+	}
+	if err := s.CheckAccessToken(ctx, otherClaims); !errors.Is(err, apiv1.UserSessionError_USER_SESSION_ERROR_TOKEN_DENIED) {
+		t.Fatalf("expected the session of the other mini program to be denied, got %v", err)
+	}
+	if err := s.CheckAccessToken(ctx, mainClaims); err != nil {
+		t.Fatalf("expected the session of the default mini program to be kept, got %v", err)
+	}
+
+	// 记录 AppID 之前的小程序平台属于默认小程序
+	if err := s.unlinkPlatform(ctx, alice.ID, userplatform.PlatformWechatMini, "wx-main"); err != nil {
+		t.Fatalf("unlinkPlatform failed: %v", err)
+	}
+	if _, err := db.UserPlatform.Get(ctx, mainApp.ID); !ent.IsNotFound(err) {
+		t.Fatalf("expected the default mini program to be unlinked, got %v", err)
+	}
+	if _, err := db.UserPlatform.Get(ctx, official.ID); err != nil {
+		t.Fatalf("expected the official account to be kept, got %v", err)
+	}
+	if err := s.unlinkPlatform(ctx, alice.ID, userplatform.PlatformWechatOa, ""); !errors.Is(err, apiv1.UserError_USER_ERROR_LAST_PLATFORM) {
+		t.Fatalf("expected the last platform to be kept, got %v", err)
+	}
+}
+
+func TestLinkWechatPlatformPerApp(t *testing.T) {
+	s, db := newTestService(t)
+	s.wechat = newTestWechatApps()
+	ctx := context.Background()
+	withAppID := func(appID string) func(*ent.UserPlatformCreate) *ent.UserPlatformCreate {
+		return func(create *ent.UserPlatformCreate) *ent.UserPlatformCreate {
+			return create.SetAppID(appID)
+		}
+	}
+	alice := createTestUser(t, db, "alice")
+	// 记录 AppID 之前绑定的默认小程序
+	createTestWechatPlatform(t, db, alice, userplatform.PlatformWechatMini, "", "openid-a")
+
+	_, err := s.linkPlatform(ctx, alice.ID, userplatform.PlatformWechatMini, "openid-b", false, withAppID("wx-main"))
+	if !errors.Is(err, apiv1.UserError_USER_ERROR_ALREADY_LINKED) {
+		t.Fatalf("expected a second openid of the same app to be rejected, got %v", err)
+	}
+	result, err := s.linkPlatform(ctx, alice.ID, userplatform.PlatformWechatMini, "openid-c", false, withAppID("wx-other"))
+	if err != nil || !result.Created {
+		t.Fatalf("expected an openid of another app to be linked, got %+v, %v", result, err)
+	}
+
+	bob := createTestUser(t, db, "bob")
+	createTestWechatPlatform(t, db, bob, userplatform.PlatformWechatMini, "wx-main", "openid-d")
+	createTestPlatform(t, db, bob, userplatform.PlatformPhone, "+8613800138000")
+	_, err = s.linkPlatform(ctx, alice.ID, userplatform.PlatformPhone, "+8613800138000", true, nil)
+	if !errors.Is(err, apiv1.UserError_USER_ERROR_MERGE_CONFLICT) {
+		t.Fatalf("expected merging an openid of the same app to conflict, got %v", err)
+	}
+
+	carol := createTestUser(t, db, "carol")
+	createTestWechatPlatform(t, db, carol, userplatform.PlatformWechatOa, "wx-oa", "openid-e")
+	createTestPlatform(t, db, carol, userplatform.PlatformPhone, "+8613900139000")
+	result, err = s.linkPlatform(ctx, alice.ID, userplatform.PlatformPhone, "+8613900139000", true, nil)
+	if err != nil || result.Merged != carol.ID {
+		t.Fatalf("expected carol to be merged, got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth/oauth"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere/log"
+	"github.com/go-sphere/sphere/utils/idgenerator"
+)
+
+const userNicknameMaxLength = 30
+
+// oauthPlatforms are the platforms an OAuth provider may be registered as, the provider name is the platform.
+var oauthPlatforms = []userplatform.Platform{
+	userplatform.PlatformGithub,
+	userplatform.PlatformGoogle,
+	userplatform.PlatformApple,
+}
+
+func oauthPlatform(name string) (userplatform.Platform, bool) {
+	for _, platform := range oauthPlatforms {
+		if string(platform) == name {
+			return platform, true
+		}
+	}
+	return "", false
+}
+
+func (s *Service) InitOAuth(registry *oauth.Registry) error {
+	for _, name := range registry.Names() {
+		if _, ok := oauthPlatform(name); !ok {
+			return fmt.Errorf("oauth provider %q is not a user platform", name)
+		}
+	}
+	s.oauth = registry
+	return nil
+}
+
+func (s *Service) ListOAuthProviders(ctx context.Context, request *apiv1.ListOAuthProvidersRequest) (*apiv1.ListOAuthProvidersResponse, error) {
+	providers := make([]string, 0)
+	if s.oauth != nil {
+		providers = s.oauth.Names()
+	}
+	return &apiv1.ListOAuthProvidersResponse{
+		Providers: providers,
+	}, nil
+}
+
+func (s *Service) AuthWithOAuth(ctx context.Context, request *apiv1.AuthWithOAuthRequest) (*apiv1.AuthWithOAuthResponse, error) {
+	if s.oauth == nil {
+		return nil, apiv1.AuthError_AUTH_ERROR_OAUTH_PROVIDER_NOT_FOUND
+	}
+	provider, ok := s.oauth.Get(request.Provider)
+	if !ok {
+		return nil, apiv1.AuthError_AUTH_ERROR_OAUTH_PROVIDER_NOT_FOUND
+	}
+	platform, ok := oauthPlatform(request.Provider)
+	if !ok {
+		return nil, apiv1.AuthError_AUTH_ERROR_OAUTH_PROVIDER_NOT_FOUND
+	}
+	identity, err := provider.Exchange(ctx, request.Code, request.CodeVerifier, request.Nonce)
+	if err != nil {
+		log.Warn("oauth exchange failed", log.Any("provider", request.Provider), log.Any("error", err.Error()))
+		return nil, apiv1.AuthError_AUTH_ERROR_OAUTH_FAILED
+	}
+	res, err := auth.Auth(
+		ctx, s.db, identity.Subject, platform,
+		auth.WithOnCreateUser(func(user *ent.UserCreate) *ent.UserCreate {
+			nickname := []rune(identity.Name)
+			if len(nickname) > userNicknameMaxLength {
+				nickname = nickname[:userNicknameMaxLength]
+			}
+			return user.
+				SetUsername(fmt.Sprintf("%s_%d", platform, idgenerator.NextId())).
+				SetNickname(string(nickname))
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.CheckUserStatus(ctx, res.User.ID); err != nil {
+		return nil, err
+	}
+	token, err := s.createUserToken(ctx, s.db.Client, res.User, auth.PlatformSubject(res.Platform), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.AuthWithOAuthResponse{
+		IsNew:        res.IsNew,
+		Token:        token.AccessToken,
+		User:         s.render.User(res.User),
+		RefreshToken: token.RefreshToken,
+		Expires:      token.Expires,
+	}, nil
+}
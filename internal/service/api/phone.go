@@ -0,0 +1,42 @@
+package api
+
+import (
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/phone"
+)
+
+const PhoneDefaultCountryCode = "86"
+
+// PhoneRegions limits phone login and binding to the allowed country calling codes.
+type PhoneRegions struct {
+	// DefaultCountryCode is used for numbers entered without an international prefix.
+	DefaultCountryCode string `json:"default_country_code" yaml:"default_country_code"`
+	// AllowedCountryCodes only allows the default country code when empty.
+	AllowedCountryCodes []string `json:"allowed_country_codes" yaml:"allowed_country_codes"`
+}
+
+func (s *Service) InitPhoneRegions(regions PhoneRegions) {
+	if regions.DefaultCountryCode == "" {
+		regions.DefaultCountryCode = PhoneDefaultCountryCode
+	}
+	if len(regions.AllowedCountryCodes) == 0 {
+		regions.AllowedCountryCodes = []string{regions.DefaultCountryCode}
+	}
+	s.phoneRegions = regions
+}
+
+// normalizePhone returns the number in E.164 format, which is stored as platform id of phone platforms.
+// countryCode is the calling code of national numbers, the default country code is used when empty.
+func (s *Service) normalizePhone(number string, countryCode string) (string, error) {
+	if countryCode == "" {
+		countryCode = s.phoneRegions.DefaultCountryCode
+	}
+	normalized, err := phone.Normalize(number, countryCode)
+	if err != nil {
+		return "", apiv1.AuthError_AUTH_ERROR_INVALID_PHONE
+	}
+	if !phone.InCountries(normalized, s.phoneRegions.AllowedCountryCodes) {
+		return "", apiv1.AuthError_AUTH_ERROR_UNSUPPORTED_PHONE_REGION
+	}
+	return normalized, nil
+}
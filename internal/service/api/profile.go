@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/service/shared"
+)
+
+// UserStorageDir is the storage directory of the files uploaded by app users.
+const UserStorageDir = "user"
+
+// isUserUploadKey reports whether the key was issued by UploadToken to the user,
+// the keys are stored in UserStorageDir and their names start with shared.UploadKeyPrefix.
+func isUserUploadKey(key string, uid int64) bool {
+	dir, name := path.Split(key)
+	if strings.Trim(dir, "/") != UserStorageDir {
+		return false
+	}
+	prefix := shared.UploadKeyPrefix(uid)
+	return strings.HasPrefix(name, prefix) && len(name) > len(prefix)
+}
+
+func (s *Service) UpdateProfile(ctx context.Context, request *apiv1.UpdateProfileRequest) (*apiv1.UpdateProfileResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	me, err := s.db.User.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	avatar := ""
+	if request.Avatar != "" {
+		avatar = s.storage.ExtractKeyFromURL(request.Avatar)
+		// 头像未变化时不校验, 合并或后台设置的头像可能不在用户目录下
+		if avatar != me.Avatar && !isUserUploadKey(avatar, uid) {
+			return nil, apiv1.UserError_USER_ERROR_INVALID_AVATAR
+		}
+	}
+	me, err = s.db.User.UpdateOne(me).
+		SetNickname(request.Nickname).
+		SetAvatar(avatar).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.UpdateProfileResponse{
+		User: s.render.User(me),
+	}, nil
+}
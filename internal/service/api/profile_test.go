@@ -0,0 +1,35 @@
+package api
+
+import (
+	"path"
+	"testing"
+
+	"github.com/go-sphere/sphere-layout/internal/service/shared"
+)
+
+func TestIsUserUploadKey(t *testing.T) {
+	own := path.Join(UserStorageDir, shared.UploadKeyBuilder(12)("avatar.png"))
+	other := path.Join(UserStorageDir, shared.UploadKeyBuilder(123)("avatar.png"))
+
+	tests := []struct {
+		name string
+		key  string
+		want bool
+	}{
+		{"own upload", own, true},
+		{"own upload with leading slash", "/" + own, true},
+		{"upload of user 123", other, false},
+		{"upload of user 1", path.Join(UserStorageDir, shared.UploadKeyBuilder(1)("avatar.png")), false},
+		{"other directory", path.Join("dash", path.Base(own)), false},
+		{"nested directory", path.Join(UserStorageDir, "nested", path.Base(own)), false},
+		{"prefix only", path.Join(UserStorageDir, shared.UploadKeyPrefix(12)), false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUserUploadKey(tt.key, 12); got != tt.want {
+				t.Errorf("isUserUploadKey(%q, 12) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type rateLimit struct {
+	key    string
+	limit  int
+	window time.Duration
+}
+
+type rateCounter struct {
+	Count   int   `json:"count"`
+	ResetAt int64 `json:"reset_at"`
+}
+
+// hitRateLimit counts a hit in the window of the key and reports whether the limit was already reached.
+// The counter is read and written under a lock, so parallel requests cannot share the same count.
+func (s *Service) hitRateLimit(ctx context.Context, limit rateLimit) (bool, error) {
+	s.rateLimitLock.Lock()
+	defer s.rateLimitLock.Unlock()
+	now := time.Now()
+	counter := rateCounter{ResetAt: now.Add(limit.window).Unix()}
+	raw, found, err := s.cache.Get(ctx, limit.key)
+	if err != nil {
+		return false, err
+	}
+	if found {
+		var saved rateCounter
+		if json.Unmarshal(raw, &saved) == nil && saved.ResetAt > now.Unix() {
+			counter = saved
+		}
+	}
+	if counter.Count >= limit.limit {
+		return true, nil
+	}
+	counter.Count++
+	raw, err = json.Marshal(counter)
+	if err != nil {
+		return false, err
+	}
+	return false, s.cache.SetWithTTL(ctx, limit.key, raw, time.Until(time.Unix(counter.ResetAt, 0)))
+}
+
+// checkRateLimits counts a hit for every limit and returns limitedErr once any of them is reached.
+func (s *Service) checkRateLimits(ctx context.Context, limitedErr error, limits ...rateLimit) error {
+	for _, limit := range limits {
+		limited, err := s.hitRateLimit(ctx, limit)
+		if err != nil {
+			return err
+		}
+		if limited {
+			return limitedErr
+		}
+	}
+	return nil
+}
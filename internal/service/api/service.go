@@ -1,13 +1,18 @@
 package api
 
 import (
+	"sync"
+	"time"
+
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth/oauth"
 	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
 	"github.com/go-sphere/sphere-layout/internal/pkg/render"
+	"github.com/go-sphere/sphere-layout/internal/pkg/security"
+	"github.com/go-sphere/sphere-layout/internal/pkg/wxapp"
 	"github.com/go-sphere/sphere/cache"
 	"github.com/go-sphere/sphere/server/auth/authorizer"
 	"github.com/go-sphere/sphere/server/auth/jwtauth"
 	"github.com/go-sphere/sphere/storage"
-	"github.com/go-sphere/weixin-mp-api/wechat"
 )
 
 type TokenAuthorizer = authorizer.TokenAuthorizer[int64, jwtauth.RBACClaims[int64]]
@@ -16,24 +21,47 @@ type Service struct {
 	authorizer.ContextUtils[int64]
 
 	db     *dao.Dao
-	wechat *wechat.Wechat
+	wechat *wxapp.Apps
 	render *render.Render
 
-	cache      cache.ByteCache
-	storage    storage.CDNStorage
-	authorizer TokenAuthorizer
+	cache         cache.ByteCache
+	storage       storage.CDNStorage
+	rateLimitLock sync.Mutex
+
+	authorizer    TokenAuthorizer
+	authRefresher TokenAuthorizer
+
+	sms     SmsSender
+	smsLock sync.Mutex
+
+	email          EmailSender
+	emailLinks     EmailLinks
+	passwordPolicy security.PasswordPolicy
+
+	oauth *oauth.Registry
+
+	phoneRegions PhoneRegions
+
+	deletionGracePeriod time.Duration
 }
 
-func NewService(db *dao.Dao, wechat *wechat.Wechat, cache cache.ByteCache, store storage.CDNStorage) *Service {
+func NewService(db *dao.Dao, wechat *wxapp.Apps, cache cache.ByteCache, store storage.CDNStorage) *Service {
 	return &Service{
 		db:      db,
 		wechat:  wechat,
 		cache:   cache,
 		render:  render.NewRender(db, store, true),
 		storage: store,
+
+		phoneRegions: PhoneRegions{
+			DefaultCountryCode:  PhoneDefaultCountryCode,
+			AllowedCountryCodes: []string{PhoneDefaultCountryCode},
+		},
+		deletionGracePeriod: AccountDeletionDefaultGracePeriod,
 	}
 }
 
-func (s *Service) Init(authorizer TokenAuthorizer) {
+func (s *Service) Init(authorizer TokenAuthorizer, authRefresher TokenAuthorizer) {
 	s.authorizer = authorizer
+	s.authRefresher = authRefresher
 }
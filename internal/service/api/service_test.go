@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/client"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere/cache/memory"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
+	"github.com/go-sphere/sphere/storage"
+)
+
+const (
+	testJWT        = "test-api-jwt-secret"
+	testRefreshJWT = "test-api-refresh-jwt-secret"
+)
+
+// newTestService creates a service backed by an in-memory database.
+// Further services sharing the database can be created with newTestServiceWithDB.
+func newTestService(t *testing.T) (*Service, *ent.Client) {
+	t.Helper()
+
+	conf := client.Config{
+		Type: "sqlite3",
+		Path: fmt.Sprintf("file:api-service-test-%d?mode=memory&cache=shared", time.Now().UnixNano()),
+	}
+	db, err := client.NewDataBaseClient(conf)
+	if err != nil {
+		t.Fatalf("create test database failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return newTestServiceWithDB(t, db), db
+}
+
+func newTestServiceWithDB(t *testing.T, db *ent.Client) *Service {
+	t.Helper()
+
+	s := NewService(dao.NewDao(db), nil, memory.NewByteCache(), &testStorage{})
+	s.Init(
+		jwtauth.NewJwtAuth[jwtauth.RBACClaims[int64]](testJWT),
+		jwtauth.NewJwtAuth[jwtauth.RBACClaims[int64]](testRefreshJWT),
+	)
+	s.InitSms(&testSmsSender{})
+	return s
+}
+
+func createTestUser(t *testing.T, db *ent.Client, username string) *ent.User {
+	t.Helper()
+
+	user, err := db.User.Create().SetUsername(username).Save(context.Background())
+	if err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	return user
+}
+
+// testSmsSender remembers the codes instead of sending them.
+type testSmsSender struct {
+	mu    sync.Mutex
+	codes map[string]string
+	sent  int
+}
+
+func (s *testSmsSender) SendCode(_ context.Context, phone string, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.codes == nil {
+		s.codes = make(map[string]string)
+	}
+	s.codes[phone] = code
+	s.sent++
+	return nil
+}
+
+func (s *testSmsSender) lastCode(phone string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.codes[phone]
+}
+
+func (s *testSmsSender) sentCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sent
+}
+
+// testStorage accepts every operation and records the deleted keys.
+type testStorage struct {
+	mu      sync.Mutex
+	deleted []string
+}
+
+func (n *testStorage) GenerateURL(key string, _ ...url.Values) string { return key }
+
+func (n *testStorage) GenerateURLs(keys []string, _ ...url.Values) []string { return keys }
+
+func (n *testStorage) ExtractKeyFromURL(uri string) string { return uri }
+
+func (n *testStorage) ExtractKeyFromURLWithMode(uri string, _ bool) (string, error) { return uri, nil }
+
+func (n *testStorage) GenerateUploadAuth(_ context.Context, req storage.UploadAuthRequest) (storage.UploadAuthResult, error) {
+	return storage.UploadAuthResult{
+		Authorization: storage.UploadAuthorization{
+			Type:   storage.UploadAuthorizationTypeToken,
+			Value:  "test-upload-token",
+			Method: http.MethodPost,
+		},
+		File: storage.UploadFileInfo{
+			Key: req.FileName,
+			URL: req.FileName,
+		},
+	}, nil
+}
+
+func (n *testStorage) UploadFile(_ context.Context, _ io.Reader, key string) (string, error) {
+	return key, nil
+}
+
+func (n *testStorage) UploadLocalFile(_ context.Context, _ string, key string) (string, error) {
+	return key, nil
+}
+
+func (n *testStorage) IsFileExists(_ context.Context, _ string) (bool, error) { return false, nil }
+
+func (n *testStorage) DownloadFile(_ context.Context, _ string) (storage.DownloadResult, error) {
+	return storage.DownloadResult{}, errors.New("not implemented")
+}
+
+func (n *testStorage) DeleteFile(_ context.Context, key string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.deleted = append(n.deleted, key)
+	return nil
+}
+
+func (n *testStorage) MoveFile(_ context.Context, _, _ string, _ bool) error { return nil }
+
+func (n *testStorage) CopyFile(_ context.Context, _, _ string, _ bool) error { return nil }
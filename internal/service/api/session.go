@@ -0,0 +1,318 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/usersession"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
+	"github.com/go-sphere/sphere/utils/idgenerator"
+	"github.com/google/uuid"
+)
+
+var _ apiv1.UserSessionServiceHTTPServer = (*Service)(nil)
+
+const (
+	AuthContextKeyIP     = "auth_ip"
+	AuthContextKeyUA     = "auth_ua"
+	AuthContextKeyClaims = "auth_claims"
+)
+
+type UserToken struct {
+	User         *ent.User
+	AccessToken  string
+	RefreshToken string
+	Expires      int64
+}
+
+const (
+	UserSessionStatusCacheDuration = time.Minute
+
+	userSessionActive  = "active"
+	userSessionRevoked = "revoked"
+)
+
+func userSessionStatusKey(id int64) string {
+	return "user_session_status:" + strconv.FormatInt(id, 10)
+}
+
+// userSessionFamilyID returns the family of a session, the first session of a login forms the family.
+func userSessionFamilyID(session *ent.UserSession) int64 {
+	if session.FamilyID != 0 {
+		return session.FamilyID
+	}
+	return session.ID
+}
+
+// createUserToken issues a new access/refresh token pair. When parent is not nil the new session
+// replaces it and joins the same session family, which allows detecting refresh token reuse.
+func (s *Service) createUserToken(ctx context.Context, client *ent.Client, user *ent.User, subject string, parent *ent.UserSession) (*UserToken, error) {
+	newUUID, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	sessionID := idgenerator.NextId()
+	familyID := sessionID
+	if parent != nil {
+		familyID = userSessionFamilyID(parent)
+	}
+	sessionExpires := time.Now().Add(auth.AppRefreshTokenValidDuration)
+	create := client.UserSession.Create().
+		SetID(sessionID).
+		SetFamilyID(familyID).
+		SetUID(user.ID).
+		SetSubject(subject).
+		SetSessionKey(newUUID.String()).
+		SetExpires(sessionExpires.Unix())
+	if parent != nil {
+		create = create.SetParentID(parent.ID)
+	}
+	if ip, ok := ctx.Value(AuthContextKeyIP).(string); ok {
+		create = create.SetIPAddress(ip)
+	}
+	if ua, ok := ctx.Value(AuthContextKeyUA).(string); ok {
+		create = create.SetDeviceInfo(ua)
+	}
+	session, err := create.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := auth.RenderClaims(user, subject, session.ID, auth.AppTokenValidDuration)
+	token, err := s.authorizer.GenerateToken(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	refreshClaims := jwtauth.NewRBACClaims(session.ID, session.SessionKey, nil, sessionExpires)
+	refresh, err := s.authRefresher.GenerateToken(ctx, refreshClaims)
+	if err != nil {
+		return nil, err
+	}
+	return &UserToken{
+		User:         user,
+		AccessToken:  token,
+		RefreshToken: refresh,
+		Expires:      claims.ExpiresAt.Unix(),
+	}, nil
+}
+
+// loadRefreshSession parses the refresh token and returns the session it belongs to.
+func (s *Service) loadRefreshSession(ctx context.Context, client *ent.Client, refreshToken string) (*ent.UserSession, error) {
+	claims, err := s.authRefresher.ParseToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	session, err := client.UserSession.Get(ctx, claims.UID)
+	if err != nil {
+		return nil, err
+	}
+	if session.SessionKey != claims.Subject {
+		return nil, apiv1.UserSessionError_USER_SESSION_ERROR_KEY_NOT_MATCH
+	}
+	return session, nil
+}
+
+// rotateUserSession revokes the session a refresh token belongs to. It reports false when the session was
+// revoked in the meantime, e.g. by a concurrent refresh with the same token.
+func rotateUserSession(ctx context.Context, client *ent.Client, session *ent.UserSession) (bool, error) {
+	count, err := client.UserSession.Update().
+		Where(usersession.IDEQ(session.ID), usersession.IsRevokedEQ(false)).
+		SetIsRevoked(true).
+		Save(ctx)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *Service) RefreshToken(ctx context.Context, request *apiv1.RefreshTokenRequest) (*apiv1.RefreshTokenResponse, error) {
+	session, err := s.loadRefreshSession(ctx, s.db.Client, request.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.CheckUserStatus(ctx, session.UID); err != nil {
+		return nil, err
+	}
+	var reused *ent.UserSession
+	token, err := dao.WithTx[UserToken](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*UserToken, error) {
+		session, err := s.loadRefreshSession(ctx, client, request.RefreshToken)
+		if err != nil {
+			return nil, err
+		}
+		if session.IsRevoked {
+			// 已轮换过的刷新令牌再次出现, 说明令牌可能已泄露
+			rotated, qErr := client.UserSession.Query().Where(usersession.ParentIDEQ(session.ID)).Exist(ctx)
+			if qErr != nil {
+				return nil, qErr
+			}
+			if rotated {
+				reused = session
+				return nil, apiv1.UserSessionError_USER_SESSION_ERROR_REUSED
+			}
+			return nil, apiv1.UserSessionError_USER_SESSION_ERROR_REVOKED
+		}
+		if session.Expires < time.Now().Unix() {
+			return nil, apiv1.UserSessionError_USER_SESSION_ERROR_EXPIRED.Join(
+				client.UserSession.UpdateOneID(session.ID).SetIsRevoked(true).Exec(ctx),
+			)
+		}
+		user, err := client.User.Get(ctx, session.UID)
+		if err != nil {
+			return nil, err
+		}
+		rotated, err := rotateUserSession(ctx, client, session)
+		if err != nil {
+			return nil, err
+		}
+		if !rotated {
+			// 并发请求已使用同一刷新令牌完成轮换
+			reused = session
+			return nil, apiv1.UserSessionError_USER_SESSION_ERROR_REUSED
+		}
+		return s.createUserToken(ctx, client, user, session.Subject, session)
+	})
+	if err != nil {
+		if reused != nil {
+			// 在事务外撤销, 避免随事务回滚
+			return nil, apiv1.UserSessionError_USER_SESSION_ERROR_REUSED.Join(s.revokeUserSessionFamily(ctx, reused))
+		}
+		return nil, err
+	}
+	return &apiv1.RefreshTokenResponse{
+		Token:        token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Expires:      token.Expires,
+	}, nil
+}
+
+// revokeUserSessions revokes the sessions and denies the access tokens issued for them.
+// The database stays authoritative, the cache entries only make the revocation apply at once on this instance.
+func (s *Service) revokeUserSessions(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := s.db.UserSession.Update().
+		Where(usersession.IDIn(ids...)).
+		SetIsRevoked(true).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err = s.cache.SetWithTTL(ctx, userSessionStatusKey(id), []byte(userSessionRevoked), auth.AppTokenValidDuration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isUserSessionRevoked reports whether the session an access token belongs to has been revoked.
+// The revoked flag of the session is read from the database, so a revocation survives restarts and
+// applies to every instance. The result is cached briefly since it is checked on every request.
+// A session rotated by RefreshToken counts as revoked too, the client moves on to the new access token.
+func (s *Service) isUserSessionRevoked(ctx context.Context, id int64) (bool, error) {
+	raw, found, err := s.cache.Get(ctx, userSessionStatusKey(id))
+	if err != nil {
+		return false, err
+	}
+	if found {
+		return string(raw) == userSessionRevoked, nil
+	}
+	session, err := s.db.UserSession.Get(ctx, id)
+	if err != nil && !ent.IsNotFound(err) {
+		return false, err
+	}
+	if session == nil || session.IsRevoked {
+		_ = s.cache.SetWithTTL(ctx, userSessionStatusKey(id), []byte(userSessionRevoked), auth.AppTokenValidDuration)
+		return true, nil
+	}
+	_ = s.cache.SetWithTTL(ctx, userSessionStatusKey(id), []byte(userSessionActive), UserSessionStatusCacheDuration)
+	return false, nil
+}
+
+// revokeUserSessionFamily revokes every session rotated from the same login after a refresh token was reused.
+func (s *Service) revokeUserSessionFamily(ctx context.Context, session *ent.UserSession) error {
+	familyID := userSessionFamilyID(session)
+	ids, err := s.db.UserSession.Query().
+		Where(usersession.Or(usersession.FamilyIDEQ(familyID), usersession.IDEQ(familyID))).
+		IDs(ctx)
+	if err != nil {
+		return err
+	}
+	return s.revokeUserSessions(ctx, ids...)
+}
+
+// CheckAccessToken rejects access tokens of revoked sessions and of users who may no longer use the app.
+// Impersonation tokens are let through so that support can still look into such accounts.
+func (s *Service) CheckAccessToken(ctx context.Context, claims *jwtauth.RBACClaims[int64]) error {
+	if _, ok := auth.Impersonator(claims); ok {
+		return nil
+	}
+	if sessionID, ok := auth.SessionID(claims); ok {
+		revoked, err := s.isUserSessionRevoked(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return apiv1.UserSessionError_USER_SESSION_ERROR_TOKEN_DENIED
+		}
+	}
+	return s.CheckUserStatus(ctx, claims.UID)
+}
+
+func (s *Service) ListUserSessions(ctx context.Context, request *apiv1.ListUserSessionsRequest) (*apiv1.ListUserSessionsResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	all, err := s.db.UserSession.Query().
+		Where(
+			usersession.UIDEQ(uid),
+			usersession.IsRevokedEQ(false),
+			usersession.ExpiresGT(time.Now().Unix()),
+		).
+		Order(usersession.ByID()).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims, _ := ctx.Value(AuthContextKeyClaims).(*jwtauth.RBACClaims[int64])
+	current, _ := auth.SessionID(claims)
+	sessions := make([]*apiv1.UserSession, 0, len(all))
+	for _, session := range all {
+		sessions = append(sessions, &apiv1.UserSession{
+			Id:         session.ID,
+			DeviceInfo: session.DeviceInfo,
+			IpAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			Expires:    session.Expires,
+			Current:    session.ID == current,
+		})
+	}
+	return &apiv1.ListUserSessionsResponse{
+		Sessions: sessions,
+	}, nil
+}
+
+func (s *Service) RevokeUserSession(ctx context.Context, request *apiv1.RevokeUserSessionRequest) (*apiv1.RevokeUserSessionResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.db.UserSession.Query().
+		Where(usersession.IDEQ(request.Id), usersession.UIDEQ(uid)).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 撤销设备时一并撤销该设备轮换前的会话, 它们签发的访问令牌可能仍在有效期内
+	if err = s.revokeUserSessionFamily(ctx, session); err != nil {
+		return nil, err
+	}
+	return &apiv1.RevokeUserSessionResponse{}, nil
+}
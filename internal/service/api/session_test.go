@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/usersession"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	user := createTestUser(t, db, "alice")
+
+	first, err := s.createUserToken(ctx, db, user, "test:alice", nil)
+	if err != nil {
+		t.Fatalf("createUserToken failed: %v", err)
+	}
+	claims, err := s.authorizer.ParseToken(ctx, first.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+	if err = s.CheckAccessToken(ctx, claims); err != nil {
+		t.Fatalf("expected fresh access token to be accepted, got %v", err)
+	}
+
+	second, err := s.RefreshToken(ctx, &apiv1.RefreshTokenRequest{RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatalf("RefreshToken failed: %v", err)
+	}
+	secondClaims, err := s.authorizer.ParseToken(ctx, second.Token)
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+	if err = s.CheckAccessToken(ctx, secondClaims); err != nil {
+		t.Fatalf("expected rotated access token to be accepted, got %v", err)
+	}
+
+	_, err = s.RefreshToken(ctx, &apiv1.RefreshTokenRequest{RefreshToken: first.RefreshToken})
+	if !errors.Is(err, apiv1.UserSessionError_USER_SESSION_ERROR_REUSED) {
+		t.Fatalf("expected refresh token reuse to be detected, got %v", err)
+	}
+	if err = s.CheckAccessToken(ctx, secondClaims); !errors.Is(err, apiv1.UserSessionError_USER_SESSION_ERROR_TOKEN_DENIED) {
+		t.Fatalf("expected the whole session family to be denied, got %v", err)
+	}
+	if _, err = s.RefreshToken(ctx, &apiv1.RefreshTokenRequest{RefreshToken: second.RefreshToken}); err == nil {
+		t.Fatal("expected refresh token of a revoked family to be rejected")
+	}
+}
+
+func TestRevokedSessionSurvivesRestart(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	user := createTestUser(t, db, "bob")
+
+	token, err := s.createUserToken(ctx, db, user, "test:bob", nil)
+	if err != nil {
+		t.Fatalf("createUserToken failed: %v", err)
+	}
+	claims, err := s.authorizer.ParseToken(ctx, token.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+
+	if err = s.CheckAccessToken(ctx, claims); err != nil {
+		t.Fatalf("expected access token to be accepted, got %v", err)
+	}
+
+	session, err := db.UserSession.Query().Only(ctx)
+	if err != nil {
+		t.Fatalf("query session failed: %v", err)
+	}
+	if err = s.revokeUserSessions(ctx, session.ID); err != nil {
+		t.Fatalf("revokeUserSessions failed: %v", err)
+	}
+	if err = s.CheckAccessToken(ctx, claims); !errors.Is(err, apiv1.UserSessionError_USER_SESSION_ERROR_TOKEN_DENIED) {
+		t.Fatalf("expected revoked session to be denied, got %v", err)
+	}
+
+	// 重启后内存缓存为空, 撤销状态从数据库读取
+	restarted := newTestServiceWithDB(t, db)
+	if err = restarted.CheckAccessToken(ctx, claims); !errors.Is(err, apiv1.UserSessionError_USER_SESSION_ERROR_TOKEN_DENIED) {
+		t.Fatalf("expected revoked session to be denied after a restart, got %v", err)
+	}
+}
+
+func TestRotateUserSession(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	user := createTestUser(t, db, "carol")
+
+	token, err := s.createUserToken(ctx, db, user, "test:carol", nil)
+	if err != nil {
+		t.Fatalf("createUserToken failed: %v", err)
+	}
+	session, err := s.loadRefreshSession(ctx, db, token.RefreshToken)
+	if err != nil {
+		t.Fatalf("loadRefreshSession failed: %v", err)
+	}
+	rotated, err := rotateUserSession(ctx, db, session)
+	if err != nil || !rotated {
+		t.Fatalf("expected the session to be rotated, got %v, %v", rotated, err)
+	}
+	// session 仍是轮换前读取的快照, 模拟并发请求在检查之后才轮换
+	rotated, err = rotateUserSession(ctx, db, session)
+	if err != nil || rotated {
+		t.Fatalf("expected a stale session not to be rotated again, got %v, %v", rotated, err)
+	}
+}
+
+func TestRefreshTokenConcurrent(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	user := createTestUser(t, db, "dave")
+
+	token, err := s.createUserToken(ctx, db, user, "test:dave", nil)
+	if err != nil {
+		t.Fatalf("createUserToken failed: %v", err)
+	}
+	session, err := s.loadRefreshSession(ctx, db, token.RefreshToken)
+	if err != nil {
+		t.Fatalf("loadRefreshSession failed: %v", err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, 2)
+	)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = s.RefreshToken(ctx, &apiv1.RefreshTokenRequest{RefreshToken: token.RefreshToken})
+		}()
+	}
+	wg.Wait()
+
+	// SQLite 可能让并发事务直接失败, 无论哪种结果都不能出现两个有效的子会话
+	var succeeded, reused int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, apiv1.UserSessionError_USER_SESSION_ERROR_REUSED):
+			reused++
+		}
+	}
+	if succeeded > 1 {
+		t.Fatal("expected at most one refresh with the same token to succeed")
+	}
+	active := db.UserSession.Query().
+		Where(usersession.ParentIDEQ(session.ID), usersession.IsRevokedEQ(false)).
+		CountX(ctx)
+	if active > 1 {
+		t.Fatalf("expected at most one active child session, got %d", active)
+	}
+	if reused > 0 && active != 0 {
+		t.Fatal("expected the detected reuse to revoke the rotated session")
+	}
+	if succeeded == 0 {
+		return
+	}
+
+	// 轮换后再次使用同一令牌视为重用
+	_, err = s.RefreshToken(ctx, &apiv1.RefreshTokenRequest{RefreshToken: token.RefreshToken})
+	if !errors.Is(err, apiv1.UserSessionError_USER_SESSION_ERROR_REUSED) {
+		t.Fatalf("expected the refresh token to be reused, got %v", err)
+	}
+}
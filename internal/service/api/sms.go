@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/pkg/sms"
+	"github.com/go-sphere/sphere/utils/idgenerator"
+)
+
+const (
+	SmsCodeLength        = 6
+	SmsCodeValidDuration = time.Minute * 5
+	SmsCodeMaxAttempts   = 5
+
+	SmsPhoneSendInterval = time.Minute
+	SmsPhoneDailyLimit   = 10
+	SmsIPHourlyLimit     = 20
+)
+
+// SmsSender delivers verification codes, the provider is chosen by the integrator.
+type SmsSender interface {
+	SendCode(ctx context.Context, phone string, code string) error
+}
+
+type smsCode struct {
+	Code     string `json:"code"`
+	Attempts int    `json:"attempts"`
+	Expires  int64  `json:"expires"`
+}
+
+func smsCodeKey(phone string) string {
+	return "sms_code:" + phone
+}
+
+func (s *Service) InitSms(sender SmsSender) {
+	s.sms = sender
+}
+
+func (s *Service) checkSmsThrottle(ctx context.Context, phone string) error {
+	limits := []rateLimit{
+		{"sms_interval:phone:" + phone, 1, SmsPhoneSendInterval},
+		{"sms_daily:phone:" + phone, SmsPhoneDailyLimit, time.Hour * 24},
+	}
+	if ip, ok := ctx.Value(AuthContextKeyIP).(string); ok && ip != "" {
+		limits = append(limits, rateLimit{"sms_hourly:ip:" + ip, SmsIPHourlyLimit, time.Hour})
+	}
+	return s.checkRateLimits(ctx, apiv1.AuthError_AUTH_ERROR_SMS_TOO_FREQUENT, limits...)
+}
+
+func (s *Service) SendSmsCode(ctx context.Context, request *apiv1.SendSmsCodeRequest) (*apiv1.SendSmsCodeResponse, error) {
+	if s.sms == nil {
+		return nil, apiv1.AuthError_AUTH_ERROR_SMS_DISABLED
+	}
+	phone, err := s.normalizePhone(request.Phone, "")
+	if err != nil {
+		return nil, err
+	}
+	if err = s.checkSmsThrottle(ctx, phone); err != nil {
+		return nil, err
+	}
+	code, err := sms.NewCode(SmsCodeLength)
+	if err != nil {
+		return nil, err
+	}
+	err = s.saveSmsCode(ctx, phone, &smsCode{
+		Code:    code,
+		Expires: time.Now().Add(SmsCodeValidDuration).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = s.sms.SendCode(ctx, phone, code); err != nil {
+		return nil, err
+	}
+	return &apiv1.SendSmsCodeResponse{
+		RetryAfter: int64(SmsPhoneSendInterval.Seconds()),
+	}, nil
+}
+
+func (s *Service) saveSmsCode(ctx context.Context, phone string, code *smsCode) error {
+	ttl := time.Until(time.Unix(code.Expires, 0))
+	if ttl <= 0 {
+		return apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID
+	}
+	raw, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+	return s.cache.SetWithTTL(ctx, smsCodeKey(phone), raw, ttl)
+}
+
+func (s *Service) loadSmsCode(ctx context.Context, phone string) (*smsCode, error) {
+	raw, found, err := s.cache.Get(ctx, smsCodeKey(phone))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID
+	}
+	var saved smsCode
+	if err = json.Unmarshal(raw, &saved); err != nil {
+		return nil, err
+	}
+	if saved.Expires < time.Now().Unix() {
+		return nil, apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID
+	}
+	return &saved, nil
+}
+
+// reserveSmsAttempt counts an attempt before the code is compared, so parallel guesses
+// cannot share the same attempt. The code is dropped once the attempts are exhausted.
+func (s *Service) reserveSmsAttempt(ctx context.Context, phone string) (*smsCode, error) {
+	s.smsLock.Lock()
+	defer s.smsLock.Unlock()
+	saved, err := s.loadSmsCode(ctx, phone)
+	if err != nil {
+		return nil, err
+	}
+	if saved.Attempts >= SmsCodeMaxAttempts {
+		return nil, apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID.Join(s.cache.Del(ctx, smsCodeKey(phone)))
+	}
+	saved.Attempts++
+	if err = s.saveSmsCode(ctx, phone, saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// consumeSmsCode drops the code unless a parallel request has consumed or replaced it already.
+func (s *Service) consumeSmsCode(ctx context.Context, phone string, code string) error {
+	s.smsLock.Lock()
+	defer s.smsLock.Unlock()
+	saved, err := s.loadSmsCode(ctx, phone)
+	if err != nil {
+		return err
+	}
+	if saved.Code != code {
+		return apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID
+	}
+	return s.cache.Del(ctx, smsCodeKey(phone))
+}
+
+// verifySmsCode consumes the code on success and drops it once the attempts are exhausted.
+func (s *Service) verifySmsCode(ctx context.Context, phone string, code string) error {
+	saved, err := s.reserveSmsAttempt(ctx, phone)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(saved.Code), []byte(code)) == 1 {
+		return s.consumeSmsCode(ctx, phone, saved.Code)
+	}
+	return apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID
+}
+
+func (s *Service) AuthWithPhone(ctx context.Context, request *apiv1.AuthWithPhoneRequest) (*apiv1.AuthWithPhoneResponse, error) {
+	if s.sms == nil {
+		return nil, apiv1.AuthError_AUTH_ERROR_SMS_DISABLED
+	}
+	phone, err := s.normalizePhone(request.Phone, "")
+	if err != nil {
+		return nil, err
+	}
+	if err = s.verifySmsCode(ctx, phone, request.Code); err != nil {
+		return nil, err
+	}
+	res, err := auth.Auth(
+		ctx, s.db, phone, userplatform.PlatformPhone,
+		auth.WithOnCreateUser(func(user *ent.UserCreate) *ent.UserCreate {
+			return user.SetUsername(fmt.Sprintf("phone_%d", idgenerator.NextId()))
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.CheckUserStatus(ctx, res.User.ID); err != nil {
+		return nil, err
+	}
+	token, err := s.createUserToken(ctx, s.db.Client, res.User, auth.PlatformSubject(res.Platform), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.AuthWithPhoneResponse{
+		IsNew:        res.IsNew,
+		Token:        token.AccessToken,
+		User:         s.render.User(res.User),
+		RefreshToken: token.RefreshToken,
+		Expires:      token.Expires,
+	}, nil
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+)
+
+func TestSmsDisabledWithoutSender(t *testing.T) {
+	s, _ := newTestService(t)
+	s.InitSms(nil)
+	ctx := context.Background()
+
+	_, err := s.SendSmsCode(ctx, &apiv1.SendSmsCodeRequest{Phone: "13800138000"})
+	if !errors.Is(err, apiv1.AuthError_AUTH_ERROR_SMS_DISABLED) {
+		t.Fatalf("expected sms to be disabled, got %v", err)
+	}
+	_, err = s.AuthWithPhone(ctx, &apiv1.AuthWithPhoneRequest{Phone: "13800138000", Code: "123456"})
+	if !errors.Is(err, apiv1.AuthError_AUTH_ERROR_SMS_DISABLED) {
+		t.Fatalf("expected sms to be disabled, got %v", err)
+	}
+}
+
+func TestAuthWithPhone(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	sender := s.sms.(*testSmsSender)
+	phone, err := s.normalizePhone("13800138000", "")
+	if err != nil {
+		t.Fatalf("normalizePhone failed: %v", err)
+	}
+
+	if _, err = s.SendSmsCode(ctx, &apiv1.SendSmsCodeRequest{Phone: "13800138000"}); err != nil {
+		t.Fatalf("SendSmsCode failed: %v", err)
+	}
+	code := sender.lastCode(phone)
+	if len(code) != SmsCodeLength {
+		t.Fatalf("expected a code of %d digits, got %q", SmsCodeLength, code)
+	}
+
+	_, err = s.AuthWithPhone(ctx, &apiv1.AuthWithPhoneRequest{Phone: "13800138000", Code: wrongCode(code)})
+	if !errors.Is(err, apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID) {
+		t.Fatalf("expected wrong code to be rejected, got %v", err)
+	}
+	first, err := s.AuthWithPhone(ctx, &apiv1.AuthWithPhoneRequest{Phone: "13800138000", Code: code})
+	if err != nil {
+		t.Fatalf("AuthWithPhone failed: %v", err)
+	}
+	if !first.IsNew || first.Token == "" || first.RefreshToken == "" {
+		t.Fatalf("expected a new user with tokens, got %+v", first)
+	}
+	_, err = s.AuthWithPhone(ctx, &apiv1.AuthWithPhoneRequest{Phone: "13800138000", Code: code})
+	if !errors.Is(err, apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID) {
+		t.Fatalf("expected used code to be rejected, got %v", err)
+	}
+
+	// 使用新的缓存跳过发送间隔, 同一号码再次登录时返回已有用户
+	other := newTestServiceWithDB(t, db)
+	if _, err = other.SendSmsCode(ctx, &apiv1.SendSmsCodeRequest{Phone: "+86 138 0013 8000"}); err != nil {
+		t.Fatalf("SendSmsCode failed: %v", err)
+	}
+	second, err := other.AuthWithPhone(ctx, &apiv1.AuthWithPhoneRequest{
+		Phone: "+8613800138000",
+		Code:  other.sms.(*testSmsSender).lastCode(phone),
+	})
+	if err != nil {
+		t.Fatalf("AuthWithPhone failed: %v", err)
+	}
+	if second.IsNew || second.User.Id != first.User.Id {
+		t.Fatalf("expected user %d to log in again, got %+v", first.User.Id, second)
+	}
+}
+
+func TestSmsCodeAttemptsExhausted(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	phone, err := s.normalizePhone("13800138000", "")
+	if err != nil {
+		t.Fatalf("normalizePhone failed: %v", err)
+	}
+
+	if _, err = s.SendSmsCode(ctx, &apiv1.SendSmsCodeRequest{Phone: phone}); err != nil {
+		t.Fatalf("SendSmsCode failed: %v", err)
+	}
+	code := s.sms.(*testSmsSender).lastCode(phone)
+	for i := 0; i < SmsCodeMaxAttempts; i++ {
+		_, err = s.AuthWithPhone(ctx, &apiv1.AuthWithPhoneRequest{Phone: phone, Code: wrongCode(code)})
+		if !errors.Is(err, apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID) {
+			t.Fatalf("attempt %d: expected wrong code to be rejected, got %v", i+1, err)
+		}
+	}
+	_, err = s.AuthWithPhone(ctx, &apiv1.AuthWithPhoneRequest{Phone: phone, Code: code})
+	if !errors.Is(err, apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID) {
+		t.Fatalf("expected code to be dropped after %d attempts, got %v", SmsCodeMaxAttempts, err)
+	}
+}
+
+func TestSmsCodeAttemptsConcurrent(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	phone, err := s.normalizePhone("13800138000", "")
+	if err != nil {
+		t.Fatalf("normalizePhone failed: %v", err)
+	}
+
+	if _, err = s.SendSmsCode(ctx, &apiv1.SendSmsCodeRequest{Phone: phone}); err != nil {
+		t.Fatalf("SendSmsCode failed: %v", err)
+	}
+	code := s.sms.(*testSmsSender).lastCode(phone)
+
+	// 并行猜测不能共享同一次尝试
+	var wg sync.WaitGroup
+	for i := 0; i < SmsCodeMaxAttempts*4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.verifySmsCode(ctx, phone, wrongCode(code)); !errors.Is(err, apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID) {
+				t.Errorf("expected wrong code to be rejected, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err = s.verifySmsCode(ctx, phone, code); !errors.Is(err, apiv1.AuthError_AUTH_ERROR_SMS_CODE_INVALID) {
+		t.Fatalf("expected code to be dropped after %d parallel attempts, got %v", SmsCodeMaxAttempts*4, err)
+	}
+}
+
+func TestSendSmsCodeThrottle(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.WithValue(context.Background(), AuthContextKeyIP, "203.0.113.1")
+	sender := s.sms.(*testSmsSender)
+
+	if _, err := s.SendSmsCode(ctx, &apiv1.SendSmsCodeRequest{Phone: "13800000000"}); err != nil {
+		t.Fatalf("SendSmsCode failed: %v", err)
+	}
+	_, err := s.SendSmsCode(ctx, &apiv1.SendSmsCodeRequest{Phone: "13800000000"})
+	if !errors.Is(err, apiv1.AuthError_AUTH_ERROR_SMS_TOO_FREQUENT) {
+		t.Fatalf("expected resend within the interval to be throttled, got %v", err)
+	}
+
+	for i := 1; i < SmsIPHourlyLimit; i++ {
+		if _, err = s.SendSmsCode(ctx, &apiv1.SendSmsCodeRequest{Phone: fmt.Sprintf("138000%05d", i)}); err != nil {
+			t.Fatalf("send %d: SendSmsCode failed: %v", i, err)
+		}
+	}
+	_, err = s.SendSmsCode(ctx, &apiv1.SendSmsCodeRequest{Phone: "13900000000"})
+	if !errors.Is(err, apiv1.AuthError_AUTH_ERROR_SMS_TOO_FREQUENT) {
+		t.Fatalf("expected the ip limit to be reached, got %v", err)
+	}
+	if got := sender.sentCount(); got != SmsIPHourlyLimit {
+		t.Fatalf("expected %d codes to be sent, got %d", SmsIPHourlyLimit, got)
+	}
+
+	other := context.WithValue(context.Background(), AuthContextKeyIP, "203.0.113.2")
+	if _, err = s.SendSmsCode(other, &apiv1.SendSmsCodeRequest{Phone: "13900000000"}); err != nil {
+		t.Fatalf("expected another ip to be allowed, got %v", err)
+	}
+}
+
+func TestSendSmsCodeThrottleConcurrent(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.WithValue(context.Background(), AuthContextKeyIP, "203.0.113.1")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = s.SendSmsCode(ctx, &apiv1.SendSmsCodeRequest{Phone: "13800000000"})
+		}()
+	}
+	wg.Wait()
+	if got := s.sms.(*testSmsSender).sentCount(); got != 1 {
+		t.Fatalf("expected parallel sends within the interval to be throttled, got %d codes", got)
+	}
+}
+
+// wrongCode returns a code of the same length that differs from code.
+func wrongCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
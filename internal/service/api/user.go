@@ -20,7 +20,8 @@ func (s *Service) GetCurrentUser(ctx context.Context, request *apiv1.GetCurrentU
 		return nil, err
 	}
 	return &apiv1.GetCurrentUserResponse{
-		User: s.render.User(me),
+		User:     s.render.User(me),
+		DeleteAt: me.DeleteAt,
 	}, nil
 }
 
@@ -29,23 +30,41 @@ func (s *Service) ListUserPlatforms(ctx context.Context, request *apiv1.ListUser
 	if err != nil {
 		return nil, err
 	}
-	me, err := s.db.User.Get(ctx, id)
+	return s.listUserPlatforms(ctx, id)
+}
+
+func (s *Service) listUserPlatforms(ctx context.Context, uid int64) (*apiv1.ListUserPlatformsResponse, error) {
+	me, err := s.db.User.Get(ctx, uid)
 	if err != nil {
 		return nil, err
 	}
-	plat, err := s.db.UserPlatform.Query().Where(userplatform.UserIDEQ(id)).All(ctx)
+	plat, err := s.db.UserPlatform.Query().
+		Where(userplatform.UserIDEQ(uid)).
+		Order(userplatform.ByID()).
+		All(ctx)
 	if err != nil {
 		return nil, err
 	}
 	res := apiv1.ListUserPlatformsResponse{
 		Username: me.Username,
 	}
+	mainAppID := s.wechat.MainAppID()
 	for _, p := range plat {
 		switch p.Platform {
-		case userplatform.PlatformWechatMini:
-			res.WechatMini = p.PlatformID
+		case userplatform.PlatformWechatMini, userplatform.PlatformWechatOa:
+			appID := s.wechatPlatformAppID(p)
+			res.WechatAccounts = append(res.WechatAccounts, &apiv1.WechatAccount{
+				Platform: string(p.Platform),
+				AppId:    appID,
+				OpenId:   p.PlatformID,
+			})
+			if p.Platform == userplatform.PlatformWechatMini && (res.WechatMini == "" || appID == mainAppID) {
+				res.WechatMini = p.PlatformID
+			}
 		case userplatform.PlatformPhone:
 			res.Phone = p.PlatformID
+		case userplatform.PlatformEmail:
+			res.Email = p.PlatformID
 		}
 	}
 	return &res, nil
@@ -56,18 +75,19 @@ func (s *Service) BindPhoneWxMini(ctx context.Context, request *apiv1.BindPhoneW
 	if err != nil {
 		return nil, err
 	}
-	number, err := s.wechat.GetUserPhoneNumber(ctx, request.Code, wechat.WithRetryable(true))
+	_, app, err := s.wechatApp(request.AppId)
+	if err != nil {
+		return nil, err
+	}
+	number, err := app.GetUserPhoneNumber(ctx, request.Code, wechat.WithRetryable(true))
 	if err != nil {
 		return nil, err
 	}
-	if number.PhoneInfo.CountryCode != "86" {
-		return nil, apiv1.AuthError_AUTH_ERROR_UNSUPPORTED_PHONE_REGION
+	phone, err := s.normalizePhone(number.PhoneInfo.PurePhoneNumber, number.PhoneInfo.CountryCode)
+	if err != nil {
+		return nil, err
 	}
-	err = s.db.UserPlatform.Create().
-		SetUserID(userId).
-		SetPlatform(userplatform.PlatformPhone).
-		SetPlatformID(number.PhoneInfo.PhoneNumber).
-		Exec(ctx)
+	_, err = s.linkPlatform(ctx, userId, userplatform.PlatformPhone, phone, false, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	apiv1 "github.com/go-sphere/sphere-layout/api/api/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userflagrecord"
+	"github.com/go-sphere/sphere-layout/internal/pkg/userflag"
+)
+
+const (
+	UserFlagsCacheDuration = time.Minute
+)
+
+// userFlags returns the flags of the user with expired ones cleared.
+// The result is cached briefly since it is checked on every authenticated request, and the lookup
+// only reads: a write transaction is opened only when a flag has actually expired.
+func (s *Service) userFlags(ctx context.Context, uid int64) (uint64, error) {
+	if raw, found, err := s.cache.Get(ctx, userflag.CacheKey(uid)); err == nil && found {
+		if flags, pErr := strconv.ParseUint(string(raw), 10, 64); pErr == nil {
+			return flags, nil
+		}
+	}
+	user, err := s.db.User.Get(ctx, uid)
+	if err != nil {
+		return 0, err
+	}
+	flags := user.Flags
+	if flags != 0 {
+		expired, qErr := s.db.UserFlagRecord.Query().
+			Where(
+				userflagrecord.UserIDEQ(uid),
+				userflagrecord.ExpiresAtGT(0),
+				userflagrecord.ExpiresAtLTE(time.Now().Unix()),
+			).
+			All(ctx)
+		if qErr != nil {
+			return 0, qErr
+		}
+		if len(expired) > 0 {
+			if flags, err = s.expireUserFlags(ctx, uid, expired); err != nil {
+				return 0, err
+			}
+		}
+	}
+	_ = s.cache.SetWithTTL(ctx, userflag.CacheKey(uid), []byte(strconv.FormatUint(flags, 10)), UserFlagsCacheDuration)
+	return flags, nil
+}
+
+// expireUserFlags clears the flags of the expired records and deletes them.
+// The user is read again inside the transaction so that concurrent flag changes are kept.
+func (s *Service) expireUserFlags(ctx context.Context, uid int64, expired []*ent.UserFlagRecord) (uint64, error) {
+	flags, err := dao.WithTx[uint64](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*uint64, error) {
+		user, err := client.User.Get(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		flags := user.Flags
+		ids := make([]int64, 0, len(expired))
+		for _, record := range expired {
+			if flag, ok := userflag.Lookup(record.Flag); ok {
+				flags &^= flag.Bit
+			}
+			ids = append(ids, record.ID)
+		}
+		if _, err = client.UserFlagRecord.Delete().Where(userflagrecord.IDIn(ids...)).Exec(ctx); err != nil {
+			return nil, err
+		}
+		if flags != user.Flags {
+			if err = client.User.UpdateOne(user).SetFlags(flags).Exec(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return &flags, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return *flags, nil
+}
+
+// CheckUserStatus rejects users which are not allowed to use the app, e.g. banned ones.
+func (s *Service) CheckUserStatus(ctx context.Context, uid int64) error {
+	flags, err := s.userFlags(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if userflag.Has(flags, userflag.Banned) {
+		return apiv1.AuthError_AUTH_ERROR_USER_BANNED
+	}
+	return nil
+}
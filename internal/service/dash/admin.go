@@ -7,6 +7,7 @@ import (
 	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
 	"github.com/go-sphere/sphere-layout/internal/pkg/conv"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/admin"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/role"
 	"github.com/go-sphere/sphere-layout/internal/pkg/render/entbind"
 	"github.com/go-sphere/sphere/utils/secure"
 )
@@ -15,6 +16,12 @@ var _ dashv1.AdminServiceHTTPServer = (*Service)(nil)
 
 func (s *Service) CreateAdmin(ctx context.Context, request *dashv1.CreateAdminRequest) (*dashv1.CreateAdminResponse, error) {
 	request.Admin.Avatar = s.storage.ExtractKeyFromURL(request.Admin.Avatar)
+	if _, err := s.validateAdminPassword(nil, request.Admin.Password); err != nil {
+		return nil, err
+	}
+	if err := s.checkRolesExist(ctx, request.Admin.Roles); err != nil {
+		return nil, err
+	}
 	request.Admin.Password = secure.CryptPassword(request.Admin.Password)
 	u, err := entbind.CreateAdmin(s.db.Admin.Create(), request.Admin, entbind.IgnoreField(admin.FieldID)).Save(ctx)
 	if err != nil {
@@ -69,14 +76,30 @@ func (s *Service) ListAdmins(ctx context.Context, request *dashv1.ListAdminsRequ
 }
 
 func (s *Service) UpdateAdmin(ctx context.Context, req *dashv1.UpdateAdminRequest) (*dashv1.UpdateAdminResponse, error) {
+	if err := s.checkRolesExist(ctx, req.Admin.Roles); err != nil {
+		return nil, err
+	}
+	var history []string
 	if req.Admin.Password != "" {
+		adm, err := s.db.Admin.Get(ctx, req.Admin.Id)
+		if err != nil {
+			return nil, err
+		}
+		history, err = s.validateAdminPassword(adm, req.Admin.Password)
+		if err != nil {
+			return nil, err
+		}
 		req.Admin.Password = secure.CryptPassword(req.Admin.Password)
 	}
-	u, err := entbind.UpdateOneAdmin(
+	update := entbind.UpdateOneAdmin(
 		s.db.Admin.UpdateOneID(req.Admin.Id),
 		req.Admin,
 		entbind.IgnoreSetZeroField(admin.FieldPassword),
-	).Save(ctx)
+	)
+	if history != nil {
+		update = update.SetPasswordHistory(history)
+	}
+	u, err := update.Save(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -85,11 +108,23 @@ func (s *Service) UpdateAdmin(ctx context.Context, req *dashv1.UpdateAdminReques
 	}, nil
 }
 
+func (s *Service) UnlockAdmin(ctx context.Context, request *dashv1.UnlockAdminRequest) (*dashv1.UnlockAdminResponse, error) {
+	err := s.db.Admin.UpdateOneID(request.Id).
+		SetLoginFailures(0).
+		SetLockedUntil(0).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.UnlockAdminResponse{}, nil
+}
+
 func (s *Service) ListAdminRoles(ctx context.Context, request *dashv1.ListAdminRolesRequest) (*dashv1.ListAdminRolesResponse, error) {
+	roles, err := s.db.Role.Query().Order(role.ByName()).Select(role.FieldName).Strings(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return &dashv1.ListAdminRolesResponse{
-		Roles: []string{
-			PermissionAll,
-			PermissionAdmin,
-		},
+		Roles: roles,
 	}, nil
 }
@@ -2,12 +2,16 @@ package dash
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"entgo.io/ent/dialect/sql"
 	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
 	"github.com/go-sphere/sphere-layout/internal/pkg/conv"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/adminapikey"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/adminsession"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
 )
 
 var _ dashv1.AdminSessionServiceHTTPServer = (*Service)(nil)
@@ -20,6 +24,14 @@ func (s *Service) DeleteAdminSession(ctx context.Context, request *dashv1.Delete
 	return &dashv1.DeleteAdminSessionResponse{}, nil
 }
 
+func (s *Service) ForceLogoutAdmin(ctx context.Context, request *dashv1.ForceLogoutAdminRequest) (*dashv1.ForceLogoutAdminResponse, error) {
+	err := s.forceLogoutAdmin(ctx, request.Uid)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.ForceLogoutAdminResponse{}, nil
+}
+
 func (s *Service) ListAdminSessions(ctx context.Context, request *dashv1.ListAdminSessionsRequest) (*dashv1.ListAdminSessionsResponse, error) {
 	uid, err := s.GetCurrentID(ctx)
 	if err != nil {
@@ -51,3 +63,99 @@ func (s *Service) ListAdminSessions(ctx context.Context, request *dashv1.ListAdm
 		TotalPage:     int64(totalPage),
 	}, nil
 }
+
+func revokeAdminSessions(ctx context.Context, client *ent.Client, uid int64) error {
+	return client.AdminSession.Update().
+		Where(adminsession.UIDEQ(uid), adminsession.IsRevokedEQ(false)).
+		SetIsRevoked(true).
+		Exec(ctx)
+}
+
+// adminSessionFamilyID returns the family of a session. Sessions created before
+// family tracking was introduced have no family and form one on their own.
+func adminSessionFamilyID(session *ent.AdminSession) int64 {
+	if session.FamilyID != 0 {
+		return session.FamilyID
+	}
+	return session.ID
+}
+
+// revokeAdminSessionFamily revokes every session rotated from the same login after a refresh token was reused.
+func (s *Service) revokeAdminSessionFamily(ctx context.Context, session *ent.AdminSession) error {
+	familyID := adminSessionFamilyID(session)
+	count, err := s.db.AdminSession.Update().
+		Where(
+			adminsession.Or(adminsession.FamilyIDEQ(familyID), adminsession.IDEQ(familyID)),
+			adminsession.IsRevokedEQ(false),
+		).
+		SetIsRevoked(true).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	s.emitSecurityEvent(ctx, SecurityEvent{
+		Type:      SecurityEventRefreshTokenReuse,
+		UID:       session.UID,
+		SessionID: session.ID,
+		Detail:    "revoked " + strconv.Itoa(count) + " sessions of family " + strconv.FormatInt(familyID, 10),
+	})
+	return nil
+}
+
+func adminTokenDenyKey(uid int64) string {
+	return "admin_token_deny:" + strconv.FormatInt(uid, 10)
+}
+
+// forceLogoutAdmin revokes every session and API key of the admin and denies all access tokens issued so far.
+// Access tokens are stateless, so the deny entry only has to outlive AuthTokenValidDuration.
+func (s *Service) forceLogoutAdmin(ctx context.Context, uid int64) error {
+	err := revokeAdminSessions(ctx, s.db.Client, uid)
+	if err != nil {
+		return err
+	}
+	err = s.db.AdminApiKey.Update().
+		Where(adminapikey.UIDEQ(uid), adminapikey.IsRevokedEQ(false)).
+		SetIsRevoked(true).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	return s.cache.SetWithTTL(ctx, adminTokenDenyKey(uid), []byte(now), AuthTokenValidDuration)
+}
+
+func (s *Service) loadAdminTokenDeniedAt(ctx context.Context, uid int64) (int64, bool, error) {
+	raw, found, err := s.cache.Get(ctx, adminTokenDenyKey(uid))
+	if err != nil || !found {
+		return 0, false, err
+	}
+	deniedAt, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return deniedAt, true, nil
+}
+
+// accessTokenIssuedAt returns the issue time of a new access token. Token times only have second
+// precision, so a token issued in the same second as a forced logout is moved to the next second,
+// otherwise it could not be told apart from the tokens the logout denied.
+func (s *Service) accessTokenIssuedAt(ctx context.Context, uid int64) time.Time {
+	now := time.Now()
+	deniedAt, found, err := s.loadAdminTokenDeniedAt(ctx, uid)
+	if err != nil || !found || now.Unix() > deniedAt {
+		return now
+	}
+	return time.Unix(deniedAt+1, 0)
+}
+
+// CheckAccessToken rejects access tokens issued before the admin was forced to log out.
+func (s *Service) CheckAccessToken(ctx context.Context, claims *jwtauth.RBACClaims[int64]) error {
+	deniedAt, found, err := s.loadAdminTokenDeniedAt(ctx, claims.UID)
+	if err != nil || !found {
+		return err
+	}
+	if claims.ExpiresAt == nil || claims.ExpiresAt.Add(-AuthTokenValidDuration).Unix() <= deniedAt {
+		return dashv1.AdminSessionError_ADMIN_SESSION_ERROR_TOKEN_DENIED
+	}
+	return nil
+}
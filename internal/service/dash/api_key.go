@@ -0,0 +1,203 @@
+package dash
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/conv"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/adminapikey"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
+)
+
+var _ dashv1.ApiKeyServiceHTTPServer = (*Service)(nil)
+
+const (
+	ApiKeyPrefix = "sk_"
+	// ApiKeyScopeRolePrefix marks the roles of an access token exchanged from an API key.
+	// Each such role grants exactly one permission, see IsAllowed.
+	ApiKeyScopeRolePrefix = "scope:"
+	// ApiKeyRole is carried by every token exchanged from an API key, so that a key without
+	// any scope is still recognised as one. It grants no permission.
+	ApiKeyRole                  = ApiKeyScopeRolePrefix
+	ApiKeyExchangeCacheDuration = time.Second * 30
+	ApiKeyLastUsedInterval      = time.Minute
+)
+
+const (
+	AuthContextKeyClaims = "auth_claims"
+)
+
+func IsApiKey(token string) bool {
+	return strings.HasPrefix(token, ApiKeyPrefix)
+}
+
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func apiKeyTokenKey(hash string) string {
+	return "api_key_token:" + hash
+}
+
+func generateApiKey() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return ApiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// isApiKeyRequest reports whether the current request is authenticated with an API key.
+func isApiKeyRequest(ctx context.Context) bool {
+	claims, ok := ctx.Value(AuthContextKeyClaims).(*jwtauth.RBACClaims[int64])
+	if !ok {
+		return false
+	}
+	return isApiKeyRoles(claims.Roles)
+}
+
+// isApiKeyRoles reports whether the roles belong to an access token exchanged from an API key.
+func isApiKeyRoles(roles []string) bool {
+	for _, r := range roles {
+		if strings.HasPrefix(r, ApiKeyScopeRolePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExchangeApiKey validates an API key and returns an access token limited to the scopes of the key
+// which the owner still holds. Tokens are cached briefly so that scripts do not hit the database on every call.
+func (s *Service) ExchangeApiKey(ctx context.Context, key string) (string, error) {
+	hash := hashApiKey(key)
+	if raw, found, err := s.session.Get(ctx, apiKeyTokenKey(hash)); err == nil && found {
+		return string(raw), nil
+	}
+	item, err := s.db.AdminApiKey.Query().Where(adminapikey.KeyHashEQ(hash)).Only(ctx)
+	if err != nil {
+		return "", dashv1.ApiKeyError_API_KEY_ERROR_INVALID
+	}
+	now := time.Now()
+	if item.IsRevoked || (item.ExpiresAt > 0 && item.ExpiresAt < now.Unix()) {
+		return "", dashv1.ApiKeyError_API_KEY_ERROR_INVALID
+	}
+	administrator, err := s.db.Admin.Get(ctx, item.UID)
+	if err != nil {
+		return "", dashv1.ApiKeyError_API_KEY_ERROR_INVALID
+	}
+	if err = s.checkAdminLocked(administrator); err != nil {
+		return "", err
+	}
+	permissions := s.EffectivePermissions(administrator.Roles)
+	roles := make([]string, 0, len(item.Scopes)+1)
+	roles = append(roles, ApiKeyRole)
+	for _, scope := range item.Scopes {
+		if slices.Contains(permissions, scope) {
+			roles = append(roles, ApiKeyScopeRolePrefix+scope)
+		}
+	}
+	issuedAt := s.accessTokenIssuedAt(ctx, administrator.ID)
+	claims := jwtauth.NewRBACClaims(administrator.ID, administrator.Username, roles, issuedAt.Add(AuthTokenValidDuration))
+	token, err := s.authorizer.GenerateToken(ctx, claims)
+	if err != nil {
+		return "", err
+	}
+	if now.Sub(time.Unix(item.LastUsedAt, 0)) > ApiKeyLastUsedInterval {
+		_ = s.db.AdminApiKey.UpdateOneID(item.ID).SetLastUsedAt(now.Unix()).Exec(ctx)
+	}
+	_ = s.session.SetWithTTL(ctx, apiKeyTokenKey(hash), []byte(token), ApiKeyExchangeCacheDuration)
+	return token, nil
+}
+
+func (s *Service) ListApiKeys(ctx context.Context, request *dashv1.ListApiKeysRequest) (*dashv1.ListApiKeysResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := s.db.AdminApiKey.Query().Where(adminapikey.UIDEQ(uid))
+	count, err := query.Clone().Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	totalPage, pageSize := conv.Page(count, int(request.PageSize))
+	all, err := query.Clone().Limit(pageSize).Order(adminapikey.ByID(sql.OrderDesc())).Offset(pageSize * int(request.Page)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.ListApiKeysResponse{
+		ApiKeys:   conv.Map(all, s.render.AdminApiKey),
+		TotalSize: int64(count),
+		TotalPage: int64(totalPage),
+	}, nil
+}
+
+func (s *Service) CreateApiKey(ctx context.Context, request *dashv1.CreateApiKeyRequest) (*dashv1.CreateApiKeyResponse, error) {
+	if isApiKeyRequest(ctx) {
+		return nil, dashv1.ApiKeyError_API_KEY_ERROR_NOT_ALLOWED
+	}
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	administrator, err := s.db.Admin.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	permissions := s.EffectivePermissions(administrator.Roles)
+	scopes := conv.UniqueSorted(request.Scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(permissions, scope) {
+			return nil, dashv1.ApiKeyError_API_KEY_ERROR_INVALID_SCOPE
+		}
+	}
+	key, err := generateApiKey()
+	if err != nil {
+		return nil, err
+	}
+	create := s.db.AdminApiKey.Create().
+		SetUID(uid).
+		SetName(request.Name).
+		SetPrefix(key[:len(ApiKeyPrefix)+6]).
+		SetKeyHash(hashApiKey(key)).
+		SetScopes(scopes)
+	if request.ExpiresInDays > 0 {
+		create = create.SetExpiresAt(time.Now().AddDate(0, 0, int(request.ExpiresInDays)).Unix())
+	}
+	item, err := create.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.CreateApiKeyResponse{
+		ApiKey: s.render.AdminApiKey(item),
+		Key:    key,
+	}, nil
+}
+
+func (s *Service) RevokeApiKey(ctx context.Context, request *dashv1.RevokeApiKeyRequest) (*dashv1.RevokeApiKeyResponse, error) {
+	if isApiKeyRequest(ctx) {
+		return nil, dashv1.ApiKeyError_API_KEY_ERROR_NOT_ALLOWED
+	}
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	item, err := s.db.AdminApiKey.Query().Where(adminapikey.IDEQ(request.Id), adminapikey.UIDEQ(uid)).Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.AdminApiKey.UpdateOneID(item.ID).SetIsRevoked(true).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_ = s.session.Del(ctx, apiKeyTokenKey(item.KeyHash))
+	return &dashv1.RevokeApiKeyResponse{}, nil
+}
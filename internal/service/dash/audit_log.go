@@ -0,0 +1,199 @@
+package dash
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	entgo "entgo.io/ent"
+	"entgo.io/ent/dialect/sql"
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/conv"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/auditlog"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/schema"
+	"github.com/go-sphere/sphere/log"
+)
+
+var _ dashv1.AuditLogServiceHTTPServer = (*Service)(nil)
+
+const (
+	AuditContextKeyOperation = "audit_operation"
+)
+
+// auditSensitiveFields lists the audited entities together with their Sensitive() fields,
+// which are never written to the audit log.
+var auditSensitiveFields = func() map[string]map[string]struct{} {
+	schemas := map[string]entgo.Interface{
+		"Admin":          schema.Admin{},
+		"AdminApiKey":    schema.AdminApiKey{},
+		"AdminSession":   schema.AdminSession{},
+		"KeyValueStore":  schema.KeyValueStore{},
+		"Role":           schema.Role{},
+		"Permission":     schema.Permission{},
+		"User":           schema.User{},
+		"UserFlagRecord": schema.UserFlagRecord{},
+		"UserPlatform":   schema.UserPlatform{},
+	}
+	result := make(map[string]map[string]struct{}, len(schemas))
+	for name, sch := range schemas {
+		sensitive := make(map[string]struct{})
+		for _, f := range sch.Fields() {
+			if desc := f.Descriptor(); desc.Sensitive {
+				sensitive[desc.Name] = struct{}{}
+			}
+		}
+		result[name] = sensitive
+	}
+	return result
+}()
+
+type auditChange struct {
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+type auditMutation interface {
+	ent.Mutation
+	Client() *ent.Client
+	ID() (int64, bool)
+	IDs(ctx context.Context) ([]int64, error)
+}
+
+func auditAction(op ent.Op) string {
+	switch {
+	case op.Is(ent.OpCreate):
+		return "create"
+	case op.Is(ent.OpDelete | ent.OpDeleteOne):
+		return "delete"
+	default:
+		return "update"
+	}
+}
+
+func auditChanges(ctx context.Context, m ent.Mutation, sensitive map[string]struct{}) string {
+	changes := make(map[string]auditChange)
+	for _, name := range m.Fields() {
+		if _, ok := sensitive[name]; ok || name == "updated_at" {
+			continue
+		}
+		value, _ := m.Field(name)
+		change := auditChange{New: value}
+		if m.Op().Is(ent.OpUpdateOne) {
+			if old, err := m.OldField(ctx, name); err == nil {
+				if reflect.DeepEqual(old, value) {
+					continue
+				}
+				change.Old = old
+			}
+		}
+		changes[name] = change
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+// auditHook records the mutations made while handling a request tagged with AuditContextKeyOperation.
+// The log rows are written with the mutation's client, so they share its transaction.
+func (s *Service) auditHook() ent.Hook {
+	return func(next ent.Mutator) ent.Mutator {
+		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+			operation, _ := ctx.Value(AuditContextKeyOperation).(string)
+			sensitive, audited := auditSensitiveFields[m.Type()]
+			mutation, ok := m.(auditMutation)
+			if operation == "" || !audited || !ok {
+				return next.Mutate(ctx, m)
+			}
+			var ids []int64
+			if !m.Op().Is(ent.OpCreate) {
+				var err error
+				if ids, err = mutation.IDs(ctx); err != nil {
+					return nil, err
+				}
+			}
+			changes := auditChanges(ctx, m, sensitive)
+			value, err := next.Mutate(ctx, m)
+			if err != nil {
+				return value, err
+			}
+			if id, exist := mutation.ID(); exist && m.Op().Is(ent.OpCreate) {
+				ids = []int64{id}
+			}
+			uid, _ := s.GetCurrentID(ctx)
+			ip, _ := ctx.Value(AuthContextKeyIP).(string)
+			ua, _ := ctx.Value(AuthContextKeyUA).(string)
+			builders := make([]*ent.AuditLogCreate, 0, len(ids))
+			for _, id := range ids {
+				builders = append(builders, mutation.Client().AuditLog.Create().
+					SetUID(uid).
+					SetOperation(operation).
+					SetEntity(m.Type()).
+					SetTargetID(id).
+					SetAction(auditAction(m.Op())).
+					SetChanges(changes).
+					SetIPAddress(ip).
+					SetUserAgent(ua),
+				)
+			}
+			if len(builders) > 0 {
+				if aErr := mutation.Client().AuditLog.CreateBulk(builders...).Exec(ctx); aErr != nil {
+					log.Warn("write audit log failed", log.Any("operation", operation), log.Any("error", aErr))
+					return nil, aErr
+				}
+			}
+			return value, nil
+		})
+	}
+}
+
+func (s *Service) ListAuditLogs(ctx context.Context, request *dashv1.ListAuditLogsRequest) (*dashv1.ListAuditLogsResponse, error) {
+	query := s.db.AuditLog.Query()
+	if request.Uid != 0 {
+		query = query.Where(auditlog.UIDEQ(request.Uid))
+	}
+	if request.Operation != "" {
+		query = query.Where(auditlog.OperationEQ(request.Operation))
+	}
+	if request.Entity != "" {
+		query = query.Where(auditlog.EntityEQ(request.Entity))
+	}
+	if request.TargetId != 0 {
+		query = query.Where(auditlog.TargetIDEQ(request.TargetId))
+	}
+	if request.StartTime > 0 {
+		query = query.Where(auditlog.CreatedAtGTE(request.StartTime))
+	}
+	if request.EndTime > 0 {
+		query = query.Where(auditlog.CreatedAtLTE(request.EndTime))
+	}
+	count, err := query.Clone().Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	totalPage, pageSize := conv.Page(count, int(request.PageSize))
+	all, err := query.Clone().Limit(pageSize).Order(auditlog.ByID(sql.OrderDesc())).Offset(pageSize * int(request.Page)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.ListAuditLogsResponse{
+		AuditLogs: conv.Map(all, s.render.AuditLog),
+		TotalSize: int64(count),
+		TotalPage: int64(totalPage),
+	}, nil
+}
+
+func (s *Service) GetAuditLog(ctx context.Context, request *dashv1.GetAuditLogRequest) (*dashv1.GetAuditLogResponse, error) {
+	item, err := s.db.AuditLog.Get(ctx, request.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.GetAuditLogResponse{
+		AuditLog: s.render.AuditLog(item),
+	}, nil
+}
@@ -2,13 +2,16 @@ package dash
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
 	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
 	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/admin"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/adminsession"
 	"github.com/go-sphere/sphere/server/auth/jwtauth"
+	"github.com/go-sphere/sphere/utils/idgenerator"
 	"github.com/go-sphere/sphere/utils/secure"
 	"github.com/google/uuid"
 )
@@ -31,6 +34,7 @@ type AdminToken struct {
 	AccessToken  string
 	RefreshToken string
 	Expires      string
+	Challenge    string
 }
 
 type Session struct {
@@ -38,23 +42,36 @@ type Session struct {
 	Expires int64 `json:"expires"`
 }
 
-func (s *Service) createAdminToken(ctx context.Context, client *ent.Client, administrator *ent.Admin) (*AdminToken, error) {
+// createAdminToken issues a new access/refresh token pair. When parent is not nil the new session
+// replaces it and joins the same session family, which allows detecting refresh token reuse.
+func (s *Service) createAdminToken(ctx context.Context, client *ent.Client, administrator *ent.Admin, parent *ent.AdminSession) (*AdminToken, error) {
 	newUUID, err := uuid.NewUUID()
 	if err != nil {
 		return nil, err
 	}
 
-	authClaims := jwtauth.NewRBACClaims(administrator.ID, administrator.Username, administrator.Roles, time.Now().Add(AuthTokenValidDuration))
+	issuedAt := s.accessTokenIssuedAt(ctx, administrator.ID)
+	authClaims := jwtauth.NewRBACClaims(administrator.ID, administrator.Username, administrator.Roles, issuedAt.Add(AuthTokenValidDuration))
 	token, err := s.authorizer.GenerateToken(ctx, authClaims)
 	if err != nil {
 		return nil, err
 	}
 
+	sessionID := idgenerator.NextId()
+	familyID := sessionID
+	if parent != nil {
+		familyID = adminSessionFamilyID(parent)
+	}
 	sessionExpires := time.Now().Add(RefreshTokenValidDuration)
 	session := client.AdminSession.Create().
+		SetID(sessionID).
+		SetFamilyID(familyID).
 		SetUID(administrator.ID).
 		SetSessionKey(newUUID.String()).
 		SetExpires(sessionExpires.Unix())
+	if parent != nil {
+		session = session.SetParentID(parent.ID)
+	}
 	if ip, ok := ctx.Value(AuthContextKeyIP).(string); ok {
 		session = session.SetIPAddress(ip)
 	}
@@ -81,40 +98,142 @@ func (s *Service) createAdminToken(ctx context.Context, client *ent.Client, admi
 }
 
 func (s *Service) LoginWithPassword(ctx context.Context, request *dashv1.LoginWithPasswordRequest) (*dashv1.LoginWithPasswordResponse, error) {
+	administrator, err := s.db.Admin.Query().Where(admin.UsernameEqualFold(request.Username)).Only(ctx)
+	if err != nil {
+		return nil, dashv1.AuthError_AUTH_ERROR_INVALID_CREDENTIALS // 隐藏错误信息
+	}
+	if err = s.checkAdminLocked(administrator); err != nil {
+		return nil, err
+	}
+	if !secure.IsPasswordMatch(request.Password, administrator.Password) {
+		return nil, s.recordLoginFailure(ctx, administrator)
+	}
 	token, err := dao.WithTx[AdminToken](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*AdminToken, error) {
-		administrator, err := client.Admin.Query().Where(admin.UsernameEqualFold(request.Username)).Only(ctx)
-		if err != nil {
-			return nil, dashv1.AuthError_AUTH_ERROR_INVALID_CREDENTIALS // 隐藏错误信息
+		if administrator.TotpEnabled {
+			// 失败次数在完成二次验证后才清零, 否则可以通过反复获取挑战来无限尝试验证码
+			challenge, cErr := s.createTotpChallenge(ctx, administrator)
+			if cErr != nil {
+				return nil, cErr
+			}
+			return &AdminToken{Admin: administrator, Challenge: challenge}, nil
 		}
-		if !secure.IsPasswordMatch(request.Password, administrator.Password) {
-			return nil, dashv1.AuthError_AUTH_ERROR_INVALID_CREDENTIALS
+		if rErr := s.resetLoginFailures(ctx, client, administrator); rErr != nil {
+			return nil, rErr
 		}
-		return s.createAdminToken(ctx, client, administrator)
+		return s.createAdminToken(ctx, client, administrator, nil)
 	})
 	if err != nil {
 		return nil, err
 	}
+	if token.Challenge != "" {
+		return &dashv1.LoginWithPasswordResponse{
+			Avatar:         s.storage.GenerateURL(token.Admin.Avatar),
+			Username:       token.Admin.Username,
+			TotpRequired:   true,
+			ChallengeToken: token.Challenge,
+		}, nil
+	}
 	return &dashv1.LoginWithPasswordResponse{
 		Avatar:       s.storage.GenerateURL(token.Admin.Avatar),
 		Username:     token.Admin.Username,
 		Roles:        token.Admin.Roles,
+		Permissions:  s.EffectivePermissions(token.Admin.Roles),
 		AccessToken:  token.AccessToken,
 		RefreshToken: token.RefreshToken,
 		Expires:      token.Expires,
 	}, nil
 }
 
-func (s *Service) RefreshToken(ctx context.Context, request *dashv1.RefreshTokenRequest) (*dashv1.RefreshTokenResponse, error) {
+func (s *Service) LoginWithTotp(ctx context.Context, request *dashv1.LoginWithTotpRequest) (*dashv1.LoginWithTotpResponse, error) {
+	challenge, err := s.reserveTotpChallenge(ctx, request.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+	administrator, err := s.db.Admin.Get(ctx, challenge.UID)
+	if err != nil || !administrator.TotpEnabled {
+		return nil, dashv1.AuthError_AUTH_ERROR_INVALID_CHALLENGE
+	}
+	if err = s.checkAdminLocked(administrator); err != nil {
+		_ = s.session.Del(ctx, totpChallengeKey(request.ChallengeToken))
+		return nil, err
+	}
 	token, err := dao.WithTx[AdminToken](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*AdminToken, error) {
-		claims, err := s.authRefresher.ParseToken(ctx, request.RefreshToken)
-		if err != nil {
-			return nil, err
+		ok, vErr := s.verifyAdminTotp(ctx, client, administrator, request.Code)
+		if vErr != nil {
+			return nil, vErr
 		}
-		session, err := client.AdminSession.Get(ctx, claims.UID)
+		if !ok {
+			return nil, dashv1.AuthError_AUTH_ERROR_INVALID_TOTP_CODE
+		}
+		if rErr := s.resetLoginFailures(ctx, client, administrator); rErr != nil {
+			return nil, rErr
+		}
+		return s.createAdminToken(ctx, client, administrator, nil)
+	})
+	if err != nil {
+		if errors.Is(err, dashv1.AuthError_AUTH_ERROR_INVALID_TOTP_CODE) {
+			return nil, s.recordTotpFailure(ctx, request.ChallengeToken, administrator)
+		}
+		return nil, err
+	}
+	_ = s.session.Del(ctx, totpChallengeKey(request.ChallengeToken))
+	return &dashv1.LoginWithTotpResponse{
+		Avatar:       s.storage.GenerateURL(token.Admin.Avatar),
+		Username:     token.Admin.Username,
+		Roles:        token.Admin.Roles,
+		Permissions:  s.EffectivePermissions(token.Admin.Roles),
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Expires:      token.Expires,
+	}, nil
+}
+
+// loadRefreshSession parses the refresh token and returns the session it belongs to.
+func (s *Service) loadRefreshSession(ctx context.Context, client *ent.Client, refreshToken string) (*ent.AdminSession, error) {
+	claims, err := s.authRefresher.ParseToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	session, err := client.AdminSession.Get(ctx, claims.UID)
+	if err != nil {
+		return nil, err
+	}
+	if session.SessionKey != claims.Subject {
+		return nil, dashv1.AdminSessionError_ADMIN_SESSION_ERROR_KEY_NOT_MATCH
+	}
+	return session, nil
+}
+
+// rotateAdminSession revokes the session a refresh token belongs to. It reports false when the session was
+// revoked in the meantime, e.g. by a concurrent refresh with the same token.
+func rotateAdminSession(ctx context.Context, client *ent.Client, session *ent.AdminSession) (bool, error) {
+	count, err := client.AdminSession.Update().
+		Where(adminsession.IDEQ(session.ID), adminsession.IsRevokedEQ(false)).
+		SetIsRevoked(true).
+		Save(ctx)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *Service) RefreshToken(ctx context.Context, request *dashv1.RefreshTokenRequest) (*dashv1.RefreshTokenResponse, error) {
+	var reused *ent.AdminSession
+	token, err := dao.WithTx[AdminToken](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*AdminToken, error) {
+		session, err := s.loadRefreshSession(ctx, client, request.RefreshToken)
 		if err != nil {
 			return nil, err
 		}
 		if session.IsRevoked {
+			// 已轮换过的刷新令牌再次出现, 说明令牌可能已泄露
+			rotated, qErr := client.AdminSession.Query().Where(adminsession.ParentIDEQ(session.ID)).Exist(ctx)
+			if qErr != nil {
+				return nil, qErr
+			}
+			if rotated {
+				reused = session
+				return nil, dashv1.AdminSessionError_ADMIN_SESSION_ERROR_REUSED
+			}
 			return nil, dashv1.AdminSessionError_ADMIN_SESSION_ERROR_REVOKED
 		}
 		if session.Expires < time.Now().Unix() {
@@ -122,20 +241,26 @@ func (s *Service) RefreshToken(ctx context.Context, request *dashv1.RefreshToken
 				client.AdminSession.UpdateOneID(session.ID).SetIsRevoked(true).Exec(ctx),
 			)
 		}
-		if session.SessionKey != claims.Subject {
-			return nil, dashv1.AdminSessionError_ADMIN_SESSION_ERROR_KEY_NOT_MATCH
-		}
 		administrator, err := client.Admin.Get(ctx, session.UID)
 		if err != nil {
 			return nil, err
 		}
-		err = client.AdminSession.UpdateOneID(session.ID).SetIsRevoked(true).Exec(ctx)
+		rotated, err := rotateAdminSession(ctx, client, session)
 		if err != nil {
 			return nil, err
 		}
-		return s.createAdminToken(ctx, client, administrator)
+		if !rotated {
+			// 并发请求已使用同一刷新令牌完成轮换
+			reused = session
+			return nil, dashv1.AdminSessionError_ADMIN_SESSION_ERROR_REUSED
+		}
+		return s.createAdminToken(ctx, client, administrator, session)
 	})
 	if err != nil {
+		if reused != nil {
+			// 在事务外撤销, 避免随事务回滚
+			return nil, dashv1.AdminSessionError_ADMIN_SESSION_ERROR_REUSED.Join(s.revokeAdminSessionFamily(ctx, reused))
+		}
 		return nil, err
 	}
 	return &dashv1.RefreshTokenResponse{
@@ -144,3 +269,32 @@ func (s *Service) RefreshToken(ctx context.Context, request *dashv1.RefreshToken
 		Expires:      token.Expires,
 	}, nil
 }
+
+func (s *Service) Logout(ctx context.Context, request *dashv1.LogoutRequest) (*dashv1.LogoutResponse, error) {
+	session, err := s.loadRefreshSession(ctx, s.db.Client, request.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if !session.IsRevoked {
+		err = s.db.AdminSession.UpdateOneID(session.ID).SetIsRevoked(true).Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &dashv1.LogoutResponse{}, nil
+}
+
+func (s *Service) LogoutEverywhere(ctx context.Context, request *dashv1.LogoutEverywhereRequest) (*dashv1.LogoutEverywhereResponse, error) {
+	session, err := s.loadRefreshSession(ctx, s.db.Client, request.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if session.IsRevoked {
+		return nil, dashv1.AdminSessionError_ADMIN_SESSION_ERROR_REVOKED
+	}
+	err = s.forceLogoutAdmin(ctx, session.UID)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.LogoutEverywhereResponse{}, nil
+}
@@ -0,0 +1,95 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/adminsession"
+)
+
+func TestRotateAdminSession(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	administrator := createTestAdmin(t, db, "admin")
+
+	token, err := s.createAdminToken(ctx, db, administrator, nil)
+	if err != nil {
+		t.Fatalf("createAdminToken failed: %v", err)
+	}
+	session, err := s.loadRefreshSession(ctx, db, token.RefreshToken)
+	if err != nil {
+		t.Fatalf("loadRefreshSession failed: %v", err)
+	}
+	rotated, err := rotateAdminSession(ctx, db, session)
+	if err != nil || !rotated {
+		t.Fatalf("expected the session to be rotated, got %v, %v", rotated, err)
+	}
+	// session 仍是轮换前读取的快照, 模拟并发请求在检查之后才轮换
+	rotated, err = rotateAdminSession(ctx, db, session)
+	if err != nil || rotated {
+		t.Fatalf("expected a stale session not to be rotated again, got %v, %v", rotated, err)
+	}
+}
+
+func TestRefreshTokenConcurrent(t *testing.T) {
+	s, db := newTestService(t)
+	ctx := context.Background()
+	administrator := createTestAdmin(t, db, "admin")
+
+	token, err := s.createAdminToken(ctx, db, administrator, nil)
+	if err != nil {
+		t.Fatalf("createAdminToken failed: %v", err)
+	}
+	session, err := s.loadRefreshSession(ctx, db, token.RefreshToken)
+	if err != nil {
+		t.Fatalf("loadRefreshSession failed: %v", err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, 2)
+	)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = s.RefreshToken(ctx, &dashv1.RefreshTokenRequest{RefreshToken: token.RefreshToken})
+		}()
+	}
+	wg.Wait()
+
+	// SQLite 可能让并发事务直接失败, 无论哪种结果都不能出现两个有效的子会话
+	var succeeded, reused int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, dashv1.AdminSessionError_ADMIN_SESSION_ERROR_REUSED):
+			reused++
+		}
+	}
+	if succeeded > 1 {
+		t.Fatal("expected at most one refresh with the same token to succeed")
+	}
+	active := db.AdminSession.Query().
+		Where(adminsession.ParentIDEQ(session.ID), adminsession.IsRevokedEQ(false)).
+		CountX(ctx)
+	if active > 1 {
+		t.Fatalf("expected at most one active child session, got %d", active)
+	}
+	if reused > 0 && active != 0 {
+		t.Fatal("expected the detected reuse to revoke the rotated session")
+	}
+	if succeeded == 0 {
+		return
+	}
+
+	// 轮换后再次使用同一令牌视为重用
+	_, err = s.RefreshToken(ctx, &dashv1.RefreshTokenRequest{RefreshToken: token.RefreshToken})
+	if !errors.Is(err, dashv1.AdminSessionError_ADMIN_SESSION_ERROR_REUSED) {
+		t.Fatalf("expected the refresh token to be reused, got %v", err)
+	}
+}
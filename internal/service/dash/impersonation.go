@@ -0,0 +1,65 @@
+package dash
+
+import (
+	"context"
+	"encoding/json"
+
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/auth"
+)
+
+var _ dashv1.ImpersonationServiceHTTPServer = (*Service)(nil)
+
+// InitImpersonation sets the authorizer of the API server, which signs the impersonation tokens.
+func (s *Service) InitImpersonation(appAuthorizer TokenAuthorizer) {
+	s.appAuthorizer = appAuthorizer
+}
+
+func (s *Service) ImpersonateUser(ctx context.Context, request *dashv1.ImpersonateUserRequest) (*dashv1.ImpersonateUserResponse, error) {
+	if s.appAuthorizer == nil {
+		return nil, dashv1.ImpersonationError_IMPERSONATION_ERROR_DISABLED
+	}
+	if isApiKeyRequest(ctx) {
+		return nil, dashv1.ImpersonationError_IMPERSONATION_ERROR_NOT_ALLOWED
+	}
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.db.User.Get(ctx, request.Id)
+	if err != nil {
+		return nil, err
+	}
+	claims := auth.RenderImpersonationClaims(user, uid, auth.ImpersonationTokenValidDuration)
+	token, err := s.appAuthorizer.GenerateToken(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := json.Marshal(map[string]auditChange{
+		"reason":  {New: request.Reason},
+		"expires": {New: claims.ExpiresAt.Unix()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	operation, _ := ctx.Value(AuditContextKeyOperation).(string)
+	ip, _ := ctx.Value(AuthContextKeyIP).(string)
+	ua, _ := ctx.Value(AuthContextKeyUA).(string)
+	err = s.db.AuditLog.Create().
+		SetUID(uid).
+		SetOperation(operation).
+		SetEntity("User").
+		SetTargetID(user.ID).
+		SetAction("impersonate").
+		SetChanges(string(changes)).
+		SetIPAddress(ip).
+		SetUserAgent(ua).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.ImpersonateUserResponse{
+		AccessToken: token,
+		Expires:     claims.ExpiresAt.Unix(),
+	}, nil
+}
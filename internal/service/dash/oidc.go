@@ -0,0 +1,165 @@
+package dash
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/conv"
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/admin"
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc"
+	"github.com/go-sphere/sphere/utils/secure"
+)
+
+const (
+	OidcStateValidDuration = time.Minute * 10
+)
+
+// OidcLoginPolicy decides how identity provider users are mapped to admins.
+// Roles are synchronised from the groups claim on every login, so the identity provider stays authoritative.
+type OidcLoginPolicy struct {
+	UsernameClaim string              `json:"username_claim" yaml:"username_claim"`
+	GroupsClaim   string              `json:"groups_claim" yaml:"groups_claim"`
+	GroupRoles    map[string][]string `json:"group_roles" yaml:"group_roles"`
+	DefaultRoles  []string            `json:"default_roles" yaml:"default_roles"`
+}
+
+type oidcLoginState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func oidcStateKey(state string) string {
+	return "oidc_state:" + state
+}
+
+func (s *Service) InitOidc(provider *oidc.Provider, policy OidcLoginPolicy) {
+	if policy.UsernameClaim == "" {
+		policy.UsernameClaim = "preferred_username"
+	}
+	if policy.GroupsClaim == "" {
+		policy.GroupsClaim = "groups"
+	}
+	s.oidcProvider = provider
+	s.oidcPolicy = policy
+}
+
+func (s *Service) oidcRoles(token *oidc.IDToken) []string {
+	roles := append([]string{}, s.oidcPolicy.DefaultRoles...)
+	for _, group := range token.Strings(s.oidcPolicy.GroupsClaim) {
+		roles = append(roles, s.oidcPolicy.GroupRoles[group]...)
+	}
+	return conv.UniqueSorted(roles)
+}
+
+func (s *Service) OidcAuthorize(ctx context.Context, request *dashv1.OidcAuthorizeRequest) (*dashv1.OidcAuthorizeResponse, error) {
+	if s.oidcProvider == nil {
+		return nil, dashv1.AuthError_AUTH_ERROR_OIDC_DISABLED
+	}
+	state := oidc.NewState()
+	loginState := oidcLoginState{
+		Nonce:    oidc.NewState(),
+		Verifier: oidc.NewState(),
+	}
+	raw, err := json.Marshal(loginState)
+	if err != nil {
+		return nil, err
+	}
+	authorizeURL, err := s.oidcProvider.AuthCodeURL(ctx, state, loginState.Nonce, loginState.Verifier)
+	if err != nil {
+		return nil, dashv1.AuthError_AUTH_ERROR_OIDC_LOGIN_FAILED.Join(err)
+	}
+	if err = s.session.SetWithTTL(ctx, oidcStateKey(state), raw, OidcStateValidDuration); err != nil {
+		return nil, err
+	}
+	return &dashv1.OidcAuthorizeResponse{
+		AuthorizeUrl: authorizeURL,
+		State:        state,
+	}, nil
+}
+
+func (s *Service) LoginWithOidc(ctx context.Context, request *dashv1.LoginWithOidcRequest) (*dashv1.LoginWithOidcResponse, error) {
+	if s.oidcProvider == nil {
+		return nil, dashv1.AuthError_AUTH_ERROR_OIDC_DISABLED
+	}
+	raw, found, err := s.session.Get(ctx, oidcStateKey(request.State))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, dashv1.AuthError_AUTH_ERROR_OIDC_INVALID_STATE
+	}
+	_ = s.session.Del(ctx, oidcStateKey(request.State)) // state 只能使用一次
+	var loginState oidcLoginState
+	if err = json.Unmarshal(raw, &loginState); err != nil {
+		return nil, dashv1.AuthError_AUTH_ERROR_OIDC_INVALID_STATE
+	}
+
+	idToken, err := s.oidcProvider.Exchange(ctx, request.Code, loginState.Verifier, loginState.Nonce)
+	if err != nil {
+		return nil, dashv1.AuthError_AUTH_ERROR_OIDC_LOGIN_FAILED.Join(err)
+	}
+	roles := s.oidcRoles(idToken)
+	if len(roles) == 0 {
+		return nil, dashv1.AuthError_AUTH_ERROR_OIDC_NO_ROLE
+	}
+	// 角色映射来自配置, 角色被删除后不能再写入管理员
+	if err = s.checkRolesExist(ctx, roles); err != nil {
+		return nil, dashv1.AuthError_AUTH_ERROR_OIDC_LOGIN_FAILED.Join(err)
+	}
+	username := idToken.String(s.oidcPolicy.UsernameClaim)
+	if username == "" {
+		username = "oidc_" + idToken.Subject
+	}
+
+	token, err := dao.WithTx[AdminToken](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*AdminToken, error) {
+		administrator, err := client.Admin.Query().Where(admin.OidcSubjectEQ(idToken.Subject)).Only(ctx)
+		if err != nil && !ent.IsNotFound(err) {
+			return nil, err
+		}
+		if administrator == nil {
+			// 不自动关联同名的本地账号, 避免身份提供方的用户名接管已有账号
+			exist, eErr := client.Admin.Query().Where(admin.UsernameEqualFold(username)).Exist(ctx)
+			if eErr != nil {
+				return nil, eErr
+			}
+			if exist {
+				return nil, dashv1.AuthError_AUTH_ERROR_OIDC_ACCOUNT_CONFLICT
+			}
+			administrator, err = client.Admin.Create().
+				SetUsername(username).
+				SetNickname(idToken.String("name")).
+				SetPassword(secure.CryptPassword(oidc.NewState())).
+				SetRoles(roles).
+				SetOidcSubject(idToken.Subject).
+				Save(ctx)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			if err = s.checkAdminLocked(administrator); err != nil {
+				return nil, err
+			}
+			administrator, err = client.Admin.UpdateOne(administrator).SetRoles(roles).Save(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return s.createAdminToken(ctx, client, administrator, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.LoginWithOidcResponse{
+		Avatar:       s.storage.GenerateURL(token.Admin.Avatar),
+		Username:     token.Admin.Username,
+		Roles:        token.Admin.Roles,
+		Permissions:  s.EffectivePermissions(token.Admin.Roles),
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Expires:      token.Expires,
+	}, nil
+}
@@ -0,0 +1,84 @@
+package dash
+
+import (
+	"context"
+
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere/utils/secure"
+)
+
+var _ dashv1.ProfileServiceHTTPServer = (*Service)(nil)
+
+func (s *Service) GetProfile(ctx context.Context, request *dashv1.GetProfileRequest) (*dashv1.GetProfileResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	me, err := s.db.Admin.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.GetProfileResponse{
+		Admin: s.render.Admin(me),
+	}, nil
+}
+
+func (s *Service) UpdateProfile(ctx context.Context, request *dashv1.UpdateProfileRequest) (*dashv1.UpdateProfileResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	me, err := s.db.Admin.UpdateOneID(uid).
+		SetNickname(request.Nickname).
+		SetAvatar(s.storage.ExtractKeyFromURL(request.Avatar)).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.UpdateProfileResponse{
+		Admin: s.render.Admin(me),
+	}, nil
+}
+
+func (s *Service) ChangePassword(ctx context.Context, request *dashv1.ChangePasswordRequest) (*dashv1.ChangePasswordResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := dao.WithTx[AdminToken](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*AdminToken, error) {
+		administrator, err := client.Admin.Get(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		if !secure.IsPasswordMatch(request.OldPassword, administrator.Password) {
+			return nil, dashv1.ProfileError_PROFILE_ERROR_WRONG_PASSWORD
+		}
+		history, err := s.validateAdminPassword(administrator, request.NewPassword)
+		if err != nil {
+			return nil, err
+		}
+		administrator, err = client.Admin.UpdateOneID(uid).
+			SetPassword(secure.CryptPassword(request.NewPassword)).
+			SetPasswordHistory(history).
+			Save(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// 撤销所有已有会话, 当前客户端使用新签发的令牌继续访问
+		err = revokeAdminSessions(ctx, client, uid)
+		if err != nil {
+			return nil, err
+		}
+		return s.createAdminToken(ctx, client, administrator, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.ChangePasswordResponse{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Expires:      token.Expires,
+	}, nil
+}
@@ -0,0 +1,355 @@
+package dash
+
+import (
+	"context"
+	"strings"
+
+	"entgo.io/ent/dialect/sql"
+	"entgo.io/ent/dialect/sql/sqljson"
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/conv"
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/admin"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/permission"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/role"
+	"github.com/go-sphere/sphere-layout/internal/pkg/render/entbind"
+	"github.com/go-sphere/sphere/server/auth/acl"
+)
+
+var _ dashv1.RoleServiceHTTPServer = (*Service)(nil)
+
+type builtinItem struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+var builtinPermissions = []builtinItem{
+	{Name: PermissionAdmin, Description: "管理员及角色管理"},
+	{Name: PermissionAdminRead, Description: "查看管理员及角色"},
+	{Name: PermissionAuditRead, Description: "查看审计日志"},
+	{Name: PermissionUser, Description: "客户端用户管理"},
+	{Name: PermissionUserRead, Description: "查看客户端用户"},
+	{Name: PermissionUserImpersonate, Description: "以用户身份登录客户端"},
+}
+
+var builtinRoles = []builtinItem{
+	{Name: PermissionAll, Description: "超级管理员, 拥有全部权限"},
+	{Name: PermissionAdmin, Description: "管理员", Permissions: []string{PermissionAdmin, PermissionAdminRead}},
+	{Name: "admin_viewer", Description: "只读管理员", Permissions: []string{PermissionAdminRead}},
+}
+
+// InitACL makes sure the builtin roles and permissions exist and loads the ACL from the database.
+// Builtin definitions are overwritten on every start so that they follow the code.
+func (s *Service) InitACL(ctx context.Context) error {
+	err := dao.WithTxEx(ctx, s.db.Client, func(ctx context.Context, client *ent.Client) error {
+		for _, item := range builtinPermissions {
+			err := client.Permission.Create().
+				SetName(item.Name).
+				SetDescription(item.Description).
+				SetBuiltin(true).
+				OnConflictColumns(permission.FieldName).
+				UpdateNewValues().
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		for _, item := range builtinRoles {
+			permissions := item.Permissions
+			if permissions == nil {
+				permissions = []string{}
+			}
+			err := client.Role.Create().
+				SetName(item.Name).
+				SetDescription(item.Description).
+				SetPermissions(permissions).
+				SetBuiltin(true).
+				OnConflictColumns(role.FieldName).
+				UpdateNewValues().
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.ReloadACL(ctx)
+}
+
+// accessControl is an immutable snapshot of the roles and permissions stored in the database.
+type accessControl struct {
+	acl         *acl.ACL
+	permissions map[string][]string
+}
+
+// ReloadACL rebuilds the ACL from the database and swaps it in atomically.
+// The "all" role is granted every known permission.
+func (s *Service) ReloadACL(ctx context.Context) error {
+	permissions, err := s.db.Permission.Query().Order(permission.ByName()).All(ctx)
+	if err != nil {
+		return err
+	}
+	roles, err := s.db.Role.Query().All(ctx)
+	if err != nil {
+		return err
+	}
+	next := &accessControl{
+		acl:         acl.NewACL(),
+		permissions: make(map[string][]string, len(roles)+1),
+	}
+	for _, p := range permissions {
+		next.acl.Allow(PermissionAll, p.Name)
+		next.permissions[PermissionAll] = append(next.permissions[PermissionAll], p.Name)
+	}
+	for _, r := range roles {
+		if r.Name == PermissionAll {
+			continue
+		}
+		for _, p := range r.Permissions {
+			next.acl.Allow(r.Name, p)
+		}
+		next.permissions[r.Name] = r.Permissions
+	}
+	s.acl.Store(next)
+	return nil
+}
+
+// IsAllowed reports whether any of the roles grants access to the resource.
+func (s *Service) IsAllowed(roles []string, resource string) bool {
+	current := s.acl.Load()
+	if current == nil {
+		return false
+	}
+	if resource == PermissionAuthenticated {
+		return !isApiKeyRoles(roles)
+	}
+	for _, r := range roles {
+		if scope, ok := strings.CutPrefix(r, ApiKeyScopeRolePrefix); ok {
+			if scope == resource {
+				return true
+			}
+			continue
+		}
+		if current.acl.IsAllowed(r, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectivePermissions returns the sorted union of the permissions granted by the roles.
+func (s *Service) EffectivePermissions(roles []string) []string {
+	current := s.acl.Load()
+	if current == nil {
+		return []string{}
+	}
+	var permissions []string
+	for _, r := range roles {
+		if scope, ok := strings.CutPrefix(r, ApiKeyScopeRolePrefix); ok {
+			if scope != "" {
+				permissions = append(permissions, scope)
+			}
+			continue
+		}
+		permissions = append(permissions, current.permissions[r]...)
+	}
+	return conv.UniqueSorted(permissions)
+}
+
+func (s *Service) checkRolesExist(ctx context.Context, roles []string) error {
+	names := conv.UniqueSorted(roles)
+	if len(names) == 0 {
+		return nil
+	}
+	count, err := s.db.Role.Query().Where(role.NameIn(names...)).Count(ctx)
+	if err != nil {
+		return err
+	}
+	if count != len(names) {
+		return dashv1.RoleError_ROLE_ERROR_UNKNOWN_ROLE
+	}
+	return nil
+}
+
+func (s *Service) checkPermissionsExist(ctx context.Context, permissions []string) error {
+	names := conv.UniqueSorted(permissions)
+	if len(names) == 0 {
+		return nil
+	}
+	count, err := s.db.Permission.Query().Where(permission.NameIn(names...)).Count(ctx)
+	if err != nil {
+		return err
+	}
+	if count != len(names) {
+		return dashv1.RoleError_ROLE_ERROR_UNKNOWN_PERMISSION
+	}
+	return nil
+}
+
+func (s *Service) isRoleInUse(ctx context.Context, name string) (bool, error) {
+	return s.db.Admin.Query().Where(func(selector *sql.Selector) {
+		selector.Where(sqljson.ValueContains(admin.FieldRoles, name))
+	}).Exist(ctx)
+}
+
+func (s *Service) ListRoles(ctx context.Context, request *dashv1.ListRolesRequest) (*dashv1.ListRolesResponse, error) {
+	query := s.db.Role.Query()
+	count, err := query.Clone().Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	totalPage, pageSize := conv.Page(count, int(request.PageSize))
+	all, err := query.Clone().Limit(pageSize).Order(role.ByID(sql.OrderDesc())).Offset(pageSize * int(request.Page)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.ListRolesResponse{
+		Roles:     conv.Map(all, s.render.Role),
+		TotalSize: int64(count),
+		TotalPage: int64(totalPage),
+	}, nil
+}
+
+func (s *Service) CreateRole(ctx context.Context, request *dashv1.CreateRoleRequest) (*dashv1.CreateRoleResponse, error) {
+	if strings.HasPrefix(request.Role.Name, ApiKeyScopeRolePrefix) {
+		return nil, dashv1.RoleError_ROLE_ERROR_RESERVED_NAME
+	}
+	if err := s.checkPermissionsExist(ctx, request.Role.Permissions); err != nil {
+		return nil, err
+	}
+	item, err := entbind.CreateRole(s.db.Role.Create(), request.Role, entbind.IgnoreField(role.FieldID)).Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.ReloadACL(ctx); err != nil {
+		return nil, err
+	}
+	return &dashv1.CreateRoleResponse{
+		Role: s.render.Role(item),
+	}, nil
+}
+
+func (s *Service) UpdateRole(ctx context.Context, request *dashv1.UpdateRoleRequest) (*dashv1.UpdateRoleResponse, error) {
+	if strings.HasPrefix(request.Role.Name, ApiKeyScopeRolePrefix) {
+		return nil, dashv1.RoleError_ROLE_ERROR_RESERVED_NAME
+	}
+	old, err := s.db.Role.Get(ctx, request.Role.Id)
+	if err != nil {
+		return nil, err
+	}
+	if old.Builtin {
+		return nil, dashv1.RoleError_ROLE_ERROR_BUILTIN_READONLY
+	}
+	if old.Name != request.Role.Name {
+		inUse, uErr := s.isRoleInUse(ctx, old.Name)
+		if uErr != nil {
+			return nil, uErr
+		}
+		if inUse {
+			return nil, dashv1.RoleError_ROLE_ERROR_IN_USE
+		}
+	}
+	if err = s.checkPermissionsExist(ctx, request.Role.Permissions); err != nil {
+		return nil, err
+	}
+	item, err := entbind.UpdateOneRole(s.db.Role.UpdateOneID(request.Role.Id), request.Role).Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.ReloadACL(ctx); err != nil {
+		return nil, err
+	}
+	return &dashv1.UpdateRoleResponse{
+		Role: s.render.Role(item),
+	}, nil
+}
+
+func (s *Service) GetRole(ctx context.Context, request *dashv1.GetRoleRequest) (*dashv1.GetRoleResponse, error) {
+	item, err := s.db.Role.Get(ctx, request.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.GetRoleResponse{
+		Role: s.render.Role(item),
+	}, nil
+}
+
+func (s *Service) DeleteRole(ctx context.Context, request *dashv1.DeleteRoleRequest) (*dashv1.DeleteRoleResponse, error) {
+	item, err := s.db.Role.Get(ctx, request.Id)
+	if err != nil {
+		return nil, err
+	}
+	if item.Builtin {
+		return nil, dashv1.RoleError_ROLE_ERROR_BUILTIN_READONLY
+	}
+	inUse, err := s.isRoleInUse(ctx, item.Name)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		return nil, dashv1.RoleError_ROLE_ERROR_IN_USE
+	}
+	err = s.db.Role.DeleteOneID(request.Id).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.ReloadACL(ctx); err != nil {
+		return nil, err
+	}
+	return &dashv1.DeleteRoleResponse{}, nil
+}
+
+func (s *Service) ListPermissions(ctx context.Context, request *dashv1.ListPermissionsRequest) (*dashv1.ListPermissionsResponse, error) {
+	all, err := s.db.Permission.Query().Order(permission.ByName()).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.ListPermissionsResponse{
+		Permissions: conv.Map(all, s.render.Permission),
+	}, nil
+}
+
+func (s *Service) CreatePermission(ctx context.Context, request *dashv1.CreatePermissionRequest) (*dashv1.CreatePermissionResponse, error) {
+	item, err := entbind.CreatePermission(s.db.Permission.Create(), request.Permission, entbind.IgnoreField(permission.FieldID)).Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.ReloadACL(ctx); err != nil {
+		return nil, err
+	}
+	return &dashv1.CreatePermissionResponse{
+		Permission: s.render.Permission(item),
+	}, nil
+}
+
+func (s *Service) DeletePermission(ctx context.Context, request *dashv1.DeletePermissionRequest) (*dashv1.DeletePermissionResponse, error) {
+	item, err := s.db.Permission.Get(ctx, request.Id)
+	if err != nil {
+		return nil, err
+	}
+	if item.Builtin {
+		return nil, dashv1.RoleError_ROLE_ERROR_BUILTIN_READONLY
+	}
+	inUse, err := s.db.Role.Query().Where(func(selector *sql.Selector) {
+		selector.Where(sqljson.ValueContains(role.FieldPermissions, item.Name))
+	}).Exist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		return nil, dashv1.RoleError_ROLE_ERROR_IN_USE
+	}
+	err = s.db.Permission.DeleteOneID(request.Id).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.ReloadACL(ctx); err != nil {
+		return nil, err
+	}
+	return &dashv1.DeletePermissionResponse{}, nil
+}
@@ -0,0 +1,120 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-sphere/httpx"
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/security"
+	"github.com/go-sphere/sphere/log"
+	"github.com/go-sphere/sphere/utils/secure"
+)
+
+const (
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+)
+
+type SecurityEvent struct {
+	Type      string `json:"type"`
+	UID       int64  `json:"uid"`
+	SessionID int64  `json:"session_id"`
+	IP        string `json:"ip"`
+	UA        string `json:"ua"`
+	Detail    string `json:"detail"`
+}
+
+// emitSecurityEvent reports suspicious activity which should be reviewed by an operator.
+func (s *Service) emitSecurityEvent(ctx context.Context, event SecurityEvent) {
+	if ip, ok := ctx.Value(AuthContextKeyIP).(string); ok && event.IP == "" {
+		event.IP = ip
+	}
+	if ua, ok := ctx.Value(AuthContextKeyUA).(string); ok && event.UA == "" {
+		event.UA = ua
+	}
+	log.Warn("dash security event", log.Any("event", event))
+}
+
+func (s *Service) InitSecurity(passwordPolicy security.PasswordPolicy, loginLockout security.LockoutPolicy) {
+	s.passwordPolicy = passwordPolicy
+	s.loginLockout = loginLockout
+}
+
+func accountLockedError(until int64) error {
+	code := dashv1.AuthError_AUTH_ERROR_ACCOUNT_LOCKED
+	message := "账号已锁定, 请于 " + time.Unix(until, 0).Format(AuthExpiresTimeFormat) + " 后重试"
+	return httpx.NewError(code.GetStatus(), code.GetCode(), message, code)
+}
+
+func weakPasswordError(err error) error {
+	code := dashv1.AuthError_AUTH_ERROR_WEAK_PASSWORD
+	return httpx.NewError(code.GetStatus(), code.GetCode(), err.Error(), code)
+}
+
+func (s *Service) checkAdminLocked(administrator *ent.Admin) error {
+	if administrator.LockedUntil > time.Now().Unix() {
+		return accountLockedError(administrator.LockedUntil)
+	}
+	return nil
+}
+
+// recordLoginFailure increases the failure counter and locks the account once the lockout policy is exceeded.
+// It always returns an error which should be sent back to the client.
+func (s *Service) recordLoginFailure(ctx context.Context, administrator *ent.Admin) error {
+	updated, err := s.db.Admin.UpdateOneID(administrator.ID).AddLoginFailures(1).Save(ctx)
+	if err != nil {
+		return dashv1.AuthError_AUTH_ERROR_INVALID_CREDENTIALS
+	}
+	duration := s.loginLockout.LockDuration(updated.LoginFailures)
+	if duration <= 0 {
+		return dashv1.AuthError_AUTH_ERROR_INVALID_CREDENTIALS
+	}
+	until := time.Now().Add(duration).Unix()
+	err = s.db.Admin.UpdateOneID(administrator.ID).SetLockedUntil(until).Exec(ctx)
+	if err != nil {
+		return dashv1.AuthError_AUTH_ERROR_INVALID_CREDENTIALS
+	}
+	return accountLockedError(until)
+}
+
+// recordTotpFailure counts a wrong second factor against the same lockout as a wrong password.
+// Once the account is locked the challenge is dropped as well.
+func (s *Service) recordTotpFailure(ctx context.Context, challengeToken string, administrator *ent.Admin) error {
+	err := s.recordLoginFailure(ctx, administrator)
+	if errors.Is(err, dashv1.AuthError_AUTH_ERROR_INVALID_CREDENTIALS) {
+		return dashv1.AuthError_AUTH_ERROR_INVALID_TOTP_CODE
+	}
+	_ = s.session.Del(ctx, totpChallengeKey(challengeToken))
+	return err
+}
+
+func (s *Service) resetLoginFailures(ctx context.Context, client *ent.Client, administrator *ent.Admin) error {
+	if administrator.LoginFailures == 0 && administrator.LockedUntil == 0 {
+		return nil
+	}
+	return client.Admin.UpdateOneID(administrator.ID).
+		SetLoginFailures(0).
+		SetLockedUntil(0).
+		Exec(ctx)
+}
+
+// validateAdminPassword checks the plain password against the password policy and,
+// for existing admins, against their recent passwords. It returns the password history
+// which should be stored together with the new password.
+func (s *Service) validateAdminPassword(administrator *ent.Admin, password string) ([]string, error) {
+	if err := s.passwordPolicy.Validate(password); err != nil {
+		return nil, weakPasswordError(err)
+	}
+	if administrator == nil {
+		return []string{}, nil
+	}
+	history := s.passwordPolicy.NextHistory(administrator.Password, administrator.PasswordHistory)
+	for _, hashed := range history {
+		if secure.IsPasswordMatch(password, hashed) {
+			return nil, dashv1.AuthError_AUTH_ERROR_PASSWORD_REUSED
+		}
+	}
+	return history, nil
+}
@@ -1,9 +1,15 @@
 package dash
 
 import (
+	"sync"
+	"sync/atomic"
+
 	"github.com/alitto/pond/v2"
 	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/oidc"
 	"github.com/go-sphere/sphere-layout/internal/pkg/render"
+	"github.com/go-sphere/sphere-layout/internal/pkg/security"
+	"github.com/go-sphere/sphere-layout/internal/pkg/totp"
 	"github.com/go-sphere/sphere/cache"
 	"github.com/go-sphere/sphere/cache/memory"
 	"github.com/go-sphere/sphere/server/auth/authorizer"
@@ -13,8 +19,17 @@ import (
 )
 
 const (
-	PermissionAll   = "all"
-	PermissionAdmin = "admin"
+	// PermissionAuthenticated is granted to every signed-in admin but never to an API key.
+	PermissionAuthenticated = "authenticated"
+
+	PermissionAll       = "all"
+	PermissionAdmin     = "admin"
+	PermissionAdminRead = "admin:read"
+	PermissionAuditRead = "audit:read"
+
+	PermissionUser            = "user"
+	PermissionUserRead        = "user:read"
+	PermissionUserImpersonate = "user:impersonate"
 )
 
 type TokenAuthorizer = authorizer.TokenAuthorizer[int64, jwtauth.RBACClaims[int64]]
@@ -30,13 +45,26 @@ type Service struct {
 	session cache.ByteCache
 	storage storage.CDNStorage
 	tasks   pond.ResultPool[string]
+	acl     atomic.Pointer[accessControl]
 
 	authorizer    TokenAuthorizer
 	authRefresher TokenAuthorizer
+
+	totpIssuer string
+	totpCipher *totp.Cipher
+	totpLock   sync.Mutex
+
+	passwordPolicy security.PasswordPolicy
+	loginLockout   security.LockoutPolicy
+
+	oidcProvider *oidc.Provider
+	oidcPolicy   OidcLoginPolicy
+
+	appAuthorizer TokenAuthorizer
 }
 
 func NewService(db *dao.Dao, wechat *wechat.Wechat, cache cache.ByteCache, store storage.CDNStorage) *Service {
-	return &Service{
+	s := &Service{
 		db:      db,
 		wechat:  wechat,
 		render:  render.NewRender(db, store, true),
@@ -45,6 +73,8 @@ func NewService(db *dao.Dao, wechat *wechat.Wechat, cache cache.ByteCache, store
 		storage: store,
 		tasks:   pond.NewResultPool[string](16),
 	}
+	db.Use(s.auditHook())
+	return s
 }
 
 func (s *Service) Init(authorizer TokenAuthorizer, authRefresher TokenAuthorizer) {
@@ -0,0 +1,51 @@
+package dash
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/client"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere/cache/memory"
+	"github.com/go-sphere/sphere/server/auth/jwtauth"
+)
+
+const (
+	testJWT        = "test-dash-jwt-secret"
+	testRefreshJWT = "test-dash-refresh-jwt-secret"
+)
+
+// newTestService creates a service backed by an in-memory database.
+func newTestService(t *testing.T) (*Service, *ent.Client) {
+	t.Helper()
+
+	conf := client.Config{
+		Type: "sqlite3",
+		Path: fmt.Sprintf("file:dash-service-test-%d?mode=memory&cache=shared", time.Now().UnixNano()),
+	}
+	db, err := client.NewDataBaseClient(conf)
+	if err != nil {
+		t.Fatalf("create test database failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	s := NewService(dao.NewDao(db), nil, memory.NewByteCache(), nil)
+	s.Init(
+		jwtauth.NewJwtAuth[jwtauth.RBACClaims[int64]](testJWT),
+		jwtauth.NewJwtAuth[jwtauth.RBACClaims[int64]](testRefreshJWT),
+	)
+	return s, db
+}
+
+func createTestAdmin(t *testing.T, db *ent.Client, username string) *ent.Admin {
+	t.Helper()
+
+	administrator, err := db.Admin.Create().SetUsername(username).SetPassword("-").Save(context.Background())
+	if err != nil {
+		t.Fatalf("create admin failed: %v", err)
+	}
+	return administrator
+}
@@ -0,0 +1,275 @@
+package dash
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"time"
+
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/totp"
+	"github.com/go-sphere/sphere/utils/secure"
+	"github.com/google/uuid"
+)
+
+var _ dashv1.TotpServiceHTTPServer = (*Service)(nil)
+
+const (
+	TotpChallengeValidDuration = time.Minute * 5
+	TotpChallengeMaxAttempts   = 5
+	TotpRecoveryCodeCount      = 10
+	TotpDefaultIssuer          = "Sphere"
+)
+
+type totpChallenge struct {
+	UID      int64 `json:"uid"`
+	Attempts int   `json:"attempts"`
+	Expires  int64 `json:"expires"`
+}
+
+func totpChallengeKey(token string) string {
+	return "totp_challenge:" + token
+}
+
+func totpLastStepKey(uid int64) string {
+	return "totp_last_step:" + strconv.FormatInt(uid, 10)
+}
+
+func (s *Service) InitTotp(issuer string, cipher *totp.Cipher) {
+	if issuer == "" {
+		issuer = TotpDefaultIssuer
+	}
+	s.totpIssuer = issuer
+	s.totpCipher = cipher
+}
+
+func (s *Service) createTotpChallenge(ctx context.Context, administrator *ent.Admin) (string, error) {
+	newUUID, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	token := newUUID.String()
+	err = s.saveTotpChallenge(ctx, token, &totpChallenge{
+		UID:     administrator.ID,
+		Expires: time.Now().Add(TotpChallengeValidDuration).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *Service) saveTotpChallenge(ctx context.Context, token string, challenge *totpChallenge) error {
+	ttl := time.Until(time.Unix(challenge.Expires, 0))
+	if ttl <= 0 {
+		return dashv1.AuthError_AUTH_ERROR_INVALID_CHALLENGE
+	}
+	raw, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+	return s.session.SetWithTTL(ctx, totpChallengeKey(token), raw, ttl)
+}
+
+func (s *Service) loadTotpChallenge(ctx context.Context, token string) (*totpChallenge, error) {
+	raw, found, err := s.session.Get(ctx, totpChallengeKey(token))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, dashv1.AuthError_AUTH_ERROR_INVALID_CHALLENGE
+	}
+	var challenge totpChallenge
+	if err = json.Unmarshal(raw, &challenge); err != nil {
+		return nil, err
+	}
+	if challenge.Expires < time.Now().Unix() {
+		return nil, dashv1.AuthError_AUTH_ERROR_INVALID_CHALLENGE
+	}
+	return &challenge, nil
+}
+
+// reserveTotpChallenge counts an attempt before the code is verified, so parallel guesses
+// cannot share the same attempt. The challenge is dropped once the attempts are exhausted.
+func (s *Service) reserveTotpChallenge(ctx context.Context, token string) (*totpChallenge, error) {
+	s.totpLock.Lock()
+	defer s.totpLock.Unlock()
+	challenge, err := s.loadTotpChallenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Attempts >= TotpChallengeMaxAttempts {
+		_ = s.session.Del(ctx, totpChallengeKey(token))
+		return nil, dashv1.AuthError_AUTH_ERROR_INVALID_CHALLENGE
+	}
+	challenge.Attempts++
+	if err = s.saveTotpChallenge(ctx, token, challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// acceptTotpCode validates a TOTP code and rejects it when the same or an earlier time step
+// has already been accepted for the admin, so an observed code cannot be replayed.
+func (s *Service) acceptTotpCode(ctx context.Context, uid int64, code, secret string) (bool, error) {
+	step, ok := totp.ValidateCounter(code, secret, time.Now())
+	if !ok {
+		return false, nil
+	}
+	s.totpLock.Lock()
+	defer s.totpLock.Unlock()
+	key := totpLastStepKey(uid)
+	raw, found, err := s.session.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if found {
+		last, pErr := strconv.ParseInt(string(raw), 10, 64)
+		if pErr == nil && step <= last {
+			return false, nil
+		}
+	}
+	ttl := time.Duration(totp.DefaultPeriod*(2*totp.DefaultSkew+1)) * time.Second
+	err = s.session.SetWithTTL(ctx, key, []byte(strconv.FormatInt(step, 10)), ttl)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Service) decryptTotpSecret(administrator *ent.Admin) (string, error) {
+	if administrator.TotpSecret == "" {
+		return "", dashv1.AuthError_AUTH_ERROR_TOTP_NOT_ENROLLED
+	}
+	return s.totpCipher.Decrypt(administrator.TotpSecret)
+}
+
+// verifyAdminTotp checks a TOTP code, falling back to the recovery codes.
+// A matched recovery code is removed so it can only be used once.
+func (s *Service) verifyAdminTotp(ctx context.Context, client *ent.Client, administrator *ent.Admin, code string) (bool, error) {
+	secret, err := s.decryptTotpSecret(administrator)
+	if err != nil {
+		return false, err
+	}
+	ok, err := s.acceptTotpCode(ctx, administrator.ID, code, secret)
+	if err != nil || ok {
+		return ok, err
+	}
+	index := slices.IndexFunc(administrator.RecoveryCodes, func(hashed string) bool {
+		return secure.IsPasswordMatch(code, hashed)
+	})
+	if index < 0 {
+		return false, nil
+	}
+	codes := slices.Delete(slices.Clone(administrator.RecoveryCodes), index, index+1)
+	err = client.Admin.UpdateOneID(administrator.ID).SetRecoveryCodes(codes).Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Service) EnrollTotp(ctx context.Context, request *dashv1.EnrollTotpRequest) (*dashv1.EnrollTotpResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	administrator, err := s.db.Admin.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if administrator.TotpEnabled {
+		return nil, dashv1.AuthError_AUTH_ERROR_TOTP_ALREADY_ENABLED
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.totpCipher.Encrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Admin.UpdateOneID(uid).SetTotpSecret(encrypted).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.EnrollTotpResponse{
+		Secret: secret,
+		Uri:    totp.KeyURI(s.totpIssuer, administrator.Username, secret),
+	}, nil
+}
+
+func (s *Service) ConfirmTotp(ctx context.Context, request *dashv1.ConfirmTotpRequest) (*dashv1.ConfirmTotpResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	administrator, err := s.db.Admin.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if administrator.TotpEnabled {
+		return nil, dashv1.AuthError_AUTH_ERROR_TOTP_ALREADY_ENABLED
+	}
+	secret, err := s.decryptTotpSecret(administrator)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.acceptTotpCode(ctx, uid, request.Code, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, dashv1.AuthError_AUTH_ERROR_INVALID_TOTP_CODE
+	}
+	codes, err := totp.GenerateRecoveryCodes(TotpRecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	hashed := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashed = append(hashed, secure.CryptPassword(code))
+	}
+	err = s.db.Admin.UpdateOneID(uid).
+		SetTotpEnabled(true).
+		SetRecoveryCodes(hashed).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.ConfirmTotpResponse{
+		RecoveryCodes: codes,
+	}, nil
+}
+
+func (s *Service) DisableTotp(ctx context.Context, request *dashv1.DisableTotpRequest) (*dashv1.DisableTotpResponse, error) {
+	uid, err := s.GetCurrentID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	administrator, err := s.db.Admin.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if !administrator.TotpEnabled {
+		return nil, dashv1.AuthError_AUTH_ERROR_TOTP_NOT_ENABLED
+	}
+	ok, err := s.verifyAdminTotp(ctx, s.db.Client, administrator, request.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, dashv1.AuthError_AUTH_ERROR_INVALID_TOTP_CODE
+	}
+	err = s.db.Admin.UpdateOneID(uid).
+		SetTotpEnabled(false).
+		SetTotpSecret("").
+		SetRecoveryCodes([]string{}).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.DisableTotpResponse{}, nil
+}
@@ -0,0 +1,218 @@
+package dash
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+	dashv1 "github.com/go-sphere/sphere-layout/api/dash/v1"
+	"github.com/go-sphere/sphere-layout/internal/pkg/conv"
+	"github.com/go-sphere/sphere-layout/internal/pkg/dao"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/predicate"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/user"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userflagrecord"
+	"github.com/go-sphere/sphere-layout/internal/pkg/database/ent/userplatform"
+	"github.com/go-sphere/sphere-layout/internal/pkg/userflag"
+)
+
+var _ dashv1.UserServiceHTTPServer = (*Service)(nil)
+
+func (s *Service) ListUsers(ctx context.Context, request *dashv1.ListUsersRequest) (*dashv1.ListUsersResponse, error) {
+	query := s.db.User.Query()
+	if request.Keyword != "" {
+		platformUsers, err := s.db.UserPlatform.Query().
+			Where(
+				userplatform.PlatformIn(userplatform.PlatformPhone, userplatform.PlatformEmail),
+				userplatform.PlatformIDContainsFold(request.Keyword),
+			).
+			Select(userplatform.FieldUserID).
+			Int64s(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conditions := []predicate.User{
+			user.UsernameContainsFold(request.Keyword),
+			user.NicknameContainsFold(request.Keyword),
+			user.IDIn(platformUsers...),
+		}
+		if id, pErr := strconv.ParseInt(request.Keyword, 10, 64); pErr == nil {
+			conditions = append(conditions, user.IDEQ(id))
+		}
+		query = query.Where(user.Or(conditions...))
+	}
+	count, err := query.Clone().Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	totalPage, pageSize := conv.Page(count, int(request.PageSize))
+	all, err := query.Clone().Limit(pageSize).Order(user.ByID(sql.OrderDesc())).Offset(pageSize * int(request.Page)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.ListUsersResponse{
+		Users:     conv.Map(all, s.render.UserDetail),
+		TotalSize: int64(count),
+		TotalPage: int64(totalPage),
+	}, nil
+}
+
+func (s *Service) GetUser(ctx context.Context, request *dashv1.GetUserRequest) (*dashv1.GetUserResponse, error) {
+	item, err := s.db.User.Get(ctx, request.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.GetUserResponse{
+		User: s.render.UserDetail(item),
+	}, nil
+}
+
+func (s *Service) UpdateUser(ctx context.Context, request *dashv1.UpdateUserRequest) (*dashv1.UpdateUserResponse, error) {
+	if userflag.Unknown(request.Flags) != 0 {
+		return nil, dashv1.UserError_USER_ERROR_UNKNOWN_FLAG
+	}
+	item, err := dao.WithTx[ent.User](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*ent.User, error) {
+		// 清除的标记不再需要原因和过期时间
+		_, err := client.UserFlagRecord.Delete().
+			Where(userflagrecord.UserIDEQ(request.Id), userflagrecord.FlagNotIn(userflag.Names(request.Flags)...)).
+			Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return client.User.UpdateOneID(request.Id).
+			SetRemark(request.Remark).
+			SetFlags(request.Flags).
+			Save(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = s.cache.Del(ctx, userflag.CacheKey(item.ID))
+	return &dashv1.UpdateUserResponse{
+		User: s.render.UserDetail(item),
+	}, nil
+}
+
+func (s *Service) ListUserFlags(ctx context.Context, request *dashv1.ListUserFlagsRequest) (*dashv1.ListUserFlagsResponse, error) {
+	all, err := s.db.UserFlagRecord.Query().
+		Where(userflagrecord.UserIDEQ(request.UserId)).
+		Order(userflagrecord.ByFlag()).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.ListUserFlagsResponse{
+		Flags: conv.Map(all, s.render.UserFlagRecord),
+		Definitions: conv.Map(userflag.All(), func(f userflag.Flag) *dashv1.UserFlagDefinition {
+			return &dashv1.UserFlagDefinition{
+				Bit:         f.Bit,
+				Name:        f.Name,
+				Description: f.Description,
+			}
+		}),
+	}, nil
+}
+
+// setUserFlag sets or clears a flag of the user. The reason and expiry of a set flag are kept in UserFlagRecord.
+func (s *Service) setUserFlag(ctx context.Context, uid int64, name string, enabled bool, reason string, expiresAt int64) (*ent.User, error) {
+	flag, ok := userflag.Lookup(name)
+	if !ok {
+		return nil, dashv1.UserError_USER_ERROR_UNKNOWN_FLAG
+	}
+	if enabled && expiresAt != 0 && expiresAt <= time.Now().Unix() {
+		return nil, dashv1.UserError_USER_ERROR_INVALID_EXPIRES
+	}
+	operator, _ := s.GetCurrentID(ctx)
+	item, err := dao.WithTx[ent.User](ctx, s.db.Client, func(ctx context.Context, client *ent.Client) (*ent.User, error) {
+		item, err := client.User.Get(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		_, err = client.UserFlagRecord.Delete().
+			Where(userflagrecord.UserIDEQ(uid), userflagrecord.FlagEQ(flag.Name)).
+			Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			return client.User.UpdateOne(item).SetFlags(item.Flags &^ flag.Bit).Save(ctx)
+		}
+		err = client.UserFlagRecord.Create().
+			SetUserID(uid).
+			SetFlag(flag.Name).
+			SetReason(reason).
+			SetExpiresAt(expiresAt).
+			SetOperatorID(operator).
+			Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return client.User.UpdateOne(item).SetFlags(item.Flags | flag.Bit).Save(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = s.cache.Del(ctx, userflag.CacheKey(uid))
+	return item, nil
+}
+
+func (s *Service) SetUserFlag(ctx context.Context, request *dashv1.SetUserFlagRequest) (*dashv1.SetUserFlagResponse, error) {
+	item, err := s.setUserFlag(ctx, request.Id, request.Flag, request.Enabled, request.Reason, request.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.SetUserFlagResponse{
+		User: s.render.UserDetail(item),
+	}, nil
+}
+
+func (s *Service) BanUser(ctx context.Context, request *dashv1.BanUserRequest) (*dashv1.BanUserResponse, error) {
+	_, err := s.setUserFlag(ctx, request.Id, userflag.NameBanned, true, request.Reason, request.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.BanUserResponse{}, nil
+}
+
+func (s *Service) UnbanUser(ctx context.Context, request *dashv1.UnbanUserRequest) (*dashv1.UnbanUserResponse, error) {
+	_, err := s.setUserFlag(ctx, request.Id, userflag.NameBanned, false, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.UnbanUserResponse{}, nil
+}
+
+func (s *Service) ListUserPlatforms(ctx context.Context, request *dashv1.ListUserPlatformsRequest) (*dashv1.ListUserPlatformsResponse, error) {
+	all, err := s.db.UserPlatform.Query().
+		Where(userplatform.UserIDEQ(request.UserId)).
+		Order(userplatform.ByID()).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.ListUserPlatformsResponse{
+		Platforms: conv.Map(all, s.render.UserPlatform),
+	}, nil
+}
+
+func (s *Service) UnlinkUserPlatform(ctx context.Context, request *dashv1.UnlinkUserPlatformRequest) (*dashv1.UnlinkUserPlatformResponse, error) {
+	err := dao.WithTxEx(ctx, s.db.Client, func(ctx context.Context, client *ent.Client) error {
+		item, err := client.UserPlatform.Get(ctx, request.Id)
+		if err != nil {
+			return err
+		}
+		count, err := client.UserPlatform.Query().Where(userplatform.UserIDEQ(item.UserID)).Count(ctx)
+		if err != nil {
+			return err
+		}
+		if count <= 1 {
+			return dashv1.UserError_USER_ERROR_LAST_PLATFORM
+		}
+		return client.UserPlatform.DeleteOne(item).Exec(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dashv1.UnlinkUserPlatformResponse{}, nil
+}
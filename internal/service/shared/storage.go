@@ -3,7 +3,9 @@ package shared
 import (
 	"context"
 	"fmt"
+	"path"
 	"strconv"
+	"strings"
 
 	sharedv1 "github.com/go-sphere/sphere-layout/api/shared/v1"
 	"github.com/go-sphere/sphere/storage"
@@ -11,6 +13,21 @@ import (
 
 var _ sharedv1.StorageServiceHTTPServer = (*Service)(nil)
 
+// UploadKeyPrefix returns the prefix of the file names issued to the user by UploadToken.
+// The separator after the id keeps the files of user 12 apart from the files of user 123.
+func UploadKeyPrefix(uid int64) string {
+	return strconv.FormatInt(uid, 10) + "_"
+}
+
+// UploadKeyBuilder builds the file names issued to the user, storage.DefaultKeyBuilder provides the unique part.
+func UploadKeyBuilder(uid int64) func(string) string {
+	build := storage.DefaultKeyBuilder("")
+	prefix := UploadKeyPrefix(uid)
+	return func(fileName string) string {
+		return prefix + strings.TrimLeft(path.Base(build(fileName)), "_")
+	}
+}
+
 func (s *Service) UploadToken(ctx context.Context, req *sharedv1.UploadTokenRequest) (*sharedv1.UploadTokenResponse, error) {
 	if req.Filename == "" {
 		return nil, fmt.Errorf("filename is required")
@@ -19,7 +36,7 @@ func (s *Service) UploadToken(ctx context.Context, req *sharedv1.UploadTokenRequ
 	if err != nil {
 		return nil, err
 	}
-	key := storage.DefaultKeyBuilder(strconv.Itoa(int(id)))
+	key := UploadKeyBuilder(id)
 	token, err := s.storage.GenerateUploadAuth(ctx, storage.UploadAuthRequest{
 		FileName: key(req.Filename),
 		Dir:      s.storageDir,